@@ -0,0 +1,74 @@
+package gotailf
+
+import "time"
+
+// batcher accumulates records into batches.
+type batcher struct {
+	config  *Config
+	resultC chan<- []*Record
+	batch   []*Record
+	bytes   int
+	// read time of the first record of the batch.
+	since time.Time
+	// preallocated records.
+	slab []Record
+}
+
+func newBatcher(config *Config, resultC chan<- []*Record) *batcher {
+	return &batcher{
+		config:  config,
+		resultC: resultC,
+	}
+}
+
+func batchBufferSize(config *Config) int {
+	if config.BatchMaxCount <= 0 {
+		return 1
+	}
+	if n := int(config.BufferSize) / config.BatchMaxCount; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// alloc returns a new record from the slab to reduce allocations.
+func (s *batcher) alloc() *Record {
+	if len(s.slab) == 0 {
+		n := s.config.BatchMaxCount
+		if n <= 0 || n > 1024 {
+			n = 1024
+		}
+		s.slab = make([]Record, n)
+	}
+	r := &s.slab[0]
+	s.slab = s.slab[1:]
+	return r
+}
+
+func (s *batcher) add(r *Record) {
+	if len(s.batch) == 0 {
+		s.since = r.Time
+	}
+	s.batch = append(s.batch, r)
+	s.bytes += len(r.Text)
+	if s.isFull(r.Time) {
+		s.flush()
+	}
+}
+
+// isFull returns true if the batch should be yielded.
+// The latency is measured by the read time of the records.
+func (s *batcher) isFull(now time.Time) bool {
+	return (s.config.BatchMaxCount > 0 && len(s.batch) >= s.config.BatchMaxCount) ||
+		(s.config.BatchMaxBytes > 0 && s.bytes >= s.config.BatchMaxBytes) ||
+		(s.config.BatchMaxLatency > 0 && now.Sub(s.since) >= s.config.BatchMaxLatency)
+}
+
+func (s *batcher) flush() {
+	if len(s.batch) == 0 {
+		return
+	}
+	s.resultC <- s.batch
+	s.batch = make([]*Record, 0, len(s.batch))
+	s.bytes = 0
+}
@@ -0,0 +1,144 @@
+package gotailf_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailerBatches(t *testing.T) {
+	t.Parallel()
+	f := test.NewTmpFile(t)
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(f.File(), "line%d\n", i)
+	}
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithFlushInterval(50*time.Millisecond),
+		gotailf.WithOffset(0),
+		gotailf.WithBatchMaxCount(2),
+	)
+	assert.Nil(t, err)
+	time.AfterFunc(80*time.Millisecond, func() {
+		fmt.Fprint(f.File(), "appended\n")
+	})
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	got := [][]string{}
+	for b := range s.Batches(ctx) {
+		texts := make([]string, len(b))
+		for i, r := range b {
+			texts[i] = r.Text
+		}
+		got = append(got, texts)
+	}
+	assert.Nil(t, s.Err())
+	assert.Equal(t, [][]string{
+		{"line0", "line1"},
+		{"line2", "line3"},
+		{"line4"}, // flushed at EOF
+		{"appended"},
+	}, got)
+}
+
+func TestTailerBatchesMaxLatency(t *testing.T) {
+	t.Parallel()
+	f := test.NewTmpFile(t)
+	fmt.Fprint(f.File(), strings.Repeat("line\n", 100))
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithFlushInterval(50*time.Millisecond),
+		gotailf.WithOffset(0),
+		gotailf.WithBatchMaxLatency(time.Nanosecond),
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	var (
+		batches int
+		last    time.Time
+	)
+	for b := range s.Batches(ctx) {
+		batches++
+		for _, r := range b {
+			// the read time of each record
+			assert.False(t, r.Time.Before(last))
+			last = r.Time
+		}
+	}
+	assert.Nil(t, s.Err())
+	// the lines are read at once, but the batches are split by the read times of the lines
+	assert.Greater(t, batches, 1)
+}
+
+func newBenchmarkFile(b *testing.B, lines int) (string, func()) {
+	f, err := os.CreateTemp("", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	line := `time=2021-10-01T12:00:00Z level=info msg="request done" status=200 path=/api/users` + "\n"
+	if _, err := f.WriteString(strings.Repeat(line, lines)); err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+	return f.Name(), func() { os.Remove(f.Name()) }
+}
+
+const benchmarkLines = 200000
+
+func BenchmarkTailerRecords(b *testing.B) {
+	name, cleanup := newBenchmarkFile(b, benchmarkLines)
+	defer cleanup()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := gotailf.NewTailer(name, gotailf.WithOffset(0))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		var n int
+		for range s.Records(ctx) {
+			n++
+			if n == benchmarkLines {
+				cancel()
+			}
+		}
+		cancel()
+	}
+}
+
+func BenchmarkTailerBatches(b *testing.B) {
+	name, cleanup := newBenchmarkFile(b, benchmarkLines)
+	defer cleanup()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := gotailf.NewTailer(name, gotailf.WithOffset(0))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		var n int
+		for batch := range s.Batches(ctx) {
+			n += len(batch)
+			if n == benchmarkLines {
+				cancel()
+			}
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,226 @@
+package gotailf
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// BroadcastConfig represents Broadcaster configurations.
+type BroadcastConfig struct {
+	// History is the number of the last records kept for the new subscribers.
+	// Default is 0.
+	History int
+}
+
+type BroadcastOption func(*BroadcastConfig)
+
+// WithBroadcastHistory sets BroadcastConfig.History.
+func WithBroadcastHistory(n int) BroadcastOption {
+	return func(c *BroadcastConfig) {
+		c.History = n
+	}
+}
+
+// SubscribeConfig represents Broadcaster.Subscribe() configurations.
+type SubscribeConfig struct {
+	// BufferSize is the size of the buffer of the subscription.
+	// Default is 1000.
+	BufferSize int
+	// OverflowPolicy decides what to do when the buffer of the subscription is full.
+	// OverflowBlock blocks all the subscribers.
+	// Default is OverflowBlock.
+	OverflowPolicy OverflowPolicy
+	// Replay is the number of the last records yielded first.
+	// Bounded by BroadcastConfig.History.
+	// Default is 0.
+	Replay int
+}
+
+type SubscribeOption func(*SubscribeConfig)
+
+// WithSubscribeBufferSize sets SubscribeConfig.BufferSize.
+func WithSubscribeBufferSize(size int) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.BufferSize = size
+	}
+}
+
+// WithSubscribeOverflowPolicy sets SubscribeConfig.OverflowPolicy.
+func WithSubscribeOverflowPolicy(policy OverflowPolicy) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.OverflowPolicy = policy
+	}
+}
+
+// WithSubscribeReplay sets SubscribeConfig.Replay.
+func WithSubscribeReplay(n int) SubscribeOption {
+	return func(c *SubscribeConfig) {
+		c.Replay = n
+	}
+}
+
+// Broadcaster yields the records of a tailer to multiple subscribers.
+// The records are shared among the subscribers, they should not be modified.
+type Broadcaster struct {
+	tailer Tailer
+	config *BroadcastConfig
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	// history is the ring buffer of the last records.
+	history []*Record
+	// head is the index of the next record in history.
+	head  int
+	ended bool
+	doneC chan struct{}
+}
+
+// NewBroadcaster starts tailing by the tailer and returns a new Broadcaster.
+// The subscriptions are closed when the tailing ends.
+func NewBroadcaster(ctx context.Context, t Tailer, opts ...BroadcastOption) *Broadcaster {
+	config := &BroadcastConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	b := &Broadcaster{
+		tailer:      t,
+		config:      config,
+		subscribers: map[*Subscription]struct{}{},
+		history:     make([]*Record, 0, config.History),
+		doneC:       make(chan struct{}),
+	}
+	go b.loop(t.Records(ctx))
+	return b
+}
+
+// Done is closed when the tailing ends.
+func (b *Broadcaster) Done() <-chan struct{} { return b.doneC }
+
+// Err returns the error of the tailer.
+// This should be called after Done() is closed.
+func (b *Broadcaster) Err() error { return b.tailer.Err() }
+
+// Subscribers returns the number of the subscribers.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+func (b *Broadcaster) loop(recordC <-chan *Record) {
+	for r := range recordC {
+		b.mu.Lock()
+		b.remember(r)
+		for s := range b.subscribers {
+			s.o.send(r)
+		}
+		if len(recordC) == 0 {
+			// the read reaches the end of the file in most cases
+			for s := range b.subscribers {
+				s.o.flush()
+			}
+		}
+		b.mu.Unlock()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		s.o.flush()
+		close(s.c)
+	}
+	b.subscribers = map[*Subscription]struct{}{}
+	b.ended = true
+	close(b.doneC)
+}
+
+func (b *Broadcaster) remember(r *Record) {
+	if b.config.History <= 0 {
+		return
+	}
+	if len(b.history) < b.config.History {
+		b.history = append(b.history, r)
+		return
+	}
+	b.history[b.head] = r
+	b.head = (b.head + 1) % b.config.History
+}
+
+// recent returns the last n records in order.
+func (b *Broadcaster) recent(n int) []*Record {
+	if n > len(b.history) {
+		n = len(b.history)
+	}
+	if n <= 0 {
+		return nil
+	}
+	rs := make([]*Record, 0, n)
+	// the oldest record is at head when the history is full, otherwise at 0
+	for i := len(b.history) - n; i < len(b.history); i++ {
+		rs = append(rs, b.history[(b.head+i)%len(b.history)])
+	}
+	return rs
+}
+
+// Subscribe attaches a new subscriber.
+func (b *Broadcaster) Subscribe(opts ...SubscribeOption) *Subscription {
+	config := &SubscribeConfig{
+		BufferSize: 1000,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var (
+		replay = b.recent(config.Replay)
+		c      = make(chan *Record, config.BufferSize+len(replay))
+		s      = &Subscription{
+			b:     b,
+			c:     c,
+			doneC: make(chan struct{}),
+		}
+	)
+	s.o = newOverflow(config.OverflowPolicy, c, &s.dropped, skippedRecord)
+	s.o.done = s.doneC
+	for _, r := range replay {
+		c <- r
+	}
+	if b.ended {
+		close(c)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Subscription is a subscriber of Broadcaster.
+type Subscription struct {
+	b       *Broadcaster
+	c       chan *Record
+	o       *overflow[*Record]
+	dropped atomic.Int64
+	doneC   chan struct{}
+	once    sync.Once
+}
+
+// Records returns the records of the subscription.
+// Closed when the subscription is closed or the tailing ends.
+func (s *Subscription) Records() <-chan *Record { return s.c }
+
+// Dropped returns the number of the records dropped by SubscribeConfig.OverflowPolicy.
+func (s *Subscription) Dropped() int64 { return s.dropped.Load() }
+
+// Close detaches the subscriber.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		// stop the blocking send to this subscription
+		close(s.doneC)
+		s.b.mu.Lock()
+		defer s.b.mu.Unlock()
+		if _, ok := s.b.subscribers[s]; ok {
+			delete(s.b.subscribers, s)
+			close(s.c)
+		}
+	})
+}
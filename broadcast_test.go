@@ -0,0 +1,86 @@
+package gotailf_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func collectTexts(recordC <-chan *gotailf.Record) []string {
+	got := []string{}
+	for r := range recordC {
+		got = append(got, r.Text)
+	}
+	return got
+}
+
+func TestBroadcaster(t *testing.T) {
+	t.Parallel()
+
+	newBroadcaster := func(t *testing.T, ctx context.Context, content string, opts ...gotailf.BroadcastOption) (*test.TmpFile, *gotailf.Broadcaster) {
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), content)
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+		)
+		assert.Nil(t, err)
+		return f, gotailf.NewBroadcaster(ctx, s, opts...)
+	}
+
+	t.Run("subscribers", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		f, b := newBroadcaster(t, ctx, "", gotailf.WithBroadcastHistory(3))
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		var (
+			all  = b.Subscribe()
+			slow = b.Subscribe(
+				gotailf.WithSubscribeBufferSize(1),
+				gotailf.WithSubscribeOverflowPolicy(gotailf.OverflowDropNewest),
+			)
+		)
+		assert.Equal(t, 2, b.Subscribers())
+		fmt.Fprint(f.File(), "l1\nl2\nl3\nl4\n")
+		time.Sleep(100 * time.Millisecond)
+		replayed := b.Subscribe(gotailf.WithSubscribeReplay(2))
+		fmt.Fprint(f.File(), "l5\n")
+
+		assert.Equal(t, []string{"l1", "l2", "l3", "l4", "l5"}, collectTexts(all.Records()))
+		assert.Equal(t, []string{"l1"}, collectTexts(slow.Records()))
+		assert.Equal(t, int64(4), slow.Dropped())
+		assert.Equal(t, []string{"l3", "l4", "l5"}, collectTexts(replayed.Records()))
+		<-b.Done()
+		assert.Nil(t, b.Err())
+		assert.Equal(t, 0, b.Subscribers())
+		assert.Equal(t, []string{"l4", "l5"}, collectTexts(b.Subscribe(gotailf.WithSubscribeReplay(2)).Records()), "ended")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		f, b := newBroadcaster(t, ctx, "")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		var (
+			all     = b.Subscribe()
+			blocked = b.Subscribe(gotailf.WithSubscribeBufferSize(1))
+		)
+		fmt.Fprint(f.File(), "l1\nl2\nl3\n")
+		time.AfterFunc(100*time.Millisecond, blocked.Close)
+		assert.Equal(t, []string{"l1", "l2", "l3"}, collectTexts(all.Records()))
+		assert.Equal(t, []string{"l1"}, collectTexts(blocked.Records()))
+	})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal/term"
+	"github.com/berquerant/gotailf/level"
+)
+
+const colorReset = "\x1b[0m"
+
+// colors are the SGR sequences of the color names.
+var colors = map[string]string{
+	"black":          "\x1b[30m",
+	"red":            "\x1b[31m",
+	"green":          "\x1b[32m",
+	"yellow":         "\x1b[33m",
+	"blue":           "\x1b[34m",
+	"magenta":        "\x1b[35m",
+	"cyan":           "\x1b[36m",
+	"white":          "\x1b[37m",
+	"bright-black":   "\x1b[90m",
+	"bright-red":     "\x1b[91m",
+	"bright-green":   "\x1b[92m",
+	"bright-yellow":  "\x1b[93m",
+	"bright-blue":    "\x1b[94m",
+	"bright-magenta": "\x1b[95m",
+	"bright-cyan":    "\x1b[96m",
+	"bright-white":   "\x1b[97m",
+	"bold":           "\x1b[1m",
+	"underline":      "\x1b[4m",
+	"reverse":        "\x1b[7m",
+	"bold-red":       "\x1b[1;31m",
+	"bold-yellow":    "\x1b[1;33m",
+}
+
+func colorNames() []string {
+	names := make([]string, 0, len(colors))
+	for k := range colors {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupColor(name string) (string, error) {
+	if c, ok := colors[strings.ToLower(name)]; ok {
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown color %q, available: %s", name, strings.Join(colorNames(), ", "))
+}
+
+// colorConfig is the configuration of the colors.
+//
+// Example:
+//
+//	{
+//	  "levels": {"error": "red", "warn": "yellow"},
+//	  "lines": [{"pattern": "timeout", "color": "magenta"}],
+//	  "highlights": [{"pattern": "user=\\w+", "color": "cyan"}],
+//	  "files": ["blue", "magenta"]
+//	}
+type colorConfig struct {
+	// Levels are the colors of the whole line by the detected level.
+	Levels map[string]string `json:"levels"`
+	// Lines are the rules to color the whole line, the first matched rule is applied.
+	// Lines take precedence over Levels.
+	Lines []colorRuleConfig `json:"lines"`
+	// Highlights are the rules to color the matched parts of the line.
+	Highlights []colorRuleConfig `json:"highlights"`
+	// Files are the colors of the file names in the headers of multiple files.
+	Files []string `json:"files"`
+}
+
+type colorRuleConfig struct {
+	Pattern string `json:"pattern"`
+	Color   string `json:"color"`
+}
+
+func newDefaultColorConfig() *colorConfig {
+	return &colorConfig{
+		Levels: map[string]string{
+			"fatal": "bold-red",
+			"error": "red",
+			"warn":  "yellow",
+		},
+		Files: []string{"cyan", "magenta", "blue", "green", "bright-cyan", "bright-magenta", "bright-blue", "bright-green"},
+	}
+}
+
+// loadColorConfig reads the config file.
+// The levels in the file are merged into the default levels,
+// and the other keys present in the file replace the defaults.
+func loadColorConfig(filename string) (*colorConfig, error) {
+	c := newDefaultColorConfig()
+	if filename == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid color config %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+type colorRule struct {
+	re    *regexp.Regexp
+	color string
+}
+
+func newColorRule(c colorRuleConfig) (*colorRule, error) {
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	color, err := lookupColor(c.Color)
+	if err != nil {
+		return nil, err
+	}
+	return &colorRule{
+		re:    re,
+		color: color,
+	}, nil
+}
+
+// parseHighlight parses PATTERN[=COLOR].
+// Default color is reverse.
+func parseHighlight(v string) (*colorRule, error) {
+	c := colorRuleConfig{
+		Pattern: v,
+		Color:   "reverse",
+	}
+	if i := strings.LastIndex(v, "="); i >= 0 {
+		if _, ok := colors[strings.ToLower(v[i+1:])]; ok {
+			c.Pattern = v[:i]
+			c.Color = v[i+1:]
+		}
+	}
+	return newColorRule(c)
+}
+
+// painter decorates lines with colors.
+type painter struct {
+	levels     map[level.Level]string
+	lines      []*colorRule
+	highlights []*colorRule
+	files      []string
+	fileColors map[string]string
+}
+
+func newPainter(c *colorConfig, highlights []*colorRule) (*painter, error) {
+	p := &painter{
+		levels:     map[level.Level]string{},
+		fileColors: map[string]string{},
+	}
+	for k, v := range c.Levels {
+		l, err := level.Parse(k)
+		if err != nil {
+			return nil, err
+		}
+		color, err := lookupColor(v)
+		if err != nil {
+			return nil, err
+		}
+		p.levels[l] = color
+	}
+	for _, x := range c.Lines {
+		r, err := newColorRule(x)
+		if err != nil {
+			return nil, err
+		}
+		p.lines = append(p.lines, r)
+	}
+	for _, x := range c.Highlights {
+		r, err := newColorRule(x)
+		if err != nil {
+			return nil, err
+		}
+		p.highlights = append(p.highlights, r)
+	}
+	p.highlights = append(p.highlights, highlights...)
+	for _, x := range c.Files {
+		color, err := lookupColor(x)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, color)
+	}
+	return p, nil
+}
+
+// fileName returns the colored name of the file.
+// Colors are assigned in order of appearance.
+func (p *painter) fileName(file string) string {
+	if p == nil || len(p.files) == 0 {
+		return file
+	}
+	c, ok := p.fileColors[file]
+	if !ok {
+		c = p.files[len(p.fileColors)%len(p.files)]
+		p.fileColors[file] = c
+	}
+	return c + file + colorReset
+}
+
+// lineColor returns the color of the whole line, empty if no rules matched.
+func (p *painter) lineColor(r *gotailf.Record) string {
+	if p == nil {
+		return ""
+	}
+	for _, x := range p.lines {
+		if x.re.MatchString(r.Text) {
+			return x.color
+		}
+	}
+	return p.levels[r.Level]
+}
+
+// paint colors the line.
+// base is the color of the whole line, may be empty.
+func (p *painter) paint(line, base string) string {
+	if p == nil {
+		return line
+	}
+	type span struct {
+		start, end int
+		color      string
+	}
+	var spans []span
+	for _, r := range p.highlights {
+		for _, m := range r.re.FindAllStringIndex(line, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			overlapped := false
+			for _, s := range spans {
+				if m[0] < s.end && s.start < m[1] {
+					overlapped = true
+					break
+				}
+			}
+			if !overlapped {
+				spans = append(spans, span{start: m[0], end: m[1], color: r.color})
+			}
+		}
+	}
+	if base == "" && len(spans) == 0 {
+		return line
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var (
+		b   strings.Builder
+		pos int
+	)
+	b.WriteString(base)
+	for _, s := range spans {
+		b.WriteString(line[pos:s.start])
+		b.WriteString(s.color)
+		b.WriteString(line[s.start:s.end])
+		b.WriteString(colorReset)
+		b.WriteString(base)
+		pos = s.end
+	}
+	b.WriteString(line[pos:])
+	b.WriteString(colorReset)
+	return b.String()
+}
+
+// useColor decides whether colors are enabled.
+// mode is one of auto, always and never.
+// auto enables colors when the stdout is a terminal and NO_COLOR is not set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		return term.IsTerminal(int(os.Stdout.Fd())), nil
+	default:
+		return false, fmt.Errorf("unknown color mode: %s", mode)
+	}
+}
+
+// highlightFlag is a repeatable flag of the highlight rules.
+type highlightFlag []*colorRule
+
+func (f *highlightFlag) String() string { return "" }
+
+func (f *highlightFlag) Set(v string) error {
+	r, err := parseHighlight(v)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
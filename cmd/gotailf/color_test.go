@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHighlight(t *testing.T) {
+	for _, tc := range []struct {
+		title   string
+		v       string
+		pattern string
+		color   string
+		err     bool
+	}{
+		{title: "default color", v: "error", pattern: "error", color: colors["reverse"]},
+		{title: "color", v: "error=red", pattern: "error", color: colors["red"]},
+		{title: "color ignores case", v: "error=RED", pattern: "error", color: colors["red"]},
+		{title: "not color", v: "user=admin", pattern: "user=admin", color: colors["reverse"]},
+		{title: "last equal", v: "a=b=cyan", pattern: "a=b", color: colors["cyan"]},
+		{title: "invalid pattern", v: "(=red", err: true},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := parseHighlight(tc.v)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.pattern, got.re.String())
+			assert.Equal(t, tc.color, got.color)
+		})
+	}
+}
+
+func TestPaint(t *testing.T) {
+	newRule := func(pattern, color string) *colorRule {
+		return &colorRule{re: regexp.MustCompile(pattern), color: color}
+	}
+	var (
+		red    = colors["red"]
+		cyan   = colors["cyan"]
+		yellow = colors["yellow"]
+	)
+	for _, tc := range []struct {
+		title      string
+		highlights []*colorRule
+		line       string
+		base       string
+		want       string
+	}{
+		{
+			title: "no colors",
+			line:  "plain",
+			want:  "plain",
+		},
+		{
+			title: "base",
+			line:  "boom",
+			base:  red,
+			want:  red + "boom" + colorReset,
+		},
+		{
+			title:      "highlights",
+			highlights: []*colorRule{newRule("b+", cyan)},
+			line:       "abbcb",
+			want:       "a" + cyan + "bb" + colorReset + "c" + cyan + "b" + colorReset + colorReset,
+		},
+		{
+			title:      "highlight restores base",
+			highlights: []*colorRule{newRule("user", cyan)},
+			line:       "bad user x",
+			base:       red,
+			want:       red + "bad " + cyan + "user" + colorReset + red + " x" + colorReset,
+		},
+		{
+			title: "overlapped highlights, the former wins",
+			highlights: []*colorRule{
+				newRule("cd", cyan),
+				newRule("bcd|de", yellow),
+				newRule("a", red),
+			},
+			line: "abcde",
+			// bcd is dropped, then no matches of de are left
+			want: red + "a" + colorReset + "b" + cyan + "cd" + colorReset + "e" + colorReset,
+		},
+		{
+			title:      "empty matches",
+			highlights: []*colorRule{newRule("x*", cyan)},
+			line:       "ab",
+			want:       "ab",
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			p, err := newPainter(&colorConfig{}, tc.highlights)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, p.paint(tc.line, tc.base))
+		})
+	}
+
+	t.Run("no painter", func(t *testing.T) {
+		var p *painter
+		assert.Equal(t, "line", p.paint("line", ""))
+		assert.Equal(t, "", p.lineColor(&gotailf.Record{Text: "line", Level: level.Error}))
+		assert.Equal(t, "a.log", p.fileName("a.log"))
+	})
+}
+
+func TestUseColorDevNull(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() {
+		os.Stdout = stdout
+	}()
+	// a character device but not a terminal
+	got, err := useColor("auto")
+	assert.Nil(t, err)
+	assert.False(t, got)
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	for _, tc := range []struct {
+		mode string
+		want bool
+		err  bool
+	}{
+		{mode: "auto", want: false},
+		{mode: "always", want: true},
+		{mode: "never", want: false},
+		{mode: "sometimes", err: true},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			got, err := useColor(tc.mode)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLoadColorConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("default", func(t *testing.T) {
+		got, err := loadColorConfig("")
+		assert.Nil(t, err)
+		assert.Equal(t, newDefaultColorConfig(), got)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		got, err := loadColorConfig(write("merge.json", `{
+  "levels": {"error": "magenta", "info": "green"},
+  "lines": [{"pattern": "timeout", "color": "blue"}],
+  "files": ["red"]
+}`))
+		assert.Nil(t, err)
+		assert.Equal(t, &colorConfig{
+			Levels: map[string]string{
+				"fatal": "bold-red",
+				"error": "magenta",
+				"warn":  "yellow",
+				"info":  "green",
+			},
+			Lines: []colorRuleConfig{{Pattern: "timeout", Color: "blue"}},
+			Files: []string{"red"},
+		}, got)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := loadColorConfig(write("invalid.json", `{"levels":`))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := loadColorConfig(filepath.Join(dir, "not-found.json"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("painter", func(t *testing.T) {
+		c, err := loadColorConfig(write("painter.json", `{"lines": [{"pattern": "timeout", "color": "blue"}]}`))
+		if !assert.Nil(t, err) {
+			return
+		}
+		p, err := newPainter(c, nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, colors["blue"], p.lineColor(&gotailf.Record{Text: "timeout", Level: level.Error}))
+		assert.Equal(t, colors["red"], p.lineColor(&gotailf.Record{Text: "boom", Level: level.Error}))
+		assert.Equal(t, "", p.lineColor(&gotailf.Record{Text: "ok", Level: level.Info}))
+	})
+
+	t.Run("unknown color", func(t *testing.T) {
+		c, err := loadColorConfig(write("unknown.json", `{"levels": {"info": "pink"}}`))
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, err = newPainter(c, nil)
+		assert.NotNil(t, err)
+	})
+}
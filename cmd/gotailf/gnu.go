@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gnuArgs rewrites the arguments of GNU style into the ones the flag set parses:
+// the flags after the files, the clustered short flags like -fn5,
+// and the obsolete -NUM as -n NUM.
+// The obsolete +NUM as -n +NUM is accepted only as the first argument followed by a file at most.
+// The files follow "--".
+func gnuArgs(fs *flag.FlagSet, args []string) []string {
+	if len(args) > 0 && len(args) <= 2 && isObsoleteCount(args[0]) {
+		args = append([]string{"-n", args[0]}, args[1:]...)
+	}
+	var flags, files []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			files = append(files, args[i+1:]...)
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			files = append(files, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := fs.Lookup(name); f != nil || strings.HasPrefix(arg, "--") {
+			flags = append(flags, arg)
+			if f != nil && !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+			continue
+		}
+		cluster, ok := expandShortFlags(fs, arg[1:])
+		if !ok {
+			// leave it to the flag set to report
+			flags = append(flags, arg)
+			continue
+		}
+		if last := cluster[len(cluster)-1]; last.value == nil {
+			if f := fs.Lookup(last.name); !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				last.value = &args[i]
+			}
+		}
+		for _, x := range cluster {
+			if x.value != nil {
+				flags = append(flags, "-"+x.name, *x.value)
+			} else {
+				flags = append(flags, "-"+x.name)
+			}
+		}
+	}
+	return append(append(flags, "--"), files...)
+}
+
+type shortFlag struct {
+	name string
+	// nil if the value is not in the cluster.
+	value *string
+}
+
+// expandShortFlags splits the cluster of the short flags without the leading dash.
+// The flag with a value takes the rest of the cluster.
+func expandShortFlags(fs *flag.FlagSet, cluster string) ([]*shortFlag, bool) {
+	var flags []*shortFlag
+	if n := len(cluster) - len(strings.TrimLeft(cluster, "0123456789")); n > 0 {
+		value := cluster[:n]
+		flags = append(flags, &shortFlag{name: "n", value: &value})
+		cluster = cluster[n:]
+	}
+	for i := 0; i < len(cluster); i++ {
+		name := cluster[i : i+1]
+		f := fs.Lookup(name)
+		if f == nil {
+			return nil, false
+		}
+		if isBoolFlag(f) || i+1 == len(cluster) {
+			flags = append(flags, &shortFlag{name: name})
+			continue
+		}
+		value := cluster[i+1:]
+		flags = append(flags, &shortFlag{name: name, value: &value})
+		break
+	}
+	return flags, len(flags) > 0
+}
+
+// isObsoleteCount returns true if arg is +NUM.
+func isObsoleteCount(arg string) bool {
+	if !strings.HasPrefix(arg, "+") {
+		return false
+	}
+	_, err := parseCount(arg[1:])
+	return err == nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// countFlag is a flag of [+]NUM of -n and -c.
+type countFlag struct {
+	set bool
+	// fromStart is true if +NUM, counts from the start of the file.
+	fromStart bool
+	n         int64
+}
+
+func (f *countFlag) String() string {
+	if f == nil || !f.set {
+		return ""
+	}
+	if f.fromStart {
+		return fmt.Sprintf("+%d", f.n)
+	}
+	return strconv.FormatInt(f.n, 10)
+}
+
+func (f *countFlag) Set(v string) error {
+	var fromStart bool
+	switch {
+	case strings.HasPrefix(v, "+"):
+		fromStart = true
+		v = v[1:]
+	case strings.HasPrefix(v, "-"):
+		v = v[1:]
+	}
+	n, err := parseCount(v)
+	if err != nil {
+		return err
+	}
+	f.set, f.fromStart, f.n = true, fromStart, n
+	return nil
+}
+
+// countSuffixes are the multipliers of the counts.
+var countSuffixes = map[string]int64{
+	"b":   512,
+	"kB":  1000,
+	"K":   1 << 10,
+	"KiB": 1 << 10,
+	"MB":  1000 * 1000,
+	"M":   1 << 20,
+	"MiB": 1 << 20,
+	"GB":  1000 * 1000 * 1000,
+	"G":   1 << 30,
+	"GiB": 1 << 30,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"T":   1 << 40,
+	"TiB": 1 << 40,
+	"PB":  1000 * 1000 * 1000 * 1000 * 1000,
+	"P":   1 << 50,
+	"PiB": 1 << 50,
+	"EB":  1000 * 1000 * 1000 * 1000 * 1000 * 1000,
+	"E":   1 << 60,
+	"EiB": 1 << 60,
+}
+
+// parseCount parses NUM with an optional suffix of the multiplier, e.g. 10K.
+func parseCount(v string) (int64, error) {
+	i := len(v) - len(strings.TrimLeft(v, "0123456789"))
+	if i == 0 {
+		return 0, fmt.Errorf("invalid number: %q", v)
+	}
+	n, err := strconv.ParseInt(v[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number: %q", v)
+	}
+	if suffix := v[i:]; suffix != "" {
+		m, ok := countSuffixes[suffix]
+		if !ok {
+			return 0, fmt.Errorf("invalid suffix: %q", v)
+		}
+		if n > math.MaxInt64/m {
+			return 0, fmt.Errorf("number too large: %q", v)
+		}
+		n *= m
+	}
+	return n, nil
+}
+
+// followFlag is a flag of --follow[={name|descriptor}].
+type followFlag string
+
+const (
+	followNone       followFlag = ""
+	followName       followFlag = "name"
+	followDescriptor followFlag = "descriptor"
+)
+
+func (f *followFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+func (f *followFlag) Set(v string) error {
+	switch v {
+	case "true", "descriptor":
+		*f = followDescriptor
+	case "name":
+		*f = followName
+	case "false":
+		*f = followNone
+	default:
+		return fmt.Errorf("invalid follow mode: %q, expected name or descriptor", v)
+	}
+	return nil
+}
+
+func (*followFlag) IsBoolFlag() bool { return true }
+
+// secondsFlag is a flag of the positive seconds like 0.5, or the positive Go duration like 500ms.
+type secondsFlag time.Duration
+
+func (f *secondsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return time.Duration(*f).String()
+}
+
+func (f *secondsFlag) Set(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		x, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsInf(x, 0) || math.IsNaN(x) || x > float64(math.MaxInt64)/float64(time.Second) {
+			return fmt.Errorf("invalid interval: %q", v)
+		}
+		d = time.Duration(x * float64(time.Second))
+	}
+	if d <= 0 {
+		return fmt.Errorf("interval should be positive: %q", v)
+	}
+	*f = secondsFlag(d)
+	return nil
+}
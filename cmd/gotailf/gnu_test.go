@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGNUArgs(t *testing.T) {
+	newFlagSet := func() *flag.FlagSet {
+		var (
+			fs     = flag.NewFlagSet("gotailf", flag.ContinueOnError)
+			count  countFlag
+			follow followFlag
+		)
+		fs.Var(&count, "n", "")
+		fs.Var(&follow, "f", "")
+		fs.Var(&follow, "follow", "")
+		fs.Bool("F", false, "")
+		fs.Bool("q", false, "")
+		return fs
+	}
+
+	for _, tc := range []struct {
+		title string
+		args  []string
+		want  []string
+	}{
+		{
+			title: "files",
+			args:  []string{"a", "b"},
+			want:  []string{"--", "a", "b"},
+		},
+		{
+			title: "flags after files",
+			args:  []string{"a", "-n", "5", "b", "-F"},
+			want:  []string{"-n", "5", "-F", "--", "a", "b"},
+		},
+		{
+			title: "cluster",
+			args:  []string{"-qfn5", "a"},
+			want:  []string{"-q", "-f", "-n", "5", "--", "a"},
+		},
+		{
+			title: "obsolete minus",
+			args:  []string{"-20", "a"},
+			want:  []string{"-n", "20", "--", "a"},
+		},
+		{
+			title: "obsolete plus",
+			args:  []string{"+4", "a"},
+			want:  []string{"-n", "+4", "--", "a"},
+		},
+		{
+			title: "obsolete plus only",
+			args:  []string{"+4"},
+			want:  []string{"-n", "+4", "--"},
+		},
+		{
+			title: "plus with multiple files is a file",
+			args:  []string{"+4", "a", "b"},
+			want:  []string{"--", "+4", "a", "b"},
+		},
+		{
+			title: "plus not first is a file",
+			args:  []string{"a", "+4"},
+			want:  []string{"--", "a", "+4"},
+		},
+		{
+			title: "plus not number is a file",
+			args:  []string{"+x", "a"},
+			want:  []string{"--", "+x", "a"},
+		},
+		{
+			title: "double dash",
+			args:  []string{"-n", "1", "--", "-f"},
+			want:  []string{"-n", "1", "--", "-f"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, gnuArgs(newFlagSet(), tc.args))
+		})
+	}
+}
+
+func TestSecondsFlag(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{value: "0.5", want: "500ms"},
+		{value: "2s", want: "2s"},
+		{value: "-1", err: true},
+		{value: "0", err: true},
+		{value: "0s", err: true},
+		{value: "-1s", err: true},
+		{value: "1e-10", err: true},
+		{value: "1e100", err: true},
+		{value: "nan", err: true},
+		{value: "inf", err: true},
+		{value: "x", err: true},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			var f secondsFlag
+			err := f.Set(tc.value)
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, f.String())
+		})
+	}
+}
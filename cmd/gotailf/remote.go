@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/remote"
+)
+
+// parseListenAddress parses tcp://HOST:PORT, unix://PATH or HOST:PORT.
+func parseListenAddress(v string) (string, string) {
+	if network, address, ok := strings.Cut(v, "://"); ok {
+		return network, address
+	}
+	return "tcp", v
+}
+
+// parseServeFiles parses FILE or NAME=PATH into the allowlist.
+// The name of FILE is the base name.
+func parseServeFiles(args []string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, x := range args {
+		name, path, ok := strings.Cut(x, "=")
+		if !ok {
+			name, path = filepath.Base(x), x
+		}
+		if name == "" || path == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid file: %s", x)
+		}
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("duplicated name: %s", name)
+		}
+		files[name] = path
+	}
+	return files, nil
+}
+
+func serveMain(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage of gotailf serve:
+  gotailf serve [flags] FILE|NAME=PATH...
+
+Serve the tails of the files to gotailf connect.
+Clients can request only the given files, by NAME or the base name of FILE.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+	var (
+		listen    = fs.String("listen", "tcp://127.0.0.1:7070", "address to listen: tcp://HOST:PORT or unix://PATH")
+		heartbeat = fs.Duration("heartbeat", 10*time.Second, "interval of the heartbeats while no lines")
+	)
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *heartbeat <= 0 {
+		fmt.Fprintln(os.Stderr, "-heartbeat should be positive")
+		os.Exit(2)
+	}
+	files, err := parseServeFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ln, err := net.Listen(parseListenAddress(*listen))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	server := remote.NewServer(files,
+		remote.WithHeartbeat(*heartbeat),
+		remote.WithTailerOptions(gotailf.WithFlushInterval(200*time.Millisecond)),
+	)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := server.Serve(ctx, ln); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func loadToken(filename string) (remote.Token, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return remote.Token{}, nil
+	}
+	if err != nil {
+		return remote.Token{}, err
+	}
+	return remote.ParseToken(string(b))
+}
+
+// saveToken replaces the file with the token.
+func saveToken(filename string, token remote.Token) error {
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, []byte(token.String()+"\n"), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func connectMain(args []string) {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage of gotailf connect:
+  gotailf connect [flags] ADDRESS NAME
+  gotailf connect -list ADDRESS
+
+Follow the file NAME served by gotailf serve at ADDRESS, tcp://HOST:PORT, unix://PATH or HOST:PORT,
+and write it into the stdout. Reconnects and resumes after the disconnections.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+	var (
+		stateFile   = fs.String("state", "", "file to save the resume token, resumes from it at start")
+		fromStart   = fs.Bool("from-start", false, "start from the origin of the file without the resume token")
+		list        = fs.Bool("list", false, "list the names of the files")
+		readTimeout = fs.Duration("read-timeout", 30*time.Second, "reconnect when no data including heartbeats for the duration")
+	)
+	_ = fs.Parse(args)
+	if (*list && fs.NArg() != 1) || (!*list && fs.NArg() != 2) {
+		fs.Usage()
+		os.Exit(2)
+	}
+	network, address := parseListenAddress(fs.Arg(0))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *list {
+		names, err := remote.List(ctx, network, address)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	opts := []remote.ClientOption{
+		remote.WithReadTimeout(*readTimeout),
+		remote.WithOnRetry(func(err error, after time.Duration) {
+			fmt.Fprintf(os.Stderr, "disconnected: %v, reconnect after %s\n", err, after)
+		}),
+	}
+	if *fromStart {
+		opts = append(opts, remote.WithOffset(0))
+	}
+	if *stateFile != "" {
+		token, err := loadToken(*stateFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts = append(opts, remote.WithToken(token))
+	}
+	client := remote.NewClient(network, address, fs.Arg(1), opts...)
+
+	save := func() {
+		if *stateFile == "" {
+			return
+		}
+		if token := client.Token(); token.Identity != "" {
+			if err := saveToken(*stateFile, token); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	var (
+		runCtx, cancel = context.WithCancel(ctx)
+		saved          = make(chan struct{})
+	)
+	go func() {
+		defer close(saved)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				save()
+				return
+			case <-ticker.C:
+				save()
+			}
+		}
+	}()
+	err := client.Run(runCtx, func(line string) error {
+		fmt.Println(line)
+		return nil
+	})
+	cancel()
+	<-saved
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
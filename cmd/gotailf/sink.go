@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/syslogsink"
+)
+
+// outputs delivers the records to the sinks.
+type outputs struct {
+	ds    []*delivery
+	sinks []sink.Sink
+}
+
+// delivery is the delivery to a sink.
+type delivery struct {
+	c chan *gotailf.Record
+	// done is closed when the delivery ends.
+	done chan struct{}
+	err  error
+}
+
+// add starts the delivery to the sink.
+// The delivery is not canceled by the interrupt to flush the rest of the records.
+func (o *outputs) add(s sink.Sink, opts ...sink.Option) {
+	d := &delivery{
+		c:    make(chan *gotailf.Record, 1000),
+		done: make(chan struct{}),
+	}
+	o.ds = append(o.ds, d)
+	o.sinks = append(o.sinks, s)
+	go func() {
+		d.err = sink.New(s, opts...).Run(context.Background(), d.c)
+		close(d.done)
+	}()
+}
+
+func (o *outputs) enabled() bool { return len(o.ds) > 0 }
+
+// send passes the record to the deliveries.
+// Returns false if any delivery has ended by an error, reported by close.
+func (o *outputs) send(r *gotailf.Record) bool {
+	for _, d := range o.ds {
+		select {
+		case d.c <- r:
+		case <-d.done:
+			return false
+		}
+	}
+	return true
+}
+
+// close waits for the deliveries and closes the sinks.
+func (o *outputs) close() []error {
+	for _, d := range o.ds {
+		close(d.c)
+	}
+	var errs []error
+	for _, d := range o.ds {
+		<-d.done
+		if d.err != nil {
+			errs = append(errs, d.err)
+		}
+	}
+	for _, s := range o.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// parseHeader parses "KEY: VALUE".
+func parseHeader(v string) (string, string, error) {
+	key, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid header: %s", v)
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), nil
+}
+
+// parseSyslogAddress parses NETWORK://ADDRESS of -syslog-out.
+// "local" is the local syslog, and ADDRESS without NETWORK is udp.
+func parseSyslogAddress(v string) (string, string) {
+	if v == "local" {
+		return "", ""
+	}
+	if network, address, ok := strings.Cut(v, "://"); ok {
+		return network, address
+	}
+	return "udp", v
+}
+
+func newSyslogSink(addr, format, facility, severity, appName, hostname string) (sink.Sink, error) {
+	f, err := syslogsink.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := syslogsink.ParseFacility(facility)
+	if err != nil {
+		return nil, err
+	}
+	opts := []syslogsink.Option{
+		syslogsink.WithFormat(f),
+		syslogsink.WithFacility(fc),
+		syslogsink.WithAppName(appName),
+	}
+	if severity == "level" {
+		opts = append(opts, syslogsink.WithSeverityFromLevel(true))
+	} else {
+		sv, err := syslogsink.ParseSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, syslogsink.WithSeverity(sv))
+	}
+	if hostname != "" {
+		opts = append(opts, syslogsink.WithHostname(hostname))
+	}
+	network, address := parseSyslogAddress(addr)
+	return syslogsink.New(network, address, opts...)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// rejectSink rejects all the records permanently.
+type rejectSink struct{}
+
+func (rejectSink) Write(_ context.Context, _ []*gotailf.Record) error {
+	return sink.Permanent(errors.New("rejected"))
+}
+
+func (rejectSink) Close() error { return nil }
+
+func TestOutputsFailedSink(t *testing.T) {
+	var outs outputs
+	outs.add(rejectSink{}, sink.WithBatchMaxCount(10))
+
+	sent := make(chan int)
+	go func() {
+		var n int
+		for n < 5000 && outs.send(&gotailf.Record{Text: "line"}) {
+			n++
+		}
+		sent <- n
+	}()
+	select {
+	case n := <-sent:
+		assert.Less(t, n, 5000)
+	case <-time.After(5 * time.Second):
+		t.Fatal("send blocked after the delivery failed")
+	}
+	errs := outs.close()
+	if assert.Equal(t, 1, len(errs)) {
+		assert.Contains(t, errs[0].Error(), "rejected")
+	}
+}
+
+func TestConsumeFailedSink(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	filenames := []string{dir.Path("a"), dir.Path("b")}
+	for _, filename := range filenames {
+		assert.Nil(t, os.WriteFile(filename, []byte(strings.Repeat("line\n", 5000)), 0o600))
+	}
+	spec := &tailSpec{
+		lines:  &countFlag{set: true, fromStart: true, n: 1},
+		bytes:  &countFlag{},
+		follow: true,
+		delim:  '\n',
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	streams, errs := tailFiles(ctx, filenames, false, func(*gotailf.Record) {},
+		func(filename string) (gotailf.Tailer, error) {
+			return spec.open(filename, gotailf.WithFlushInterval(10*time.Millisecond))
+		})
+
+	var outs outputs
+	outs.add(rejectSink{}, sink.WithBatchMaxCount(10))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consume(gotailf.FanIn(streams...), cancel, outs.send)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("consume blocked after the delivery failed")
+	}
+	assert.Equal(t, 1, len(outs.close()))
+	assert.Equal(t, 0, len(errs()))
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal"
+)
+
+// tailFiles tails the files by the tailers of open and yields the records of each file applied prepare.
+// The files are tailed one after another into a stream if sequential, otherwise concurrently into the streams of the files.
+// The files failed to be opened are skipped.
+// The records are discarded after ctx ends, until the tailers end.
+// The returned function reports the errors of the tailers except the end of ctx, waits for the tailers to end.
+func tailFiles(ctx context.Context, filenames []string, sequential bool, prepare func(*gotailf.Record), open func(filename string) (gotailf.Tailer, error)) ([]<-chan *gotailf.Record, func() []error) {
+	var (
+		streams  []<-chan *gotailf.Record
+		tailers  []gotailf.Tailer
+		openErrs []error
+		wg       sync.WaitGroup
+	)
+	forward := func(t gotailf.Tailer, resultC chan<- *gotailf.Record) {
+		for r := range t.Records(ctx) {
+			if ctx.Err() != nil {
+				continue
+			}
+			prepare(r)
+			select {
+			case <-ctx.Done():
+			case resultC <- r:
+			}
+		}
+	}
+	if sequential {
+		// unbuffered, the buffer of the tailer applies the overflow policy
+		resultC := make(chan *gotailf.Record)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(resultC)
+			for _, filename := range filenames {
+				if ctx.Err() != nil {
+					return
+				}
+				t, err := open(filename)
+				if err != nil {
+					openErrs = append(openErrs, err)
+					continue
+				}
+				tailers = append(tailers, t)
+				forward(t, resultC)
+			}
+		}()
+		streams = append(streams, resultC)
+	} else {
+		for _, filename := range filenames {
+			t, err := open(filename)
+			if err != nil {
+				openErrs = append(openErrs, err)
+				continue
+			}
+			tailers = append(tailers, t)
+			// unbuffered, the buffer of the tailer applies the overflow policy
+			resultC := make(chan *gotailf.Record)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer close(resultC)
+				forward(t, resultC)
+			}()
+			streams = append(streams, resultC)
+		}
+	}
+	return streams, func() []error {
+		wg.Wait()
+		errs := openErrs
+		for _, t := range tailers {
+			// the end of ctx is not an error of the tailer
+			if err := t.Err(); err != nil && !errors.Is(err, ctx.Err()) {
+				errs = append(errs, fmt.Errorf("%s: %w", t.Filename(), err))
+			}
+		}
+		return errs
+	}
+}
+
+// consume calls f with the records until f returns false or recordC is closed.
+// When f returns false, cancel is called and the rest of the records are discarded until recordC is closed.
+func consume(recordC <-chan *gotailf.Record, cancel func(), f func(*gotailf.Record) bool) {
+	for r := range recordC {
+		if f(r) {
+			continue
+		}
+		cancel()
+		for range recordC {
+		}
+		return
+	}
+}
+
+// tailSpec decides where and how long the files are tailed.
+type tailSpec struct {
+	// lines is -n, used unless bytes is set.
+	lines *countFlag
+	// bytes is -c.
+	bytes *countFlag
+	// follow is false to stop at the end of the files.
+	follow bool
+	// retry is true to wait for the files missing at the start.
+	retry bool
+	delim byte
+}
+
+// startOffset returns the offset of the file to start tailing from.
+func (s *tailSpec) startOffset(filename string) (int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case s.bytes.set && s.bytes.fromStart:
+		return max(s.bytes.n-1, 0), nil
+	case s.bytes.set:
+		return max(stat.Size()-s.bytes.n, 0), nil
+	case s.lines.fromStart:
+		return internal.LinesOffset(f, s.lines.n-1, s.delim)
+	default:
+		return internal.LastLinesOffset(f, stat.Size(), s.lines.n, s.delim)
+	}
+}
+
+// open returns a new Tailer of the file.
+// The file must be exist unless following with retry.
+func (s *tailSpec) open(filename string, opts ...gotailf.Option) (gotailf.Tailer, error) {
+	offset, err := s.startOffset(filename)
+	switch {
+	case err == nil:
+	case s.follow && s.retry && errors.Is(err, os.ErrNotExist):
+		// the whole file when it appears
+		offset = 0
+	default:
+		return nil, err
+	}
+	opts = append(opts,
+		gotailf.WithDelimiter(s.delim),
+		gotailf.WithOffset(offset),
+	)
+	if !s.follow {
+		return gotailf.NewTailer(filename, append(opts, gotailf.WithFollow(false))...)
+	}
+	return gotailf.NewContinueTailer(filename, opts...), nil
+}
+
+// terminated returns true if the line of the record ends with the delimiter in the file.
+// The last line of the file may not end with the delimiter.
+func terminated(r *gotailf.Record) bool {
+	return r.Skipped > 0 || r.End-r.Offset > int64(len(r.Text))
+}
+
+// watchPID calls cancel after the process of the pid ends.
+func watchPID(ctx context.Context, cancel context.CancelFunc, pid int, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if processAlive(pid) {
+				continue
+			}
+			// the last data written by the process are read by the next checks of the files
+			select {
+			case <-ctx.Done():
+			case <-time.After(2 * interval):
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailFiles(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	assert.Nil(t, os.WriteFile(dir.Path("a"), []byte("a1\na2\na3\n"), 0o600))
+	assert.Nil(t, os.WriteFile(dir.Path("b"), []byte("b1\nb2\nb3\n"), 0o600))
+	filenames := []string{dir.Path("a"), dir.Path("missing"), dir.Path("b")}
+
+	for _, tc := range []struct {
+		title      string
+		follow     bool
+		sequential bool
+		streams    int
+		want       []string
+	}{
+		{
+			title:      "sequential",
+			sequential: true,
+			streams:    1,
+			// in order of the files
+			want: []string{"a2", "a3", "b2", "b3"},
+		},
+		{
+			title:   "concurrent",
+			streams: 2,
+			want:    []string{"a2", "a3", "b2", "b3"},
+		},
+		{
+			title:   "follow",
+			follow:  true,
+			streams: 2,
+			want:    []string{"a2", "a3", "b2", "b3"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			spec := &tailSpec{
+				lines:  &countFlag{set: true, n: 2},
+				bytes:  &countFlag{},
+				follow: tc.follow,
+				delim:  '\n',
+			}
+			ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+			defer cancel()
+			streams, errs := tailFiles(ctx, filenames, tc.sequential, func(*gotailf.Record) {},
+				func(filename string) (gotailf.Tailer, error) {
+					return spec.open(filename, gotailf.WithFlushInterval(10*time.Millisecond))
+				})
+			assert.Equal(t, tc.streams, len(streams))
+			got := []string{}
+			for r := range gotailf.FanIn(streams...) {
+				got = append(got, r.Text)
+			}
+			if !tc.sequential {
+				sort.Strings(got)
+			}
+			assert.Equal(t, tc.want, got)
+			// the missing file without retry
+			if es := errs(); assert.Equal(t, 1, len(es)) {
+				assert.ErrorIs(t, es[0], os.ErrNotExist)
+			}
+		})
+	}
+
+	t.Run("no files", func(t *testing.T) {
+		spec := &tailSpec{
+			lines:  &countFlag{set: true, n: 10},
+			bytes:  &countFlag{},
+			follow: true,
+			delim:  '\n',
+		}
+		streams, errs := tailFiles(context.TODO(), []string{dir.Path("missing")}, false, func(*gotailf.Record) {},
+			func(filename string) (gotailf.Tailer, error) {
+				return spec.open(filename)
+			})
+		assert.Equal(t, 0, len(streams))
+		_, ok := <-gotailf.FanIn(streams...)
+		assert.False(t, ok)
+		assert.Equal(t, 1, len(errs()))
+	})
+}
+
+func TestTerminated(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		r     *gotailf.Record
+		want  bool
+	}{
+		{
+			title: "newline",
+			r:     &gotailf.Record{Offset: 2, End: 4, Text: "x"},
+			want:  true,
+		},
+		{
+			title: "crlf",
+			r:     &gotailf.Record{Offset: 2, End: 5, Text: "x"},
+			want:  true,
+		},
+		{
+			title: "no newline",
+			r:     &gotailf.Record{Offset: 2, End: 3, Text: "y"},
+		},
+		{
+			title: "skipped marker",
+			r:     &gotailf.Record{Offset: 2, End: 2, Text: "3 lines skipped", Skipped: 3},
+			want:  true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, terminated(tc.r))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/tui"
+)
+
+func tuiMain(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage of gotailf tui:
+  gotailf tui [flags] FILE...
+
+Follow the files in the interactive terminal UI.
+Press q to quit; see the keys in the status line.
+
+NO_COLOR disables colors.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+	var (
+		lines       = fs.Int64("n", 100, "show the last lines of the files first")
+		maxLines    = fs.Int("max-lines", 10000, "max number of the lines kept per file")
+		parseFormat = fs.String("parse", "", "parse lines as auto, json, logfmt or kv to detect the levels from the fields")
+	)
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	prepare := (*gotailf.Record).DetectLevel
+	if *parseFormat != "" {
+		f, err := parse.ParseFormat(*parseFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		prepare = func(r *gotailf.Record) {
+			r.Parse(f)
+			r.DetectLevel()
+		}
+	}
+	tailerOpts := []gotailf.Option{
+		gotailf.WithFlushInterval(200 * time.Millisecond),
+		gotailf.WithTailFromOriginWhenGone(true),
+		gotailf.WithTailFromOriginWhenTruncated(true),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := tui.Run(ctx, fs.Args(),
+		tui.WithMaxLines(*maxLines),
+		tui.WithBackfill(*lines),
+		tui.WithColor(os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"),
+		tui.WithPrepare(prepare),
+		tui.WithTailerOptions(tailerOpts...),
+	); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/web"
+)
+
+func httpMain(args []string) {
+	fs := flag.NewFlagSet("http", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, `Usage of gotailf http:
+  gotailf http [flags] FILE|NAME=PATH...
+
+Serve the tails of the files over Server-Sent Events and WebSocket, with a page to view them in a browser.
+Clients can request only the given files, by NAME or the base name of FILE.
+
+Flags:
+`)
+		fs.PrintDefaults()
+	}
+	var (
+		listen      = fs.String("listen", "127.0.0.1:8080", "address to listen")
+		maxBackfill = fs.Int64("max-backfill", 1000, "max number of the backfill lines")
+	)
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	files, err := parseServeFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := &http.Server{
+		Addr: *listen,
+		Handler: web.NewHandler(files,
+			web.WithMaxBackfill(*maxBackfill),
+			web.WithTailerOptions(gotailf.WithFlushInterval(200*time.Millisecond)),
+		),
+		ReadHeaderTimeout: 10 * time.Second,
+		// end the streams on interrupt
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
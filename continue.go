@@ -2,6 +2,7 @@ package gotailf
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/berquerant/gotailf/internal"
 )
@@ -9,12 +10,15 @@ import (
 type continueTailer struct {
 	filename string
 	config   *Config
-	tailer   Tailer
-	err      error
+	tailer   *tailer
+	// the number of the lines dropped by the previous tailers,
+	// and by Config.OverflowPolicy of Tail() and Records().
+	dropped atomic.Int64
+	err     error
 }
 
 // NewContinueTailer returns a new continue Tailer.
-// When the target file is removed, waits that the file with the same name is created.
+// When the target file is removed, waits that the file with the same name is created, unless Config.StopWhenGone.
 // When the target file is truncated, continues tailing the file.
 func NewContinueTailer(filename string, opts ...Option) Tailer {
 	config := newDefaultConfig()
@@ -29,6 +33,12 @@ func NewContinueTailer(filename string, opts ...Option) Tailer {
 
 func (s *continueTailer) Filename() string { return s.filename }
 func (s *continueTailer) Pos() int64       { return s.tailer.Pos() }
+func (s *continueTailer) Dropped() int64 {
+	if s.tailer == nil {
+		return s.dropped.Load()
+	}
+	return s.dropped.Load() + s.tailer.Dropped()
+}
 func (s *continueTailer) Err() error {
 	if s.err != nil {
 		return s.err
@@ -42,7 +52,7 @@ func (s *continueTailer) open(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	tailer, err := newTailerFromFile(f, s.config, internal.NewWatcher(s.filename, s.config.FlushInterval))
+	tailer, err := newTailerFromFile(s.filename, f, s.config, newWatcher(s.filename, f, s.config))
 	if err != nil {
 		return err
 	}
@@ -50,16 +60,65 @@ func (s *continueTailer) open(ctx context.Context) error {
 	return nil
 }
 
+// Tail sends the lines of the tailers to the channel directly,
+// so Config.OverflowPolicy applies to the returned channel.
 func (s *continueTailer) Tail(ctx context.Context) <-chan string {
-	resultC := make(chan string, s.config.BufferSize)
+	var (
+		resultC = make(chan string, s.config.BufferSize)
+		o       = newLineOverflow(s.config.OverflowPolicy, resultC, &s.dropped)
+	)
 	go func() {
-		s.loop(ctx, resultC)
+		s.loop(ctx, func(t *tailer) {
+			t.sendLines(ctx, o)
+		})
 		close(resultC)
 	}()
 	return resultC
 }
 
-func (s *continueTailer) loop(ctx context.Context, resultC chan<- string) {
+// Records sends the records of the tailers to the channel directly as Tail().
+func (s *continueTailer) Records(ctx context.Context) <-chan *Record {
+	var (
+		resultC = make(chan *Record, s.config.BufferSize)
+		o       = newOverflow(s.config.OverflowPolicy, resultC, &s.dropped, skippedRecord)
+	)
+	go func() {
+		s.loop(ctx, func(t *tailer) {
+			t.sendRecords(ctx, o)
+		})
+		close(resultC)
+	}()
+	return resultC
+}
+
+func (s *continueTailer) Batches(ctx context.Context) <-chan []*Record {
+	resultC := make(chan []*Record, batchBufferSize(s.config))
+	go func() {
+		s.loop(ctx, func(t *tailer) {
+			for b := range t.Batches(ctx) {
+				resultC <- b
+			}
+		})
+		close(resultC)
+	}()
+	return resultC
+}
+
+func (s *continueTailer) RawBatches(ctx context.Context) <-chan *RawBatch {
+	resultC := make(chan *RawBatch, batchBufferSize(s.config))
+	go func() {
+		s.loop(ctx, func(t *tailer) {
+			for b := range t.RawBatches(ctx) {
+				resultC <- b
+			}
+		})
+		close(resultC)
+	}()
+	return resultC
+}
+
+// loop opens the target file and consumes the tailer until the tailing cannot be continued.
+func (s *continueTailer) loop(ctx context.Context, consume func(t *tailer)) {
 	toOffset := func(isOrigin bool) int64 {
 		if isOrigin {
 			return 0
@@ -71,15 +130,20 @@ func (s *continueTailer) loop(ctx context.Context, resultC chan<- string) {
 			s.setErr(err)
 			return
 		}
-		for line := range s.tailer.Tail(ctx) {
-			resultC <- line
-		}
+		consume(s.tailer)
+		s.dropped.Add(s.tailer.Dropped())
 		switch s.tailer.Err() {
 		case ErrFileGone:
+			if s.config.StopWhenGone {
+				s.setErr(ErrFileGone)
+				return
+			}
 			s.config.Offset = toOffset(s.config.TailFromOriginWhenGone)
+			s.config.Line = 0
 			continue
 		case ErrFileTruncated:
 			s.config.Offset = toOffset(s.config.TailFromOriginWhenTruncated)
+			s.config.Line = 0
 			continue
 		default:
 			s.setErr(s.tailer.Err())
@@ -146,6 +146,30 @@ func TestContinueTailer(t *testing.T) {
 		}
 	})
 
+	t.Run("stop when gone", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprintln(f.File(), "gone")
+		f.Close(t)
+		s := gotailf.NewContinueTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+			gotailf.WithStopWhenGone(true),
+		)
+		time.AfterFunc(80*time.Millisecond, func() {
+			f.Remove(t)
+		})
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
+		got := []string{}
+		for line := range s.Tail(ctx) {
+			got = append(got, line)
+		}
+		assert.Equal(t, gotailf.ErrFileGone, s.Err())
+		assert.Equal(t, []string{"gone"}, got)
+		assert.Nil(t, ctx.Err())
+	})
+
 	t.Run("from not found", func(t *testing.T) {
 		t.Parallel()
 		var filename string
@@ -0,0 +1,420 @@
+// Package filter provides an expression language to filter records by their fields.
+//
+// An expression is evaluated against the parsed fields of a record, e.g.
+//
+//	level in ("warn", "error") && status >= 500 && path =~ "^/api"
+//
+// Operands are field names, meta identifiers ($text, $file, $offset, $line, $level and $event_time),
+// string, number, true, false and null literals,
+// exists(field) that reports whether the field exists,
+// and time("2006-01-02T15:04:05Z") that is a time literal in RFC3339.
+// Field names consist of letters, digits, '_', '.' and '-', and begin with a letter or '_'.
+//
+// Operators are ==, !=, <, <=, >, >=, =~ (regexp match), !~ (regexp not match),
+// in (list), not in (list), !, && and ||.
+//
+// Comparisons with missing fields are false.
+// When the one side of a comparison is a number or a time, the other side is converted to the same type;
+// strings are converted to times as RFC3339 and numbers are converted to times as epoch seconds.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/parse"
+)
+
+// SyntaxError is an error of an invalid expression.
+type SyntaxError struct {
+	// Pos is the 1-based position of the error in the expression.
+	Pos int
+	// Msg is the description of the error.
+	Msg string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("col %d: %s", e.Pos, e.Msg) }
+
+func syntaxErrorf(pos int, format string, v ...interface{}) error {
+	return &SyntaxError{
+		Pos: pos,
+		Msg: fmt.Sprintf(format, v...),
+	}
+}
+
+// Expr is a compiled filter expression.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses the expression.
+// Returns *SyntaxError when the expression is invalid.
+func Compile(src string) (*Expr, error) {
+	tokens, err := (&lexer{src: src}).tokens()
+	if err != nil {
+		return nil, err
+	}
+	root, err := (&parser{tokens: tokens}).parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{
+		src:  src,
+		root: root,
+	}, nil
+}
+
+// MustCompile is like Compile but panics if the expression is invalid.
+func MustCompile(src string) *Expr {
+	e, err := Compile(src)
+	if err != nil {
+		panic(fmt.Sprintf("filter: Compile(%q): %v", src, err))
+	}
+	return e
+}
+
+func (e *Expr) String() string { return e.src }
+
+// Match returns true if the record satisfies the expression.
+func (e *Expr) Match(r *gotailf.Record) bool { return isTrue(e.root.eval(r)) }
+
+type valueType int
+
+const (
+	typeAny valueType = iota
+	typeBool
+	typeString
+	typeNumber
+	typeTime
+	typeNull
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeBool:
+		return "boolean"
+	case typeString:
+		return "string"
+	case typeNumber:
+		return "number"
+	case typeTime:
+		return "time"
+	case typeNull:
+		return "null"
+	default:
+		return "any"
+	}
+}
+
+func typeOf(v interface{}) valueType {
+	switch v.(type) {
+	case bool:
+		return typeBool
+	case string:
+		return typeString
+	case float64:
+		return typeNumber
+	case time.Time:
+		return typeTime
+	case nil:
+		return typeNull
+	default:
+		return typeAny
+	}
+}
+
+func comparableTypes(a, b valueType) bool {
+	if a == typeAny || b == typeAny || a == b {
+		return true
+	}
+	if a == typeTime || b == typeTime {
+		return a != typeBool && a != typeNull && b != typeBool && b != typeNull
+	}
+	return false
+}
+
+// missing is the value of the missing field.
+type missingValue struct{}
+
+var missing = missingValue{}
+
+func isTrue(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+type node interface {
+	eval(r *gotailf.Record) interface{}
+	// typ returns the static type of the node.
+	typ() valueType
+}
+
+type (
+	literalNode struct {
+		value interface{}
+	}
+	fieldNode struct {
+		name string
+	}
+	metaNode struct {
+		meta *meta
+	}
+	existsNode struct {
+		name string
+	}
+	notNode struct {
+		node node
+	}
+	andNode struct {
+		left  node
+		right node
+	}
+	orNode struct {
+		left  node
+		right node
+	}
+	compareNode struct {
+		op    tokenType
+		left  node
+		right node
+	}
+	matchNode struct {
+		negate bool
+		node   node
+		re     *regexp.Regexp
+	}
+	inNode struct {
+		node node
+		list []interface{}
+	}
+)
+
+func (n *literalNode) eval(_ *gotailf.Record) interface{} { return n.value }
+func (n *literalNode) typ() valueType                     { return typeOf(n.value) }
+
+func (n *fieldNode) eval(r *gotailf.Record) interface{} {
+	if v, ok := r.Fields.Get(n.name); ok {
+		return v
+	}
+	return missing
+}
+func (*fieldNode) typ() valueType { return typeAny }
+
+func (n *metaNode) eval(r *gotailf.Record) interface{} { return n.meta.get(r) }
+func (n *metaNode) typ() valueType                     { return n.meta.typ }
+
+func (n *existsNode) eval(r *gotailf.Record) interface{} {
+	_, ok := r.Fields.Get(n.name)
+	return ok
+}
+func (*existsNode) typ() valueType { return typeBool }
+
+func (n *notNode) eval(r *gotailf.Record) interface{} { return !isTrue(n.node.eval(r)) }
+func (*notNode) typ() valueType                       { return typeBool }
+
+func (n *andNode) eval(r *gotailf.Record) interface{} {
+	return isTrue(n.left.eval(r)) && isTrue(n.right.eval(r))
+}
+func (*andNode) typ() valueType { return typeBool }
+
+func (n *orNode) eval(r *gotailf.Record) interface{} {
+	return isTrue(n.left.eval(r)) || isTrue(n.right.eval(r))
+}
+func (*orNode) typ() valueType { return typeBool }
+
+func (n *compareNode) eval(r *gotailf.Record) interface{} {
+	left, right := n.left.eval(r), n.right.eval(r)
+	if left == missing || right == missing {
+		return false
+	}
+	switch n.op {
+	case tokenEq:
+		return equal(left, right)
+	case tokenNe:
+		return !equal(left, right)
+	}
+	c, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case tokenLt:
+		return c < 0
+	case tokenLe:
+		return c <= 0
+	case tokenGt:
+		return c > 0
+	case tokenGe:
+		return c >= 0
+	default:
+		panic("unreachable")
+	}
+}
+func (*compareNode) typ() valueType { return typeBool }
+
+func (n *matchNode) eval(r *gotailf.Record) interface{} {
+	v := n.node.eval(r)
+	if v == missing {
+		return false
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = formatValue(v)
+	}
+	return n.re.MatchString(s) != n.negate
+}
+func (*matchNode) typ() valueType { return typeBool }
+
+func (n *inNode) eval(r *gotailf.Record) interface{} {
+	v := n.node.eval(r)
+	if v == missing {
+		return false
+	}
+	for _, x := range n.list {
+		if equal(v, x) {
+			return true
+		}
+	}
+	return false
+}
+func (*inNode) typ() valueType { return typeBool }
+
+func equal(a, b interface{}) bool {
+	switch a.(type) {
+	case bool, nil:
+		return a == b
+	}
+	switch b.(type) {
+	case bool, nil:
+		return false
+	}
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// compare returns the order of a and b.
+// Returns false if they are not comparable.
+func compare(a, b interface{}) (int, bool) {
+	switch {
+	case typeOf(a) == typeTime || typeOf(b) == typeTime:
+		x, ok := toTime(a)
+		if !ok {
+			return 0, false
+		}
+		y, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		default:
+			return 0, true
+		}
+	case typeOf(a) == typeNumber || typeOf(b) == typeNumber:
+		x, ok := toNumber(a)
+		if !ok {
+			return 0, false
+		}
+		y, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case typeOf(a) == typeString && typeOf(b) == typeString:
+		return strings.Compare(a.(string), b.(string)), true
+	default:
+		return 0, false
+	}
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	case float64:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func formatValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return parse.FormatValue(v)
+}
+
+type meta struct {
+	typ valueType
+	get func(r *gotailf.Record) interface{}
+}
+
+// metas are the meta identifiers, the attributes of the record other than the fields.
+var metas = map[string]*meta{
+	"text": {
+		typ: typeString,
+		get: func(r *gotailf.Record) interface{} { return r.Text },
+	},
+	"file": {
+		typ: typeString,
+		get: func(r *gotailf.Record) interface{} { return r.File },
+	},
+	"offset": {
+		typ: typeNumber,
+		get: func(r *gotailf.Record) interface{} { return float64(r.Offset) },
+	},
+	"line": {
+		typ: typeNumber,
+		get: func(r *gotailf.Record) interface{} {
+			if r.Line == 0 {
+				return missing
+			}
+			return float64(r.Line)
+		},
+	},
+	"level": {
+		typ: typeString,
+		get: func(r *gotailf.Record) interface{} { return r.Level.String() },
+	},
+	"event_time": {
+		typ: typeTime,
+		get: func(r *gotailf.Record) interface{} {
+			if r.EventTime.IsZero() {
+				return missing
+			}
+			return r.EventTime
+		},
+	},
+}
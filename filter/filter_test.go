@@ -0,0 +1,111 @@
+package filter_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/filter"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpr(t *testing.T) {
+	record := &gotailf.Record{
+		File:      "app.log",
+		Offset:    120,
+		Line:      3,
+		Text:      "raw text",
+		Level:     level.Error,
+		EventTime: time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC),
+		Fields: parse.Fields{
+			"level":  "error",
+			"status": float64(503),
+			"code":   "404",
+			"path":   "/api/users",
+			"ok":     false,
+			"nil":    nil,
+			"ts":     "2021-10-01T12:00:00Z",
+			"epoch":  float64(1633089600),
+		},
+	}
+
+	for _, tc := range []*struct {
+		expr string
+		want bool
+	}{
+		{`level == "error"`, true},
+		{`level != "error"`, false},
+		{`level in ("warn", "error")`, true},
+		{`level in ["warn", "info"]`, false},
+		{`level not in ("warn", "info")`, true},
+		{`status >= 500`, true},
+		{`status < 500`, false},
+		{`code == 404`, true},
+		{`code > 400 && code < 500`, true},
+		{`path =~ "^/api"`, true},
+		{`path !~ "^/api"`, false},
+		{`status =~ "^5"`, true},
+		{`exists(level) && !exists(missing)`, true},
+		{`exists("nil") && nil == null`, true},
+		{`missing == 1`, false},
+		{`missing != 1`, false},
+		{`!(missing == 1)`, true},
+		{`ok == false`, true},
+		{`!ok`, true},
+		{`ok`, false},
+		{`level == "info" || status == 503`, true},
+		{`ts >= time("2021-10-01T00:00:00Z") && ts < time("2021-10-02T00:00:00Z")`, true},
+		{`epoch == time("2021-10-01T12:00:00Z")`, true},
+		{`$text == "raw text" && $file =~ "app" && $offset == 120`, true},
+		{`$level in ("error", "fatal")`, true},
+		{`$line == 3`, true},
+		{`$event_time >= time("2021-10-01T00:00:00Z") && $event_time < ts`, false},
+		{`$event_time == ts`, true},
+		{`level == "error" && (status < 500 || path =~ "users$")`, true},
+	} {
+		tc := tc
+		t.Run(tc.expr, func(t *testing.T) {
+			e, err := filter.Compile(tc.expr)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, tc.want, e.Match(record))
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	for _, tc := range []*struct {
+		expr string
+		pos  int
+	}{
+		{`level ==`, 9},
+		{`(level == "a"`, 14},
+		{`level == "a`, 10},
+		{`status >= true`, 11},
+		{`status =~ "[a"`, 11},
+		{`status =~ 1`, 11},
+		{`"a" && b`, 1},
+		{`level in ("a", b)`, 16},
+		{`level not "a"`, 11},
+		{`$unknown == 1`, 1},
+		{`unknown(x)`, 1},
+		{`ts > time("yesterday")`, 11},
+		{`"a" < 1`, 5},
+		{`level == "a" level`, 14},
+		{`level # 1`, 7},
+	} {
+		tc := tc
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := filter.Compile(tc.expr)
+			var serr *filter.SyntaxError
+			if !assert.True(t, errors.As(err, &serr), "%v", err) {
+				return
+			}
+			assert.Equal(t, tc.pos, serr.Pos, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenMeta
+	tokenString
+	tokenNumber
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNe
+	tokenLt
+	tokenLe
+	tokenGt
+	tokenGe
+	tokenMatch
+	tokenNotMatch
+)
+
+func (t tokenType) String() string {
+	switch t {
+	case tokenEOF:
+		return "EOF"
+	case tokenIdent:
+		return "identifier"
+	case tokenMeta:
+		return "meta identifier"
+	case tokenString:
+		return "string"
+	case tokenNumber:
+		return "number"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenLBracket:
+		return "'['"
+	case tokenRBracket:
+		return "']'"
+	case tokenComma:
+		return "','"
+	case tokenAnd:
+		return "'&&'"
+	case tokenOr:
+		return "'||'"
+	case tokenNot:
+		return "'!'"
+	case tokenEq:
+		return "'=='"
+	case tokenNe:
+		return "'!='"
+	case tokenLt:
+		return "'<'"
+	case tokenLe:
+		return "'<='"
+	case tokenGt:
+		return "'>'"
+	case tokenGe:
+		return "'>='"
+	case tokenMatch:
+		return "'=~'"
+	case tokenNotMatch:
+		return "'!~'"
+	default:
+		return "unknown"
+	}
+}
+
+type token struct {
+	typ tokenType
+	// raw text of the token.
+	raw string
+	// unquoted string or the identifier name.
+	value string
+	num   float64
+	// position of the head of the token, 1-based.
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "EOF"
+	case tokenIdent, tokenMeta, tokenString, tokenNumber:
+		return fmt.Sprintf("%s %s", t.typ, t.raw)
+	default:
+		return t.typ.String()
+	}
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isIdentHead(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isIdentTail(c byte) bool {
+	return isIdentHead(c) || isDigit(c) || c == '.' || c == '-'
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// errorf returns a SyntaxError at the 0-based position.
+func (l *lexer) errorf(pos int, format string, v ...interface{}) error {
+	return syntaxErrorf(pos+1, format, v...)
+}
+
+func (l *lexer) tokens() ([]token, error) {
+	var tokens []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.typ == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{typ: tokenEOF, pos: start + 1}, nil
+	}
+	newToken := func(typ tokenType, width int) (token, error) {
+		l.pos += width
+		return token{
+			typ: typ,
+			raw: l.src[start:l.pos],
+			pos: start + 1,
+		}, nil
+	}
+	c := l.src[l.pos]
+	peek := func(s string) bool { return strings.HasPrefix(l.src[l.pos:], s) }
+	switch {
+	case c == '(':
+		return newToken(tokenLParen, 1)
+	case c == ')':
+		return newToken(tokenRParen, 1)
+	case c == '[':
+		return newToken(tokenLBracket, 1)
+	case c == ']':
+		return newToken(tokenRBracket, 1)
+	case c == ',':
+		return newToken(tokenComma, 1)
+	case peek("&&"):
+		return newToken(tokenAnd, 2)
+	case peek("||"):
+		return newToken(tokenOr, 2)
+	case peek("=="):
+		return newToken(tokenEq, 2)
+	case peek("!="):
+		return newToken(tokenNe, 2)
+	case peek("=~"):
+		return newToken(tokenMatch, 2)
+	case peek("!~"):
+		return newToken(tokenNotMatch, 2)
+	case peek("<="):
+		return newToken(tokenLe, 2)
+	case peek(">="):
+		return newToken(tokenGe, 2)
+	case c == '<':
+		return newToken(tokenLt, 1)
+	case c == '>':
+		return newToken(tokenGt, 1)
+	case c == '!':
+		return newToken(tokenNot, 1)
+	case c == '"':
+		return l.str()
+	case isDigit(c) || c == '-' || c == '.':
+		return l.number()
+	case c == '$':
+		l.pos++
+		if l.pos >= len(l.src) || !isIdentHead(l.src[l.pos]) {
+			return token{}, l.errorf(start, "invalid meta identifier")
+		}
+		t := l.ident(start)
+		t.typ = tokenMeta
+		t.value = t.raw[1:]
+		return t, nil
+	case isIdentHead(c):
+		return l.ident(start), nil
+	default:
+		return token{}, l.errorf(start, "unexpected character %q", c)
+	}
+}
+
+func (l *lexer) ident(start int) token {
+	for l.pos < len(l.src) && isIdentTail(l.src[l.pos]) {
+		l.pos++
+	}
+	return token{
+		typ:   tokenIdent,
+		raw:   l.src[start:l.pos],
+		value: l.src[start:l.pos],
+		pos:   start + 1,
+	}
+}
+
+func (l *lexer) str() (token, error) {
+	start := l.pos
+	l.pos++ // skip open quote
+	for l.pos < len(l.src) && l.src[l.pos] != '"' {
+		if l.src[l.pos] == '\\' {
+			l.pos++
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{}, l.errorf(start, "unterminated string")
+	}
+	l.pos++ // skip close quote
+	raw := l.src[start:l.pos]
+	v, err := strconv.Unquote(raw)
+	if err != nil {
+		return token{}, l.errorf(start, "invalid string %s", raw)
+	}
+	return token{
+		typ:   tokenString,
+		raw:   raw,
+		value: v,
+		pos:   start + 1,
+	}, nil
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || strings.IndexByte(".eE", l.src[l.pos]) >= 0 ||
+		(l.pos > start && strings.IndexByte("eE", l.src[l.pos-1]) >= 0 && strings.IndexByte("+-", l.src[l.pos]) >= 0)) {
+		l.pos++
+	}
+	raw := l.src[start:l.pos]
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return token{}, l.errorf(start, "invalid number %s", raw)
+	}
+	return token{
+		typ: tokenNumber,
+		raw: raw,
+		num: n,
+		pos: start + 1,
+	}, nil
+}
@@ -0,0 +1,296 @@
+package filter
+
+import (
+	"regexp"
+	"time"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, v ...interface{}) error {
+	return syntaxErrorf(t.pos, format, v...)
+}
+
+func (p *parser) expect(typ tokenType) (token, error) {
+	t := p.advance()
+	if t.typ != typ {
+		return t, p.errorf(t, "expected %s but got %s", typ, t)
+	}
+	return t, nil
+}
+
+func (p *parser) isKeyword(t token, keyword string) bool {
+	return t.typ == tokenIdent && t.value == keyword
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	if err := p.expectBool(n, p.tokens[0]); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *parser) expectBool(n node, t token) error {
+	if x := n.typ(); x != typeBool && x != typeAny {
+		return p.errorf(t, "expected boolean but got %s", x)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	head := p.peek()
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenOr {
+		p.advance()
+		rightHead := p.peek()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(left, head); err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(right, rightHead); err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	head := p.peek()
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().typ == tokenAnd {
+		p.advance()
+		rightHead := p.peek()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(left, head); err != nil {
+			return nil, err
+		}
+		if err := p.expectBool(right, rightHead); err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.peek().typ != tokenNot {
+		return p.comparison()
+	}
+	p.advance()
+	head := p.peek()
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectBool(n, head); err != nil {
+		return nil, err
+	}
+	return &notNode{node: n}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	head := p.peek()
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.typ == tokenEq, t.typ == tokenNe, t.typ == tokenLt, t.typ == tokenLe, t.typ == tokenGt, t.typ == tokenGe:
+		p.advance()
+		rightHead := p.peek()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if t.typ != tokenEq && t.typ != tokenNe {
+			for _, x := range []struct {
+				n node
+				t token
+			}{{left, head}, {right, rightHead}} {
+				if y := x.n.typ(); y == typeBool || y == typeNull {
+					return nil, p.errorf(x.t, "cannot order %s by %s", y, t.typ)
+				}
+			}
+		}
+		if !comparableTypes(left.typ(), right.typ()) {
+			return nil, p.errorf(t, "cannot compare %s with %s", left.typ(), right.typ())
+		}
+		return &compareNode{op: t.typ, left: left, right: right}, nil
+	case t.typ == tokenMatch, t.typ == tokenNotMatch:
+		p.advance()
+		pt, err := p.expect(tokenString)
+		if err != nil {
+			return nil, p.errorf(pt, "expected regexp string after %s but got %s", t.typ, pt)
+		}
+		re, err := regexp.Compile(pt.value)
+		if err != nil {
+			return nil, p.errorf(pt, "invalid regexp: %v", err)
+		}
+		return &matchNode{negate: t.typ == tokenNotMatch, node: left, re: re}, nil
+	case p.isKeyword(t, "in"):
+		p.advance()
+		list, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{node: left, list: list}, nil
+	case p.isKeyword(t, "not"):
+		p.advance()
+		if in := p.advance(); !p.isKeyword(in, "in") {
+			return nil, p.errorf(in, "expected in after not but got %s", in)
+		}
+		list, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: &inNode{node: left, list: list}}, nil
+	default:
+		return left, nil
+	}
+}
+
+func (p *parser) list() ([]interface{}, error) {
+	open := p.advance()
+	var closeType tokenType
+	switch open.typ {
+	case tokenLParen:
+		closeType = tokenRParen
+	case tokenLBracket:
+		closeType = tokenRBracket
+	default:
+		return nil, p.errorf(open, "expected list but got %s", open)
+	}
+	var list []interface{}
+	for {
+		t := p.peek()
+		n, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := n.(*literalNode)
+		if !ok {
+			return nil, p.errorf(t, "expected literal in list but got %s", t)
+		}
+		list = append(list, lit.value)
+		t = p.advance()
+		switch t.typ {
+		case tokenComma:
+			continue
+		case closeType:
+			return list, nil
+		default:
+			return nil, p.errorf(t, "expected ',' or %s but got %s", closeType, t)
+		}
+	}
+}
+
+func (p *parser) operand() (node, error) {
+	t := p.advance()
+	switch t.typ {
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenNumber:
+		return &literalNode{value: t.num}, nil
+	case tokenMeta:
+		m, ok := metas[t.value]
+		if !ok {
+			return nil, p.errorf(t, "unknown meta identifier %s", t.raw)
+		}
+		return &metaNode{meta: m}, nil
+	case tokenLParen:
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "in", "not":
+			return nil, p.errorf(t, "unexpected %s", t.value)
+		}
+		if p.peek().typ == tokenLParen {
+			return p.call(t)
+		}
+		return &fieldNode{name: t.value}, nil
+	default:
+		return nil, p.errorf(t, "expected operand but got %s", t)
+	}
+}
+
+func (p *parser) call(name token) (node, error) {
+	p.advance() // skip (
+	arg := p.advance()
+	switch name.value {
+	case "exists":
+		var n node
+		switch arg.typ {
+		case tokenIdent:
+			n = &existsNode{name: arg.value}
+		case tokenString:
+			n = &existsNode{name: arg.value}
+		default:
+			return nil, p.errorf(arg, "expected field name but got %s", arg)
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case "time":
+		if arg.typ != tokenString {
+			return nil, p.errorf(arg, "expected time string but got %s", arg)
+		}
+		v, err := time.Parse(time.RFC3339Nano, arg.value)
+		if err != nil {
+			return nil, p.errorf(arg, "invalid time, want RFC3339: %v", err)
+		}
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return &literalNode{value: v}, nil
+	default:
+		return nil, p.errorf(name, "unknown function %s", name.value)
+	}
+}
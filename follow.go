@@ -0,0 +1,238 @@
+package gotailf
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/berquerant/gotailf/internal"
+)
+
+// FollowReader is an io.Reader over the appended data of the file.
+//
+// Read blocks until the new data is available, and returns io.EOF when the context ends.
+// WriteTo copies the data by io.Copy from the file, so the copy is done by
+// copy_file_range(2), splice(2) or sendfile(2) on Linux when the writer is a file, a pipe or a socket.
+//
+// FollowReader is not safe for concurrent use, cancel the context to stop the blocking Read.
+type FollowReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// target filename.
+	filename string
+	config   *Config
+	// continues is true if continues tailing when the file is gone or truncated.
+	continues bool
+	// target file, nil until opened.
+	file internal.File
+	// cancels the watcher of the file.
+	unwatch context.CancelFunc
+	eventC  <-chan internal.FileChangeEvent
+	// gone is true if the file is gone but the rest of the file is not read yet.
+	gone bool
+	pos  int64
+	err  error
+}
+
+// NewFollowReader returns a new FollowReader.
+// The target file must be exist.
+// When the target file is moved, removed or truncated, Read returns ErrFileGone or ErrFileTruncated.
+// The rest of the moved or removed file is read before ErrFileGone.
+func NewFollowReader(ctx context.Context, filename string, opts ...Option) (*FollowReader, error) {
+	s := newFollowReader(ctx, filename, false, opts)
+	f, err := internal.OpenFile(filename)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	if err := s.open(f, s.config.Offset); err != nil {
+		f.Close()
+		s.cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewContinueFollowReader returns a new FollowReader that continues reading
+// when the target file is moved, removed or truncated, as NewContinueTailer.
+// The target file is opened by the first read, waiting for the file to be created.
+func NewContinueFollowReader(ctx context.Context, filename string, opts ...Option) *FollowReader {
+	return newFollowReader(ctx, filename, true, opts)
+}
+
+func newFollowReader(ctx context.Context, filename string, continues bool, opts []Option) *FollowReader {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &FollowReader{
+		ctx:       ctx,
+		cancel:    cancel,
+		filename:  filename,
+		config:    config,
+		continues: continues,
+	}
+}
+
+// Filename returns the name of the target file.
+func (s *FollowReader) Filename() string { return s.filename }
+
+// Pos returns the read offset of the current target file.
+func (s *FollowReader) Pos() int64 { return s.pos }
+
+// Close stops following and closes the file.
+func (s *FollowReader) Close() error {
+	s.cancel()
+	if s.err == nil {
+		s.err = io.EOF
+	}
+	return s.close()
+}
+
+func (s *FollowReader) close() error {
+	if s.unwatch != nil {
+		s.unwatch()
+		s.unwatch = nil
+	}
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open starts reading the file from the offset.
+func (s *FollowReader) open(f internal.File, offset int64) error {
+	pos, err := seekOffset(f, offset)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	eventC, err := internal.NewWatcher(s.filename, s.config.FlushInterval).Watch(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	s.file = f
+	s.pos = pos
+	s.unwatch = cancel
+	s.eventC = eventC
+	s.gone = false
+	return nil
+}
+
+// reopen waits for the target file and starts reading it from the offset.
+func (s *FollowReader) reopen(offset int64) error {
+	if err := s.close(); err != nil {
+		return err
+	}
+	f, err := internal.OpenFileLoop(s.ctx, s.filename, s.config.FlushInterval)
+	if err != nil {
+		return s.ctxErr(err)
+	}
+	if err := s.open(f, offset); err != nil {
+		f.Close()
+		return err
+	}
+	return nil
+}
+
+// ctxErr converts the end of the context into io.EOF.
+func (s *FollowReader) ctxErr(err error) error {
+	if s.ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return io.EOF
+	}
+	return err
+}
+
+func originOffset(isOrigin bool) int64 {
+	if isOrigin {
+		return 0
+	}
+	return -1 // EOF
+}
+
+// wait is called when the read reaches the end of the file.
+// Returns nil when the new data may be available.
+func (s *FollowReader) wait() error {
+	if s.file == nil {
+		return s.reopen(s.config.Offset)
+	}
+	if s.gone {
+		if !s.continues {
+			return ErrFileGone
+		}
+		return s.reopen(originOffset(s.config.TailFromOriginWhenGone))
+	}
+	ev, ok := <-s.eventC
+	if !ok {
+		return io.EOF
+	}
+	switch ev.Type() {
+	case internal.FileChangeEventAppended:
+		return nil
+	case internal.FileChangeEventGone:
+		// read the rest of the file
+		s.gone = true
+		return nil
+	case internal.FileChangeEventTruncated:
+		if !s.continues {
+			return ErrFileTruncated
+		}
+		return s.reopen(originOffset(s.config.TailFromOriginWhenTruncated))
+	default:
+		panic("unreachable")
+	}
+}
+
+// Read reads the appended data.
+// Blocks until the new data is available.
+// Returns io.EOF when the context ends or the reader is closed.
+func (s *FollowReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.file != nil {
+			n, err := s.file.Read(p)
+			s.pos += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				s.err = err
+				continue
+			}
+		}
+		s.err = s.wait()
+	}
+}
+
+// WriteTo writes the appended data to w until the context ends or an error occurs.
+// The end of the context is not an error.
+func (s *FollowReader) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for {
+		if s.err != nil {
+			if errors.Is(s.err, io.EOF) {
+				return written, nil
+			}
+			return written, s.err
+		}
+		if s.file != nil {
+			// copies until the end of the file
+			n, err := io.Copy(w, s.file)
+			written += n
+			s.pos += n
+			if err != nil {
+				return written, err
+			}
+		}
+		s.err = s.wait()
+	}
+}
@@ -0,0 +1,156 @@
+package gotailf_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowReader(t *testing.T) {
+	t.Parallel()
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		_, err := gotailf.NewFollowReader(context.TODO(), dir.Path("notfound"))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("read", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "content\n")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		r, err := gotailf.NewFollowReader(ctx, f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(3),
+		)
+		assert.Nil(t, err)
+		defer r.Close()
+		time.AfterFunc(80*time.Millisecond, func() {
+			fmt.Fprint(f.File(), "app")
+		})
+		time.AfterFunc(160*time.Millisecond, func() {
+			fmt.Fprint(f.File(), "end")
+		})
+		got, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "tent\nappend", string(got))
+		assert.Equal(t, int64(14), r.Pos())
+	})
+
+	t.Run("write to", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "content\n")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		dest := test.NewTmpFile(t)
+		defer func() {
+			dest.Close(t)
+			dest.Remove(t)
+		}()
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		r, err := gotailf.NewFollowReader(ctx, f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+		)
+		assert.Nil(t, err)
+		defer r.Close()
+		time.AfterFunc(80*time.Millisecond, func() {
+			fmt.Fprint(f.File(), "append\n")
+		})
+		n, err := r.WriteTo(dest.File())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(15), n)
+		got, err := os.ReadFile(dest.Name())
+		assert.Nil(t, err)
+		assert.Equal(t, "content\nappend\n", string(got))
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		defer f.Close(t)
+		r, err := gotailf.NewFollowReader(context.TODO(), f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+		)
+		assert.Nil(t, err)
+		defer r.Close()
+		time.AfterFunc(80*time.Millisecond, func() {
+			// the rest is read before the error
+			fmt.Fprint(f.File(), "rest\n")
+			f.Remove(t)
+		})
+		var buf bytes.Buffer
+		_, err = r.WriteTo(&buf)
+		assert.Equal(t, gotailf.ErrFileGone, err)
+		assert.Equal(t, "rest\n", buf.String())
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "content\n")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		r, err := gotailf.NewFollowReader(context.TODO(), f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+		)
+		assert.Nil(t, err)
+		defer r.Close()
+		time.AfterFunc(80*time.Millisecond, func() {
+			assert.Nil(t, f.File().Truncate(0))
+		})
+		_, err = r.Read(make([]byte, 8))
+		assert.Equal(t, gotailf.ErrFileTruncated, err)
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			src  = dir.Path("continue")
+			dest = dir.Path("continue-moved")
+		)
+		ctx, cancel := context.WithTimeout(context.TODO(), 500*time.Millisecond)
+		defer cancel()
+		r := gotailf.NewContinueFollowReader(ctx, src,
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithTailFromOriginWhenGone(true),
+		)
+		defer r.Close()
+		// created after the reader
+		assert.Nil(t, os.WriteFile(src, []byte("first\n"), 0o600))
+		time.AfterFunc(120*time.Millisecond, func() {
+			assert.Nil(t, os.Rename(src, dest))
+		})
+		time.AfterFunc(200*time.Millisecond, func() {
+			assert.Nil(t, os.WriteFile(src, []byte("second\n"), 0o600))
+		})
+		var buf bytes.Buffer
+		_, err := r.WriteTo(&buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "second\n", buf.String())
+	})
+}
@@ -0,0 +1,265 @@
+// Package format provides formatters of records.
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+)
+
+// Formatter writes a record.
+type Formatter interface {
+	// Format writes the record into w as a line.
+	Format(w io.Writer, r *gotailf.Record) error
+}
+
+// Value returns the string representation of the value of the key of the record.
+// The key is a field name or a meta name, that is one of $text, $file, $offset, $line, $time, $level and $event_time.
+// Returns false if the record does not have the key.
+func Value(r *gotailf.Record, key string) (string, bool) {
+	switch key {
+	case "$text":
+		return r.Text, true
+	case "$file":
+		return r.File, true
+	case "$offset":
+		return strconv.FormatInt(r.Offset, 10), true
+	case "$line":
+		if r.Line == 0 {
+			return "", false
+		}
+		return strconv.FormatInt(r.Line, 10), true
+	case "$time":
+		return r.Time.Format(time.RFC3339Nano), true
+	case "$level":
+		return r.Level.String(), true
+	case "$event_time":
+		if r.EventTime.IsZero() {
+			return "", false
+		}
+		return r.EventTime.Format(time.RFC3339Nano), true
+	}
+	v, ok := r.Fields.Get(key)
+	if !ok {
+		return "", false
+	}
+	return parse.FormatValue(v), true
+}
+
+type raw struct{}
+
+// NewRaw returns a formatter that writes the text of the record.
+func NewRaw() Formatter { return &raw{} }
+
+func (*raw) Format(w io.Writer, r *gotailf.Record) error {
+	_, err := fmt.Fprintln(w, r.Text)
+	return err
+}
+
+type logfmt struct {
+	keys []string
+}
+
+// NewLogfmt returns a formatter that writes the key=value pairs of the keys.
+// If keys are empty, writes all the fields in key order.
+// If the record is malformed, writes the text of the record.
+func NewLogfmt(keys []string) Formatter {
+	return &logfmt{
+		keys: keys,
+	}
+}
+
+func (s *logfmt) Format(w io.Writer, r *gotailf.Record) error {
+	if r.ParseErr != nil {
+		_, err := fmt.Fprintln(w, r.Text)
+		return err
+	}
+	keys := s.keys
+	if len(keys) == 0 {
+		keys = r.Fields.Keys()
+	}
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v, ok := Value(r, k)
+		if !ok {
+			continue
+		}
+		pairs = append(pairs, k+"="+quoteLogfmt(v))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(pairs, " "))
+	return err
+}
+
+func quoteLogfmt(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\r\n\"=\\") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+type jsonl struct{}
+
+// NewJSONL returns a formatter that writes the record as a JSON object.
+func NewJSONL() Formatter { return &jsonl{} }
+
+// JSONRecord is the JSON representation of the record.
+type JSONRecord struct {
+	File       string       `json:"file"`
+	Offset     int64        `json:"offset"`
+	Line       int64        `json:"line,omitempty"`
+	Time       time.Time    `json:"time"`
+	Text       string       `json:"text"`
+	Level      level.Level  `json:"level,omitempty"`
+	EventTime  *time.Time   `json:"event_time,omitempty"`
+	Late       bool         `json:"late,omitempty"`
+	Skipped    int64        `json:"skipped,omitempty"`
+	Fields     parse.Fields `json:"fields,omitempty"`
+	ParseError string       `json:"parse_error,omitempty"`
+}
+
+// NewJSONRecord returns the JSON representation of the record.
+func NewJSONRecord(r *gotailf.Record) *JSONRecord {
+	x := &JSONRecord{
+		File:    r.File,
+		Offset:  r.Offset,
+		Line:    r.Line,
+		Time:    r.Time,
+		Text:    r.Text,
+		Level:   r.Level,
+		Late:    r.Late,
+		Skipped: r.Skipped,
+		Fields:  r.Fields,
+	}
+	if !r.EventTime.IsZero() {
+		t := r.EventTime
+		x.EventTime = &t
+	}
+	if r.ParseErr != nil {
+		x.ParseError = r.ParseErr.Error()
+	}
+	return x
+}
+
+func (*jsonl) Format(w io.Writer, r *gotailf.Record) error {
+	return json.NewEncoder(w).Encode(NewJSONRecord(r))
+}
+
+type tmpl struct {
+	t *template.Template
+}
+
+// NewTemplate returns a formatter that writes the record by the text/template.
+// The data of the template is *gotailf.Record, e.g. {{.File}}:{{.Offset}} {{.Text}}.
+// Available functions are:
+//
+//	field RECORD KEY: the value of the key of the record as Value(), e.g. {{field . "req.path"}}
+//	json VALUE: the JSON representation of the value
+//
+// A newline is appended to the output.
+func NewTemplate(text string) (Formatter, error) {
+	t, err := template.New("format").Funcs(template.FuncMap{
+		"field": func(r *gotailf.Record, key string) string {
+			v, _ := Value(r, key)
+			return v
+		},
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl{
+		t: t,
+	}, nil
+}
+
+func (s *tmpl) Format(w io.Writer, r *gotailf.Record) error {
+	var b strings.Builder
+	if err := s.t.Execute(&b, r); err != nil {
+		return err
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type delimited struct {
+	keys   []string
+	comma  rune
+	header bool
+	// whether the header is written or not.
+	wroteHeader bool
+}
+
+// ErrNoKeys means that no keys are specified.
+var ErrNoKeys = errors.New("no keys")
+
+// NewCSV returns a formatter that writes the values of the keys as CSV.
+// If header, writes the keys before the first record.
+func NewCSV(keys []string, header bool) (Formatter, error) {
+	return newDelimited(keys, ',', header)
+}
+
+// NewTSV returns a formatter that writes the values of the keys as TSV.
+// Tabs, newlines and backslashes in the values are escaped by backslash.
+// If header, writes the keys before the first record.
+func NewTSV(keys []string, header bool) (Formatter, error) {
+	return newDelimited(keys, '\t', header)
+}
+
+func newDelimited(keys []string, comma rune, header bool) (Formatter, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return &delimited{
+		keys:   keys,
+		comma:  comma,
+		header: header,
+	}, nil
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func (s *delimited) write(w io.Writer, values []string) error {
+	if s.comma == '\t' {
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = tsvEscaper.Replace(v)
+		}
+		_, err := fmt.Fprintln(w, strings.Join(escaped, "\t"))
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = s.comma
+	if err := cw.Write(values); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *delimited) Format(w io.Writer, r *gotailf.Record) error {
+	if s.header && !s.wroteHeader {
+		if err := s.write(w, s.keys); err != nil {
+			return err
+		}
+		s.wroteHeader = true
+	}
+	values := make([]string, len(s.keys))
+	for i, k := range s.keys {
+		values[i], _ = Value(r, k)
+	}
+	return s.write(w, values)
+}
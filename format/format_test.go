@@ -0,0 +1,112 @@
+package format_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatter(t *testing.T) {
+	newRecord := func() *gotailf.Record {
+		return &gotailf.Record{
+			File:   "app.log",
+			Offset: 10,
+			Text:   `level=info msg="a b" n=1`,
+			Time:   time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC),
+			Fields: parse.Fields{
+				"level": "info",
+				"msg":   "a b",
+				"n":     float64(1),
+			},
+		}
+	}
+	malformed := &gotailf.Record{
+		File:     "app.log",
+		Offset:   20,
+		Text:     "plain",
+		Time:     time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC),
+		ParseErr: parse.ErrNoFields,
+	}
+
+	mustNew := func(f format.Formatter, err error) format.Formatter {
+		assert.Nil(t, err)
+		return f
+	}
+
+	for _, tc := range []*struct {
+		title     string
+		formatter format.Formatter
+		records   []*gotailf.Record
+		want      string
+	}{
+		{
+			title:     "raw",
+			formatter: format.NewRaw(),
+			records:   []*gotailf.Record{newRecord()},
+			want:      "level=info msg=\"a b\" n=1\n",
+		},
+		{
+			title:     "logfmt select",
+			formatter: format.NewLogfmt([]string{"n", "msg", "missing", "$offset"}),
+			records:   []*gotailf.Record{newRecord(), malformed},
+			want:      "n=1 msg=\"a b\" $offset=10\nplain\n",
+		},
+		{
+			title:     "logfmt all",
+			formatter: format.NewLogfmt(nil),
+			records:   []*gotailf.Record{newRecord()},
+			want:      "level=info msg=\"a b\" n=1\n",
+		},
+		{
+			title:     "jsonl",
+			formatter: format.NewJSONL(),
+			records:   []*gotailf.Record{newRecord(), malformed},
+			want: `{"file":"app.log","offset":10,"time":"2021-10-01T12:00:00Z","text":"level=info msg=\"a b\" n=1","fields":{"level":"info","msg":"a b","n":1}}
+{"file":"app.log","offset":20,"time":"2021-10-01T12:00:00Z","text":"plain","parse_error":"no fields"}
+`,
+		},
+		{
+			title:     "template",
+			formatter: mustNew(format.NewTemplate(`{{.File}}:{{.Offset}} {{.Fields.level}} {{field . "msg"}} {{json .Fields.n}}`)),
+			records:   []*gotailf.Record{newRecord()},
+			want:      "app.log:10 info a b 1\n",
+		},
+		{
+			title:     "tsv",
+			formatter: mustNew(format.NewTSV([]string{"$file", "level", "missing"}, true)),
+			records:   []*gotailf.Record{newRecord(), newRecord()},
+			want:      "$file\tlevel\tmissing\napp.log\tinfo\t\napp.log\tinfo\t\n",
+		},
+		{
+			title:     "csv",
+			formatter: mustNew(format.NewCSV([]string{"msg", "n"}, false)),
+			records:   []*gotailf.Record{newRecord()},
+			want:      "a b,1\n",
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, r := range tc.records {
+				assert.Nil(t, tc.formatter.Format(&buf, r))
+			}
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := format.NewTemplate("{{.File")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("no keys", func(t *testing.T) {
+		_, err := format.NewCSV(nil, false)
+		assert.True(t, errors.Is(err, format.ErrNoKeys))
+	})
+}
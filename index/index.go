@@ -0,0 +1,417 @@
+// Package index provides sparse line-number indexes of files.
+//
+// An index records the offsets of every Interval lines of the file,
+// so the line number of an offset and the offset of a line number are found
+// by reading at most Interval lines.
+// The index is persisted and invalidated when the file is truncated or replaced,
+// detected by the fingerprint of the head of the file.
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Config represents Indexer configurations.
+type Config struct {
+	// Interval is the number of the lines between the recorded offsets.
+	// Default is 1000.
+	Interval int64
+	// Dir is the directory of the index files.
+	// Empty means the directory of the target file, the index file is .NAME.idx.
+	// Default is gotailf/index in os.UserCacheDir(), or empty if it is not available.
+	Dir string
+}
+
+func newDefaultConfig() *Config {
+	c := &Config{
+		Interval: 1000,
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		c.Dir = filepath.Join(dir, "gotailf", "index")
+	}
+	return c
+}
+
+type Option func(*Config)
+
+// WithInterval sets Config.Interval.
+func WithInterval(interval int64) Option {
+	return func(c *Config) {
+		c.Interval = interval
+	}
+}
+
+// WithDir sets Config.Dir.
+func WithDir(dir string) Option {
+	return func(c *Config) {
+		c.Dir = dir
+	}
+}
+
+// ErrLineOutOfRange means that the line does not exist in the file.
+var ErrLineOutOfRange = errors.New("line out of range")
+
+// fingerprintSize is the max size of the head of the file for the fingerprint.
+const fingerprintSize = 4096
+
+// Fingerprint identifies the content of the file.
+type Fingerprint struct {
+	// Size is the size of the head of the file.
+	Size int64 `json:"size"`
+	// Hash is the hex SHA-256 of the head of the file.
+	Hash string `json:"hash"`
+}
+
+// index is the persisted index.
+type index struct {
+	Interval    int64       `json:"interval"`
+	Fingerprint Fingerprint `json:"fingerprint"`
+	// Lines is the number of the indexed lines.
+	Lines int64 `json:"lines"`
+	// Offset is the offset of the next line of the indexed lines.
+	Offset int64 `json:"offset"`
+	// Points are the offsets of the lines i*Interval+1.
+	Points []int64 `json:"points"`
+}
+
+// Indexer maintains the index of the file.
+// Indexer is not safe for concurrent use.
+type Indexer struct {
+	filename string
+	path     string
+	config   *Config
+	idx      *index
+	// dirty is true if the index is changed after saved.
+	dirty bool
+}
+
+// Path returns the path of the index file of the target file.
+func Path(filename string, dir string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	if dir == "" {
+		return filepath.Join(filepath.Dir(abs), "."+filepath.Base(abs)+".idx"), nil
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+"-"+filepath.Base(abs)+".idx"), nil
+}
+
+// Open loads the index of the file.
+// The index is empty if not persisted yet or invalidated.
+func Open(filename string, opts ...Option) (*Indexer, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.Interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: %d", config.Interval)
+	}
+	path, err := Path(filename, config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	x := &Indexer{
+		filename: filename,
+		path:     path,
+		config:   config,
+	}
+	x.reset()
+	if idx, err := x.load(); err == nil {
+		x.idx = idx
+		x.dirty = false
+	}
+	if err := x.validate(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *Indexer) reset() {
+	x.idx = &index{
+		Interval: x.config.Interval,
+		Points:   []int64{0},
+	}
+	x.dirty = true
+}
+
+func (x *Indexer) load() (*index, error) {
+	b, err := os.ReadFile(x.path)
+	if err != nil {
+		return nil, err
+	}
+	var idx index
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, err
+	}
+	if idx.Interval != x.config.Interval || len(idx.Points) == 0 {
+		return nil, errors.New("incompatible index")
+	}
+	return &idx, nil
+}
+
+// fingerprint returns the fingerprint of the file.
+func fingerprint(f io.ReaderAt, size int64) (Fingerprint, error) {
+	if size > fingerprintSize {
+		size = fingerprintSize
+	}
+	buf := make([]byte, size)
+	if _, err := f.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return Fingerprint{}, err
+	}
+	sum := sha256.Sum256(buf)
+	return Fingerprint{
+		Size: size,
+		Hash: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// validate resets the index if the file is truncated or replaced.
+func (x *Indexer) validate() error {
+	f, err := os.Open(x.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() < x.idx.Offset || stat.Size() < x.idx.Fingerprint.Size {
+		x.reset()
+		return nil
+	}
+	fp, err := fingerprint(f, x.idx.Fingerprint.Size)
+	if err != nil {
+		return err
+	}
+	if fp != x.idx.Fingerprint {
+		x.reset()
+	}
+	return nil
+}
+
+// Lines returns the number of the indexed lines.
+func (x *Indexer) Lines() int64 { return x.idx.Lines }
+
+// Offset returns the offset of the next line of the indexed lines.
+func (x *Indexer) Offset() int64 { return x.idx.Offset }
+
+// Observe indexes the line of n bytes including the trailing newline at the offset,
+// and returns the line number, 1-based.
+// Returns 0 if the offset is not the next of the indexed lines.
+func (x *Indexer) Observe(offset int64, n int) int64 {
+	if offset != x.idx.Offset {
+		return 0
+	}
+	x.idx.Lines++
+	x.idx.Offset += int64(n)
+	if x.idx.Lines%x.idx.Interval == 0 {
+		x.idx.Points = append(x.idx.Points, x.idx.Offset)
+	}
+	x.dirty = true
+	return x.idx.Lines
+}
+
+// Update indexes the lines of the file up to the end of the file.
+// The index is reset if the file is truncated or replaced.
+func (x *Indexer) Update() error {
+	if err := x.validate(); err != nil {
+		return err
+	}
+	f, err := os.Open(x.filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(x.idx.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// long line, read the rest
+			n := len(line)
+			for errors.Is(err, bufio.ErrBufferFull) {
+				line, err = r.ReadSlice('\n')
+				n += len(line)
+			}
+			if err == nil {
+				x.Observe(x.idx.Offset, n)
+			}
+		} else if err == nil {
+			x.Observe(x.idx.Offset, len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return x.refreshFingerprint(f)
+}
+
+func (x *Indexer) refreshFingerprint(f *os.File) error {
+	if x.idx.Fingerprint.Size >= fingerprintSize {
+		return nil
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() == x.idx.Fingerprint.Size {
+		return nil
+	}
+	fp, err := fingerprint(f, stat.Size())
+	if err != nil {
+		return err
+	}
+	x.idx.Fingerprint = fp
+	x.dirty = true
+	return nil
+}
+
+// Save persists the index if changed.
+func (x *Indexer) Save() error {
+	if !x.dirty {
+		return nil
+	}
+	f, err := os.Open(x.filename)
+	if err != nil {
+		return err
+	}
+	err = x.refreshFingerprint(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(x.idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(x.path), 0o755); err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, x.path); err != nil {
+		return err
+	}
+	x.dirty = false
+	return nil
+}
+
+// countLines reads the file from the offset and returns the offset after n lines.
+// Stops at the offset limit if limit is not negative.
+// Returns the number of the read lines and the offset.
+func (x *Indexer) countLines(from, n, limit int64) (int64, int64, error) {
+	f, err := os.Open(x.filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+	var (
+		buf    = make([]byte, 64*1024)
+		count  int64
+		offset = from
+	)
+	for count < n && (limit < 0 || offset < limit) {
+		m, err := f.Read(buf)
+		chunk := buf[:m]
+		for count < n && (limit < 0 || offset < limit) {
+			i := bytes.IndexByte(chunk, '\n')
+			if i < 0 {
+				offset += int64(len(chunk))
+				break
+			}
+			count++
+			offset += int64(i + 1)
+			chunk = chunk[i+1:]
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return count, offset, nil
+}
+
+// LineAt returns the line number of the line at the offset, 1-based.
+// The offset should be the head of a line.
+func (x *Indexer) LineAt(offset int64) (int64, error) {
+	if offset > x.idx.Offset {
+		if err := x.Update(); err != nil {
+			return 0, err
+		}
+	}
+	if offset >= x.idx.Offset {
+		// the line after the indexed lines
+		count, _, err := x.countLines(x.idx.Offset, math.MaxInt64, offset)
+		if err != nil {
+			return 0, err
+		}
+		return x.idx.Lines + count + 1, nil
+	}
+	// the last point before the offset
+	i := sort.Search(len(x.idx.Points), func(i int) bool { return x.idx.Points[i] > offset }) - 1
+	count, _, err := x.countLines(x.idx.Points[i], math.MaxInt64, offset)
+	if err != nil {
+		return 0, err
+	}
+	return int64(i)*x.idx.Interval + count + 1, nil
+}
+
+// OffsetOf returns the offset of the line, 1-based.
+// The line next to the last line is the end of the file.
+func (x *Indexer) OffsetOf(line int64) (int64, error) {
+	if line <= 0 {
+		return 0, fmt.Errorf("%w: %d", ErrLineOutOfRange, line)
+	}
+	if line > x.idx.Lines+1 {
+		if err := x.Update(); err != nil {
+			return 0, err
+		}
+		if line > x.idx.Lines+1 {
+			return 0, fmt.Errorf("%w: %d", ErrLineOutOfRange, line)
+		}
+	}
+	if line == x.idx.Lines+1 {
+		return x.idx.Offset, nil
+	}
+	i := (line - 1) / x.idx.Interval
+	_, offset, err := x.countLines(x.idx.Points[i], line-1-i*x.idx.Interval, -1)
+	return offset, err
+}
+
+// Tail returns the offset of the last n lines.
+// Returns 0 if the file has n lines or less.
+func (x *Indexer) Tail(n int64) (int64, error) {
+	if err := x.Update(); err != nil {
+		return 0, err
+	}
+	if n >= x.idx.Lines {
+		return 0, nil
+	}
+	return x.OffsetOf(x.idx.Lines - n + 1)
+}
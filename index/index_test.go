@@ -0,0 +1,124 @@
+package index_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gotailf/index"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeLines writes the lines "line 1", "line 2", ... and returns the offsets of them.
+func writeLines(t *testing.T, f *os.File, from, n int) []int64 {
+	stat, err := f.Stat()
+	assert.Nil(t, err)
+	var (
+		offset  = stat.Size()
+		offsets = make([]int64, n)
+		b       strings.Builder
+	)
+	for i := 0; i < n; i++ {
+		line := fmt.Sprintf("line %d\n", from+i)
+		offsets[i] = offset
+		offset += int64(len(line))
+		b.WriteString(line)
+	}
+	_, err = f.WriteString(b.String())
+	assert.Nil(t, err)
+	return offsets
+}
+
+func TestIndexer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("seek", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		f := test.NewTmpFile(t)
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		offsets := writeLines(t, f.File(), 1, 25)
+		fmt.Fprint(f.File(), "partial")
+
+		x, err := index.Open(f.Name(), index.WithInterval(10), index.WithDir(dir.Dir()))
+		assert.Nil(t, err)
+		for _, line := range []int{1, 2, 10, 11, 12, 20, 21, 25} {
+			got, err := x.OffsetOf(int64(line))
+			assert.Nil(t, err)
+			assert.Equal(t, offsets[line-1], got, "offset of %d", line)
+			n, err := x.LineAt(offsets[line-1])
+			assert.Nil(t, err)
+			assert.Equal(t, int64(line), n, "line at %d", offsets[line-1])
+		}
+		assert.Equal(t, int64(25), x.Lines())
+		got, err := x.OffsetOf(26)
+		assert.Nil(t, err)
+		assert.Equal(t, x.Offset(), got, "the partial line")
+		_, err = x.OffsetOf(27)
+		assert.ErrorIs(t, err, index.ErrLineOutOfRange)
+		got, err = x.Tail(3)
+		assert.Nil(t, err)
+		assert.Equal(t, offsets[22], got)
+		got, err = x.Tail(100)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), got)
+	})
+
+	t.Run("persisted", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		f := test.NewTmpFile(t)
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		opts := []index.Option{index.WithInterval(10), index.WithDir(dir.Dir())}
+		offsets := writeLines(t, f.File(), 1, 15)
+
+		x, err := index.Open(f.Name(), opts...)
+		assert.Nil(t, err)
+		assert.Nil(t, x.Update())
+		assert.Nil(t, x.Save())
+
+		x, err = index.Open(f.Name(), opts...)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(15), x.Lines(), "loaded")
+		offsets = append(offsets, writeLines(t, f.File(), 16, 10)...)
+		assert.Equal(t, int64(16), x.Observe(offsets[15], len("line 16\n")))
+		assert.Equal(t, int64(0), x.Observe(offsets[20], len("line 21\n")), "not contiguous")
+		got, err := x.OffsetOf(21)
+		assert.Nil(t, err)
+		assert.Equal(t, offsets[20], got)
+		assert.Equal(t, int64(25), x.Lines())
+		assert.Nil(t, x.Save())
+
+		// rewritten
+		assert.Nil(t, f.File().Truncate(0))
+		_, err = f.File().Seek(0, 0)
+		assert.Nil(t, err)
+		writeLines(t, f.File(), 100, 30)
+		x, err = index.Open(f.Name(), opts...)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), x.Lines(), "invalidated")
+		assert.Nil(t, x.Update())
+		assert.Equal(t, int64(30), x.Lines())
+	})
+
+	t.Run("path", func(t *testing.T) {
+		t.Parallel()
+		got, err := index.Path("/var/log/app.log", "")
+		assert.Nil(t, err)
+		assert.Equal(t, "/var/log/.app.log.idx", got)
+		got, err = index.Path("/var/log/app.log", "/cache")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(got, "/cache/"))
+		assert.True(t, strings.HasSuffix(got, "-app.log.idx"))
+	})
+}
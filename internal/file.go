@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -31,3 +32,5 @@ func OpenFileLoop(ctx context.Context, filename string, interval time.Duration)
 }
 
 func DropCRLF(buf string) string { return strings.TrimRight(buf, "\r\n") }
+
+func DropCRLFBytes(buf []byte) []byte { return bytes.TrimRight(buf, "\r\n") }
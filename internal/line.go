@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// LineReader reads lines from the reader.
+// Scans the buffer by bytes.IndexByte and keeps the partial line in the buffer.
+type LineReader struct {
+	r     io.Reader
+	delim byte
+	buf   []byte
+	// unread range of buf.
+	start int
+	end   int
+}
+
+// NewLineReader returns a new LineReader with the initial buffer size.
+// The buffer grows when a line is longer than the buffer.
+func NewLineReader(r io.Reader, size int) *LineReader {
+	return NewDelimReader(r, size, '\n')
+}
+
+// NewDelimReader returns a new LineReader of the lines ending with delim.
+func NewDelimReader(r io.Reader, size int, delim byte) *LineReader {
+	if size < 16 {
+		size = 16
+	}
+	return &LineReader{
+		r:     r,
+		delim: delim,
+		buf:   make([]byte, size),
+	}
+}
+
+// ReadLine returns the next line including the trailing delimiter.
+// Returns io.EOF when no complete lines are left, the partial line is kept for the next call.
+// The returned slice is valid until the next call.
+func (s *LineReader) ReadLine() ([]byte, error) {
+	// bytes of the unread range already scanned.
+	var scanned int
+	for {
+		if i := bytes.IndexByte(s.buf[s.start+scanned:s.end], s.delim); i >= 0 {
+			n := s.start + scanned + i + 1
+			line := s.buf[s.start:n]
+			s.start = n
+			return line, nil
+		}
+		scanned = s.end - s.start
+		if s.start > 0 {
+			// compact
+			copy(s.buf, s.buf[s.start:s.end])
+			s.end -= s.start
+			s.start = 0
+		}
+		if s.end == len(s.buf) {
+			buf := make([]byte, len(s.buf)*2)
+			copy(buf, s.buf[:s.end])
+			s.buf = buf
+		}
+		n, err := s.r.Read(s.buf[s.end:])
+		s.end += n
+		if n > 0 {
+			continue
+		}
+		if err == nil {
+			// no data for now
+			err = io.EOF
+		}
+		return nil, err
+	}
+}
+
+// Buffered returns the size of the partial line.
+func (s *LineReader) Buffered() int { return s.end - s.start }
+
+// Rest returns the partial line and discards it.
+// The returned slice is valid until the next call of ReadLine.
+func (s *LineReader) Rest() []byte {
+	line := s.buf[s.start:s.end]
+	s.start = s.end
+	return line
+}
+
+// offsetChunkSize is the size of the reads of LastLinesOffset and LinesOffset.
+const offsetChunkSize = 64 * 1024
+
+// LastLinesOffset returns the offset of the last n lines ending with delim in the first size bytes of r.
+// The last line may not end with delim.
+func LastLinesOffset(r io.ReaderAt, size, n int64, delim byte) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+	var (
+		buf = make([]byte, offsetChunkSize)
+		end = size
+	)
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := r.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if end == size && chunk[len(chunk)-1] == delim {
+			// the end of the last line
+			chunk = chunk[:len(chunk)-1]
+		}
+		for {
+			i := bytes.LastIndexByte(chunk, delim)
+			if i < 0 {
+				break
+			}
+			if n--; n == 0 {
+				return start + int64(i) + 1, nil
+			}
+			chunk = chunk[:i]
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// FileLastLinesOffset returns the offset of the last n lines ending with delim in the file.
+func FileLastLinesOffset(filename string, n int64, delim byte) (int64, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return LastLinesOffset(f, stat.Size(), n, delim)
+}
+
+// LinesOffset returns the offset next to the first n lines ending with delim in r.
+// Returns the read size if r has less lines.
+func LinesOffset(r io.Reader, n int64, delim byte) (int64, error) {
+	var (
+		buf    = make([]byte, offsetChunkSize)
+		offset int64
+	)
+	for n > 0 {
+		size, err := r.Read(buf)
+		chunk := buf[:size]
+		for n > 0 {
+			i := bytes.IndexByte(chunk, delim)
+			if i < 0 {
+				offset += int64(len(chunk))
+				break
+			}
+			offset += int64(i) + 1
+			chunk = chunk[i+1:]
+			n--
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return offset, nil
+}
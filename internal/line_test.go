@@ -0,0 +1,203 @@
+package internal_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gotailf/internal"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// chunkReader yields the chunks one by one, then io.EOF for each call.
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestLineReader(t *testing.T) {
+	t.Run("lines", func(t *testing.T) {
+		r := internal.NewLineReader(strings.NewReader("first\nsecond\r\nthird"), 16)
+		for _, want := range []string{"first\n", "second\r\n"} {
+			got, err := r.ReadLine()
+			assert.Nil(t, err)
+			assert.Equal(t, want, string(got))
+		}
+		_, err := r.ReadLine()
+		assert.True(t, errors.Is(err, io.EOF))
+		assert.Equal(t, 5, r.Buffered())
+	})
+
+	t.Run("partial line completed later", func(t *testing.T) {
+		src := &chunkReader{chunks: []string{"app"}}
+		r := internal.NewLineReader(src, 16)
+		_, err := r.ReadLine()
+		assert.True(t, errors.Is(err, io.EOF))
+		src.chunks = []string{"end\nnext"}
+		got, err := r.ReadLine()
+		assert.Nil(t, err)
+		assert.Equal(t, "append\n", string(got))
+	})
+
+	t.Run("rest", func(t *testing.T) {
+		r := internal.NewLineReader(strings.NewReader("first\nlast"), 16)
+		got, err := r.ReadLine()
+		assert.Nil(t, err)
+		assert.Equal(t, "first\n", string(got))
+		_, err = r.ReadLine()
+		assert.True(t, errors.Is(err, io.EOF))
+		assert.Equal(t, "last", string(r.Rest()))
+		assert.Equal(t, 0, r.Buffered())
+	})
+
+	t.Run("delimiter", func(t *testing.T) {
+		r := internal.NewDelimReader(strings.NewReader("a\nb\x00c\x00"), 16, 0)
+		for _, want := range []string{"a\nb\x00", "c\x00"} {
+			got, err := r.ReadLine()
+			assert.Nil(t, err)
+			assert.Equal(t, want, string(got))
+		}
+	})
+
+	t.Run("long line grows buffer", func(t *testing.T) {
+		long := strings.Repeat("x", 100) + "\n"
+		r := internal.NewLineReader(strings.NewReader(long+"y\n"), 16)
+		got, err := r.ReadLine()
+		assert.Nil(t, err)
+		assert.Equal(t, long, string(got))
+		got, err = r.ReadLine()
+		assert.Nil(t, err)
+		assert.Equal(t, "y\n", string(got))
+	})
+}
+
+func benchmarkData() []byte {
+	var b bytes.Buffer
+	for b.Len() < 16*1024*1024 {
+		b.WriteString(`time=2021-10-01T12:00:00Z level=info msg="request done" status=200 path=/api/users` + "\n")
+	}
+	return b.Bytes()
+}
+
+// BenchmarkReadString is the read path before LineReader.
+func BenchmarkReadString(b *testing.B) {
+	data := benchmarkData()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var (
+			r   = bufio.NewReader(bytes.NewReader(data))
+			buf strings.Builder
+		)
+		for {
+			x, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			if buf.Len() > 0 {
+				_ = internal.DropCRLF(buf.String() + x)
+				buf.Reset()
+				continue
+			}
+			_ = internal.DropCRLF(x)
+		}
+	}
+}
+
+func BenchmarkLineReader(b *testing.B) {
+	data := benchmarkData()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := internal.NewLineReader(bytes.NewReader(data), 64*1024)
+		for {
+			line, err := r.ReadLine()
+			if err != nil {
+				break
+			}
+			_ = string(internal.DropCRLFBytes(line))
+		}
+	}
+}
+
+func TestLastLinesOffset(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		data  string
+		n     int64
+		delim byte
+		want  int64
+	}{
+		{title: "empty", data: "", n: 3, delim: '\n', want: 0},
+		{title: "zero", data: "a\nb\n", n: 0, delim: '\n', want: 4},
+		{title: "last", data: "a\nb\nc\n", n: 1, delim: '\n', want: 4},
+		{title: "last 2", data: "a\nb\nc\n", n: 2, delim: '\n', want: 2},
+		{title: "all", data: "a\nb\nc\n", n: 3, delim: '\n', want: 0},
+		{title: "more", data: "a\nb\nc\n", n: 10, delim: '\n', want: 0},
+		{title: "partial", data: "a\nb\nc", n: 1, delim: '\n', want: 4},
+		{title: "delimiter", data: "a\nb\x00c\x00", n: 1, delim: 0, want: 4},
+		{title: "long", data: strings.Repeat("x", 100000) + "\nlast\n", n: 1, delim: '\n', want: 100001},
+		{title: "long 2", data: "first\n" + strings.Repeat("x", 100000) + "\n", n: 1, delim: '\n', want: 6},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := internal.LastLinesOffset(strings.NewReader(tc.data), int64(len(tc.data)), tc.n, tc.delim)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFileLastLinesOffset(t *testing.T) {
+	f := test.NewTmpFile(t)
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	fmt.Fprint(f.File(), "a\nb\nc\n")
+	got, err := internal.FileLastLinesOffset(f.Name(), 2, '\n')
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), got)
+
+	_, err = internal.FileLastLinesOffset(f.Name()+".missing", 2, '\n')
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLinesOffset(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		data  string
+		n     int64
+		delim byte
+		want  int64
+	}{
+		{title: "zero", data: "a\nb\n", n: 0, delim: '\n', want: 0},
+		{title: "first", data: "a\nb\n", n: 1, delim: '\n', want: 2},
+		{title: "all", data: "a\nb\n", n: 2, delim: '\n', want: 4},
+		{title: "more", data: "a\nb", n: 5, delim: '\n', want: 3},
+		{title: "delimiter", data: "a\nb\x00c\x00", n: 1, delim: 0, want: 4},
+		{title: "long", data: strings.Repeat("x", 100000) + "\nlast\n", n: 1, delim: '\n', want: 100001},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := internal.LinesOffset(strings.NewReader(tc.data), tc.n, tc.delim)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Package protowire provides the encoding of the protocol buffers wire format.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Type is the wire type.
+type Type int
+
+const (
+	VarintType  Type = 0
+	Fixed64Type Type = 1
+	BytesType   Type = 2
+	Fixed32Type Type = 5
+)
+
+// AppendTag appends the tag of the field.
+func AppendTag(b []byte, num int, typ Type) []byte {
+	return AppendVarint(b, uint64(num)<<3|uint64(typ))
+}
+
+// AppendVarint appends the varint.
+func AppendVarint(b []byte, v uint64) []byte { return binary.AppendUvarint(b, v) }
+
+// AppendFixed64 appends the little endian 64 bits.
+func AppendFixed64(b []byte, v uint64) []byte { return binary.LittleEndian.AppendUint64(b, v) }
+
+// AppendFixed32 appends the little endian 32 bits.
+func AppendFixed32(b []byte, v uint32) []byte { return binary.LittleEndian.AppendUint32(b, v) }
+
+// AppendBytes appends the length-prefixed bytes.
+func AppendBytes(b []byte, v []byte) []byte {
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendString appends the length-prefixed string.
+func AppendString(b []byte, v string) []byte {
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendVarintField appends the varint field, omitted if zero.
+func AppendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, VarintType)
+	return AppendVarint(b, v)
+}
+
+// AppendFixed64Field appends the fixed64 field, omitted if zero.
+func AppendFixed64Field(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, Fixed64Type)
+	return AppendFixed64(b, v)
+}
+
+// AppendFixed32Field appends the fixed32 field, omitted if zero.
+func AppendFixed32Field(b []byte, num int, v uint32) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, Fixed32Type)
+	return AppendFixed32(b, v)
+}
+
+// AppendDoubleField appends the double field, omitted if zero.
+func AppendDoubleField(b []byte, num int, v float64) []byte {
+	return AppendFixed64Field(b, num, math.Float64bits(v))
+}
+
+// AppendStringField appends the string field, omitted if empty.
+func AppendStringField(b []byte, num int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = AppendTag(b, num, BytesType)
+	return AppendString(b, v)
+}
+
+// AppendBytesField appends the bytes field, omitted if empty.
+func AppendBytesField(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = AppendTag(b, num, BytesType)
+	return AppendBytes(b, v)
+}
+
+// AppendMessageField appends the embedded message field written by f.
+// The message is not omitted even if empty.
+func AppendMessageField(b []byte, num int, f func([]byte) []byte) []byte {
+	b = AppendTag(b, num, BytesType)
+	// reserve the length prefix and move the message if it does not fit
+	start := len(b)
+	b = append(b, 0)
+	b = f(b)
+	size := len(b) - start - 1
+	if size < 0x80 {
+		b[start] = byte(size)
+		return b
+	}
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(size))
+	b = append(b, prefix[:n-1]...)
+	copy(b[start+n:], b[start+1:start+1+size])
+	copy(b[start:], prefix[:n])
+	return b
+}
+
+// ErrInvalid means that the bytes are not the valid wire format.
+var ErrInvalid = errors.New("invalid wire format")
+
+// Field is a decoded field.
+type Field struct {
+	Num  int
+	Type Type
+	// Uint is the value of the varint, fixed64 and fixed32 fields.
+	Uint uint64
+	// Bytes is the value of the bytes field.
+	Bytes []byte
+}
+
+// ConsumeField decodes the field at the head of b.
+// Returns the field and the size of it.
+func ConsumeField(b []byte) (*Field, int, error) {
+	tag, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, ErrInvalid
+	}
+	f := &Field{
+		Num:  int(tag >> 3),
+		Type: Type(tag & 7),
+	}
+	switch f.Type {
+	case VarintType:
+		v, m := binary.Uvarint(b[n:])
+		if m <= 0 {
+			return nil, 0, ErrInvalid
+		}
+		f.Uint = v
+		return f, n + m, nil
+	case Fixed64Type:
+		if len(b) < n+8 {
+			return nil, 0, ErrInvalid
+		}
+		f.Uint = binary.LittleEndian.Uint64(b[n:])
+		return f, n + 8, nil
+	case Fixed32Type:
+		if len(b) < n+4 {
+			return nil, 0, ErrInvalid
+		}
+		f.Uint = uint64(binary.LittleEndian.Uint32(b[n:]))
+		return f, n + 4, nil
+	case BytesType:
+		size, m := binary.Uvarint(b[n:])
+		if m <= 0 || uint64(len(b)-n-m) < size {
+			return nil, 0, ErrInvalid
+		}
+		f.Bytes = b[n+m : n+m+int(size)]
+		return f, n + m + int(size), nil
+	default:
+		return nil, 0, ErrInvalid
+	}
+}
+
+// Fields decodes all the fields of the message.
+func Fields(b []byte) ([]*Field, error) {
+	var fs []*Field
+	for len(b) > 0 {
+		f, n, err := ConsumeField(b)
+		if err != nil {
+			return nil, err
+		}
+		fs = append(fs, f)
+		b = b[n:]
+	}
+	return fs, nil
+}
@@ -0,0 +1,52 @@
+package protowire_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gotailf/internal/protowire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppend(t *testing.T) {
+	// message Test1 { int32 a = 1; } with a = 150
+	assert.Equal(t, []byte{0x08, 0x96, 0x01}, protowire.AppendVarintField(nil, 1, 150))
+	// message Test2 { string b = 2; } with b = "testing"
+	assert.Equal(t, append([]byte{0x12, 0x07}, "testing"...), protowire.AppendStringField(nil, 2, "testing"))
+	// message Test3 { Test1 c = 3; }
+	assert.Equal(t, []byte{0x1a, 0x03, 0x08, 0x96, 0x01}, protowire.AppendMessageField(nil, 3, func(b []byte) []byte {
+		return protowire.AppendVarintField(b, 1, 150)
+	}))
+	assert.Equal(t, 0, len(protowire.AppendStringField(nil, 1, "")))
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	var b []byte
+	b = protowire.AppendVarintField(b, 1, 1<<40)
+	b = protowire.AppendFixed64Field(b, 2, 12345)
+	b = protowire.AppendFixed32Field(b, 3, 678)
+	b = protowire.AppendMessageField(b, 4, func(b []byte) []byte {
+		return protowire.AppendStringField(b, 1, long)
+	})
+	b = protowire.AppendBytesField(b, 5, []byte("bytes"))
+
+	fs, err := protowire.Fields(b)
+	if !assert.Nil(t, err) || !assert.Equal(t, 5, len(fs)) {
+		return
+	}
+	assert.Equal(t, uint64(1<<40), fs[0].Uint)
+	assert.Equal(t, protowire.Fixed64Type, fs[1].Type)
+	assert.Equal(t, uint64(12345), fs[1].Uint)
+	assert.Equal(t, protowire.Fixed32Type, fs[2].Type)
+	assert.Equal(t, uint64(678), fs[2].Uint)
+	assert.Equal(t, 4, fs[3].Num)
+	inner, err := protowire.Fields(fs[3].Bytes)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(inner)) {
+		assert.Equal(t, long, string(inner[0].Bytes))
+	}
+	assert.Equal(t, "bytes", string(fs[4].Bytes))
+
+	_, err = protowire.Fields(b[:len(b)-1])
+	assert.ErrorIs(t, err, protowire.ErrInvalid)
+}
@@ -0,0 +1,181 @@
+// Package snappy provides the snappy block format.
+//
+// https://github.com/google/snappy/blob/main/format_description.txt
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+)
+
+const (
+	// blockSize is the size of the independently compressed blocks,
+	// so the copy offsets fit in 2 bytes.
+	blockSize = 1 << 16
+	// minMatch is the min length of the copies.
+	minMatch  = 4
+	tableBits = 14
+)
+
+// Encode appends the compressed src to dst.
+func Encode(dst, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	for len(src) > 0 {
+		block := src
+		if len(block) > blockSize {
+			block = block[:blockSize]
+		}
+		src = src[len(block):]
+		dst = encodeBlock(dst, block)
+	}
+	return dst
+}
+
+func load32(b []byte, i int) uint32 { return binary.LittleEndian.Uint32(b[i:]) }
+
+func hash(v uint32) uint32 { return (v * 0x1e35a7bd) >> (32 - tableBits) }
+
+// encodeBlock compresses the block greedily.
+func encodeBlock(dst, src []byte) []byte {
+	var (
+		// table holds the positions+1 of the last 4 bytes of the hashes
+		table [1 << tableBits]int32
+		lit   int
+		s     int
+	)
+	for s+minMatch <= len(src) {
+		v := load32(src, s)
+		h := hash(v)
+		cand := int(table[h]) - 1
+		table[h] = int32(s + 1)
+		if cand < 0 || load32(src, cand) != v {
+			s++
+			continue
+		}
+		dst = emitLiteral(dst, src[lit:s])
+		base := s
+		s += minMatch
+		for c := cand + minMatch; s < len(src) && src[s] == src[c]; c++ {
+			s++
+		}
+		dst = emitCopy(dst, base-cand, s-base)
+		lit = s
+	}
+	return emitLiteral(dst, src[lit:])
+}
+
+func emitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	default:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	}
+	return append(dst, lit...)
+}
+
+func emitCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		// leave at least minMatch bytes
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+}
+
+// ErrCorrupt means that the input is not the valid snappy block.
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+// maxDecodedSize limits the size of the decoded bytes.
+const maxDecodedSize = 1 << 30
+
+// Decode returns the decompressed src.
+func Decode(src []byte) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || size > maxDecodedSize {
+		return nil, ErrCorrupt
+	}
+	var (
+		dst = make([]byte, 0, size)
+		i   = n
+	)
+	for i < len(src) {
+		tag := src[i]
+		var length, offset int
+		switch tag & 3 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			i++
+			if length >= 60 {
+				m := length - 59
+				if i+m > len(src) {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for j := m - 1; j >= 0; j-- {
+					length = length<<8 | int(src[i+j])
+				}
+				i += m
+			}
+			length++
+			if length > len(src)-i || len(dst)+length > int(size) {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[i:i+length]...)
+			i += length
+			continue
+		case tagCopy1:
+			if i+2 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2)&7
+			offset = int(tag&0xe0)<<3 | int(src[i+1])
+			i += 2
+		case tagCopy2:
+			if i+3 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[i+1:]))
+			i += 3
+		case tagCopy4:
+			if i+5 > len(src) {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[i+1:]))
+			i += 5
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+length > int(size) {
+			return nil, ErrCorrupt
+		}
+		// the copy may overlap the output
+		for j := 0; j < length; j++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if len(dst) != int(size) {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
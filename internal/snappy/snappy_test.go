@@ -0,0 +1,72 @@
+package snappy_test
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gotailf/internal/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	random := make([]byte, 200000)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(r.IntN(256))
+	}
+	for _, tc := range []struct {
+		title string
+		src   []byte
+	}{
+		{title: "empty"},
+		{title: "short", src: []byte("abc")},
+		{title: "repeated", src: bytes.Repeat([]byte("a"), 1000)},
+		{title: "lines", src: []byte(strings.Repeat("level=info msg=\"request done\" status=200\n", 5000))},
+		{title: "random", src: random},
+		{title: "long literal", src: random[:70000]},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			enc := snappy.Encode(nil, tc.src)
+			got, err := snappy.Decode(enc)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.True(t, bytes.Equal(tc.src, got))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	src := []byte(strings.Repeat("level=info msg=\"request done\" status=200\n", 1000))
+	assert.Less(t, len(snappy.Encode(nil, src)), len(src)/10)
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("literal and copies", func(t *testing.T) {
+		got, err := snappy.Decode([]byte{
+			10,                    // length
+			2 << 2, 'a', 'b', 'c', // literal "abc"
+			0<<5 | 1<<2 | 1, 3, // copy1 offset 3 length 5
+			1<<2 | 2, 1, 0, // copy2 offset 1 length 2
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "abcabcabbb", string(got))
+	})
+
+	for _, tc := range []struct {
+		title string
+		src   []byte
+	}{
+		{title: "empty"},
+		{title: "short", src: []byte{5, 0, 'a'}},
+		{title: "offset", src: []byte{4, 0, 'a', 0<<5 | 0<<2 | 1, 2}},
+		{title: "truncated literal", src: []byte{3, 2 << 2, 'a'}},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := snappy.Decode(tc.src)
+			assert.ErrorIs(t, err, snappy.ErrCorrupt)
+		})
+	}
+}
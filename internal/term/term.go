@@ -0,0 +1,35 @@
+// Package term provides the raw mode and the size of the terminals.
+package term
+
+import "errors"
+
+// ErrNotSupported means that the platform does not support the terminal operations.
+var ErrNotSupported = errors.New("terminal not supported")
+
+// State is the state of the terminal to restore.
+type State struct {
+	state
+}
+
+// IsTerminal reports whether fd is a terminal.
+func IsTerminal(fd int) bool {
+	_, err := getState(fd)
+	return err == nil
+}
+
+// MakeRaw puts the terminal into the raw mode, returns the previous state.
+// Input is available byte by byte without echo,
+// and the signal keys such as Ctrl-C are read as bytes.
+func MakeRaw(fd int) (*State, error) {
+	return makeRaw(fd)
+}
+
+// Restore restores the terminal to the state.
+func Restore(fd int, s *State) error {
+	return restore(fd, s)
+}
+
+// Size returns the width and the height of the terminal.
+func Size(fd int) (int, int, error) {
+	return getSize(fd)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package term
+
+type state struct{}
+
+func getState(int) (*State, error)  { return nil, ErrNotSupported }
+func makeRaw(int) (*State, error)   { return nil, ErrNotSupported }
+func restore(int, *State) error     { return ErrNotSupported }
+func getSize(int) (int, int, error) { return 0, 0, ErrNotSupported }
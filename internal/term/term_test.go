@@ -0,0 +1,22 @@
+package term_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/gotailf/internal/term"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotTerminal(t *testing.T) {
+	f := test.NewTmpFile(t)
+	defer f.Remove(t)
+	defer f.Close(t)
+	fd := int(f.File().Fd())
+
+	assert.False(t, term.IsTerminal(fd))
+	_, err := term.MakeRaw(fd)
+	assert.NotNil(t, err)
+	_, _, err = term.Size(fd)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,38 @@
+package internal
+
+import "time"
+
+// Ticker is a time.Ticker that never ticks if the interval is not positive.
+type Ticker struct {
+	ticker   *time.Ticker
+	interval time.Duration
+}
+
+func NewTicker(interval time.Duration) *Ticker {
+	t := &Ticker{interval: interval}
+	if interval > 0 {
+		t.ticker = time.NewTicker(interval)
+	}
+	return t
+}
+
+// C returns the channel of the ticks, nil if disabled.
+func (t *Ticker) C() <-chan time.Time {
+	if t.ticker == nil {
+		return nil
+	}
+	return t.ticker.C
+}
+
+// Reset restarts the interval.
+func (t *Ticker) Reset() {
+	if t.ticker != nil {
+		t.ticker.Reset(t.interval)
+	}
+}
+
+func (t *Ticker) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+}
@@ -0,0 +1,31 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicker(t *testing.T) {
+	t.Run("tick", func(t *testing.T) {
+		ticker := internal.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		select {
+		case <-ticker.C():
+		case <-time.After(time.Second):
+			t.Fatal("no ticks")
+		}
+		ticker.Reset()
+	})
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		t.Run("disabled "+interval.String(), func(t *testing.T) {
+			ticker := internal.NewTicker(interval)
+			defer ticker.Stop()
+			ticker.Reset()
+			assert.Nil(t, ticker.C())
+		})
+	}
+}
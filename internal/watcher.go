@@ -14,8 +14,15 @@ type (
 
 	watcher struct {
 		filename string
-		size     int64
-		interval time.Duration
+		// opened file of the filename, nil if not given.
+		file File
+		// watch file instead of filename.
+		descriptor bool
+		// the number of the unchanged stats to check that filename is file.
+		maxUnchangedStats int
+		unchanged         int
+		size              int64
+		interval          time.Duration
 	}
 
 	FileChangeEventType int
@@ -51,8 +58,44 @@ func NewWatcher(filename string, interval time.Duration) Watcher {
 	}
 }
 
+// NewFileWatcher returns a new Watcher of the file opened by the filename.
+// If descriptor, watches the file even if the filename is moved or removed, never FileChangeEventGone.
+// Otherwise, when the size of the filename is unchanged for maxUnchangedStats checks,
+// FileChangeEventGone if the filename is not the file, e.g. replaced by the file of the same size.
+// Zero maxUnchangedStats disables the checks.
+func NewFileWatcher(filename string, file File, interval time.Duration, descriptor bool, maxUnchangedStats int) Watcher {
+	return &watcher{
+		filename:          filename,
+		file:              file,
+		descriptor:        descriptor,
+		maxUnchangedStats: maxUnchangedStats,
+		interval:          interval,
+	}
+}
+
+func (s *watcher) stat() (os.FileInfo, error) {
+	if s.descriptor {
+		return s.file.Stat()
+	}
+	return os.Stat(s.filename)
+}
+
+// replaced returns true if the filename is not the file.
+func (s *watcher) replaced(stat os.FileInfo) bool {
+	if s.descriptor || s.file == nil || s.maxUnchangedStats <= 0 {
+		return false
+	}
+	s.unchanged++
+	if s.unchanged < s.maxUnchangedStats {
+		return false
+	}
+	s.unchanged = 0
+	fstat, err := s.file.Stat()
+	return err == nil && !os.SameFile(stat, fstat)
+}
+
 func (s *watcher) Watch(ctx context.Context) (<-chan FileChangeEvent, error) {
-	stat, err := os.Stat(s.filename)
+	stat, err := s.stat()
 	if err != nil {
 		return nil, err
 	}
@@ -74,14 +117,17 @@ func (s *watcher) watch(ctx context.Context, eventC chan<- FileChangeEvent) {
 			t.Stop()
 			return
 		case <-t.C:
-			stat, err := os.Stat(s.filename)
-			if err != nil {
+			stat, err := s.stat()
+			if err != nil || (s.size == stat.Size() && s.replaced(stat)) {
 				eventC <- &fileChangeEvent{
 					typ: FileChangeEventGone,
 					tim: time.Now(),
 				}
 				return
 			}
+			if s.size != stat.Size() {
+				s.unchanged = 0
+			}
 			switch {
 			case s.size > stat.Size():
 				eventC <- &fileChangeEvent{
@@ -147,4 +147,63 @@ func TestWatcher(t *testing.T) {
 			toEventTypes(got),
 		)
 	})
+
+	t.Run("descriptor moved", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		dest := fmt.Sprintf("%s-moved", f.Name())
+		defer func() {
+			f.Close(t)
+			os.Remove(dest)
+		}()
+		w := internal.NewFileWatcher(f.Name(), f.File(), 50*time.Millisecond, true, 0)
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		eventC, err := w.Watch(ctx)
+		time.AfterFunc(80*time.Millisecond, func() {
+			assert.Nil(t, os.Rename(f.Name(), dest))
+		})
+		time.AfterFunc(180*time.Millisecond, func() {
+			fmt.Fprint(f.File(), "appended")
+		})
+		assert.Nil(t, err)
+		got := []internal.FileChangeEvent{}
+		for ev := range eventC {
+			got = append(got, ev)
+		}
+		assert.Equal(
+			t,
+			[]internal.FileChangeEventType{internal.FileChangeEventAppended},
+			toEventTypes(got),
+		)
+	})
+
+	t.Run("replaced by the same size", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "old")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		w := internal.NewFileWatcher(f.Name(), f.File(), 50*time.Millisecond, false, 2)
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		eventC, err := w.Watch(ctx)
+		time.AfterFunc(30*time.Millisecond, func() {
+			tmp := fmt.Sprintf("%s-new", f.Name())
+			assert.Nil(t, os.WriteFile(tmp, []byte("new"), 0o600))
+			assert.Nil(t, os.Rename(tmp, f.Name()))
+		})
+		assert.Nil(t, err)
+		got := []internal.FileChangeEvent{}
+		for ev := range eventC {
+			got = append(got, ev)
+		}
+		assert.Equal(
+			t,
+			[]internal.FileChangeEventType{internal.FileChangeEventGone},
+			toEventTypes(got),
+		)
+	})
 }
@@ -0,0 +1,36 @@
+package gotailf
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// lines returns the records of the tailer as an iterator.
+func lines(ctx context.Context, t Tailer) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		recordC := t.Records(ctx)
+		defer func() {
+			cancel()
+			// wait for the tailer to close the file and stop the watcher
+			for range recordC {
+			}
+		}()
+		for r := range recordC {
+			if !yield(r, nil) {
+				return
+			}
+		}
+		err := t.Err()
+		if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		yield(nil, err)
+	}
+}
+
+func (s *tailer) Lines(ctx context.Context) iter.Seq2[*Record, error] { return lines(ctx, s) }
+
+func (s *continueTailer) Lines(ctx context.Context) iter.Seq2[*Record, error] { return lines(ctx, s) }
@@ -0,0 +1,96 @@
+package gotailf_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailerLines(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deadline", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "first\nsecond\n")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+		)
+		assert.Nil(t, err)
+		ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+		defer cancel()
+		got := []string{}
+		for r, err := range s.Lines(ctx) {
+			assert.Nil(t, err)
+			got = append(got, r.Text)
+		}
+		assert.Equal(t, []string{"first", "second"}, got)
+	})
+
+	t.Run("break", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "first\nsecond\nthird\n")
+		defer func() {
+			f.Close(t)
+			f.Remove(t)
+		}()
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+			gotailf.WithBufferSize(0),
+		)
+		assert.Nil(t, err)
+		got := []string{}
+		for r, err := range s.Lines(context.TODO()) {
+			assert.Nil(t, err)
+			got = append(got, r.Text)
+			if len(got) == 2 {
+				break
+			}
+		}
+		assert.Equal(t, []string{"first", "second"}, got)
+		pos := s.Pos()
+		fmt.Fprint(f.File(), "fourth\n")
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, pos, s.Pos(), "stopped")
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "removed\n")
+		f.Close(t)
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+		)
+		assert.Nil(t, err)
+		time.AfterFunc(80*time.Millisecond, func() {
+			f.Remove(t)
+		})
+		var (
+			got  = []string{}
+			errs []error
+		)
+		for r, err := range s.Lines(context.TODO()) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			got = append(got, r.Text)
+		}
+		assert.Equal(t, []string{"removed"}, got)
+		assert.Equal(t, []error{gotailf.ErrFileGone}, errs)
+	})
+}
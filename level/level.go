@@ -0,0 +1,227 @@
+// Package level provides the detection of log levels.
+package level
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/berquerant/gotailf/parse"
+)
+
+// Level is a normalized log level.
+type Level int
+
+const (
+	// Unknown means that the level is not detected.
+	Unknown Level = iota
+	Trace
+	Debug
+	Info
+	Warn
+	Error
+	Fatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case Trace:
+		return "trace"
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	case Fatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) { return []byte(l.String()), nil }
+
+// ErrUnknownLevel means that the level name is invalid.
+var ErrUnknownLevel = errors.New("unknown level")
+
+// Parse returns the level of the name.
+// Accepts common aliases, e.g. warning, err and critical.
+func Parse(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace", "trc", "finest", "verbose":
+		return Trace, nil
+	case "debug", "dbg", "fine":
+		return Debug, nil
+	case "info", "inf", "information", "informational", "notice":
+		return Info, nil
+	case "warn", "wrn", "warning":
+		return Warn, nil
+	case "error", "err", "eror", "severe":
+		return Error, nil
+	case "fatal", "ftl", "critical", "crit", "panic", "emerg", "emergency", "alert":
+		return Fatal, nil
+	default:
+		return Unknown, fmt.Errorf("%w: %s", ErrUnknownLevel, name)
+	}
+}
+
+// FromSyslogSeverity returns the level of the syslog severity (RFC 5424), 0 (emergency) to 7 (debug).
+func FromSyslogSeverity(severity int) Level {
+	switch {
+	case severity < 0:
+		return Unknown
+	case severity <= 2:
+		return Fatal
+	case severity == 3:
+		return Error
+	case severity == 4:
+		return Warn
+	case severity <= 6:
+		return Info
+	case severity == 7:
+		return Debug
+	default:
+		return Unknown
+	}
+}
+
+// SyslogSeverity returns the syslog severity (RFC 5424) of the level.
+// Returns -1 if the level is unknown.
+func (l Level) SyslogSeverity() int {
+	switch l {
+	case Fatal:
+		return 2
+	case Error:
+		return 3
+	case Warn:
+		return 4
+	case Info:
+		return 6
+	case Debug, Trace:
+		return 7
+	default:
+		return -1
+	}
+}
+
+// fromNumber returns the level of the bunyan/pino style number, 10 (trace) to 60 (fatal).
+func fromNumber(n float64) Level {
+	switch {
+	case n >= 60:
+		return Fatal
+	case n >= 50:
+		return Error
+	case n >= 40:
+		return Warn
+	case n >= 30:
+		return Info
+	case n >= 20:
+		return Debug
+	case n >= 10:
+		return Trace
+	default:
+		return Unknown
+	}
+}
+
+var (
+	// levelKeys are the field names of the level.
+	levelKeys = []string{"level", "lvl", "loglevel", "log.level", "levelname", "level_name", "@l"}
+	// severityKeys are the field names of the syslog severity or the level names.
+	severityKeys = []string{"severity", "syslog.severity", "severity_text"}
+)
+
+// FromFields detects the level from the parsed fields.
+// Numeric levels are bunyan/pino style and numeric severities are syslog severities.
+func FromFields(fields parse.Fields) Level {
+	for _, k := range levelKeys {
+		v, ok := fields.Get(k)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			if l, err := Parse(v); err == nil {
+				return l
+			}
+		case float64:
+			if l := fromNumber(v); l != Unknown {
+				return l
+			}
+		}
+	}
+	for _, k := range severityKeys {
+		v, ok := fields.Get(k)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			if l, err := Parse(v); err == nil {
+				return l
+			}
+		case float64:
+			if l := FromSyslogSeverity(int(v)); l != Unknown {
+				return l
+			}
+		}
+	}
+	return Unknown
+}
+
+var (
+	// syslogPriorityRegexp matches the PRI part of syslog, e.g. <13>.
+	syslogPriorityRegexp = regexp.MustCompile(`^<(\d{1,3})>`)
+	// glogRegexp matches the header of glog, e.g. E1016 12:00:00.000000.
+	glogRegexp = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}`)
+	// keyValueRegexp matches level=warn, "level":"warn" and so on.
+	keyValueRegexp = regexp.MustCompile(`(?i)\b(?:level|lvl|severity)"?\s*[=:]\s*"?([a-z]+)`)
+	// bracketRegexp matches [ERROR], <warn>, (info) and so on.
+	bracketRegexp = regexp.MustCompile(`(?i)[\[(<](trace|debug|info|notice|warn|warning|error|err|fatal|crit|critical|panic|emerg|alert)[\])>]`)
+	// wordRegexp matches the upper case level names.
+	wordRegexp = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|NOTICE|WARN|WARNING|ERROR|FATAL|CRITICAL|PANIC)\b`)
+)
+
+// FromText detects the level from the line by the common textual patterns.
+func FromText(text string) Level {
+	if m := syslogPriorityRegexp.FindStringSubmatch(text); m != nil {
+		if pri, err := strconv.Atoi(m[1]); err == nil && pri <= 191 {
+			return FromSyslogSeverity(pri % 8)
+		}
+	}
+	if m := glogRegexp.FindStringSubmatch(text); m != nil {
+		switch m[1] {
+		case "I":
+			return Info
+		case "W":
+			return Warn
+		case "E":
+			return Error
+		case "F":
+			return Fatal
+		}
+	}
+	for _, re := range []*regexp.Regexp{keyValueRegexp, bracketRegexp, wordRegexp} {
+		if m := re.FindStringSubmatch(text); m != nil {
+			if l, err := Parse(m[1]); err == nil {
+				return l
+			}
+		}
+	}
+	return Unknown
+}
+
+// Detect detects the level of the line.
+// Prefers the fields if any.
+func Detect(text string, fields parse.Fields) Level {
+	if l := FromFields(fields); l != Unknown {
+		return l
+	}
+	return FromText(text)
+}
@@ -0,0 +1,105 @@
+package level_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []*struct {
+		title  string
+		text   string
+		fields parse.Fields
+		want   level.Level
+	}{
+		{
+			title:  "field name",
+			fields: parse.Fields{"level": "WARNING"},
+			want:   level.Warn,
+		},
+		{
+			title:  "field number",
+			fields: parse.Fields{"level": float64(50)},
+			want:   level.Error,
+		},
+		{
+			title:  "syslog severity field",
+			fields: parse.Fields{"severity": float64(2)},
+			want:   level.Fatal,
+		},
+		{
+			title:  "fields take precedence",
+			text:   "[ERROR] x",
+			fields: parse.Fields{"lvl": "debug"},
+			want:   level.Debug,
+		},
+		{
+			title:  "fallback to text",
+			text:   "[ERROR] x",
+			fields: parse.Fields{"msg": "x"},
+			want:   level.Error,
+		},
+		{
+			title: "bracket",
+			text:  "2021-10-01 12:00:00 [warn] disk is almost full",
+			want:  level.Warn,
+		},
+		{
+			title: "glog",
+			text:  "E1016 12:00:00.000000    1 main.go:10] failed",
+			want:  level.Error,
+		},
+		{
+			title: "key value",
+			text:  `time=1 level=trace msg=x`,
+			want:  level.Trace,
+		},
+		{
+			title: "json text",
+			text:  `{"level": "info"}`,
+			want:  level.Info,
+		},
+		{
+			title: "syslog priority",
+			text:  "<12>Oct 16 12:00:00 host app: x",
+			want:  level.Warn,
+		},
+		{
+			title: "upper case word",
+			text:  "2021-10-01 FATAL out of memory",
+			want:  level.Fatal,
+		},
+		{
+			title: "lower case word is not a level",
+			text:  "no error found",
+			want:  level.Unknown,
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, level.Detect(tc.text, tc.fields))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	l, err := level.Parse("Critical")
+	assert.Nil(t, err)
+	assert.Equal(t, level.Fatal, l)
+	_, err = level.Parse("loud")
+	assert.True(t, errors.Is(err, level.ErrUnknownLevel))
+	assert.True(t, level.Warn > level.Info)
+	assert.Equal(t, "warn", level.Warn.String())
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	for _, l := range []level.Level{level.Debug, level.Info, level.Warn, level.Error, level.Fatal} {
+		assert.Equal(t, l, level.FromSyslogSeverity(l.SyslogSeverity()), l.String())
+	}
+	assert.Equal(t, 7, level.Trace.SyslogSeverity())
+	assert.Equal(t, -1, level.Unknown.SyslogSeverity())
+}
@@ -0,0 +1,191 @@
+package gotailf
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FanIn merges the record streams into one in arrival order.
+// The result is closed when all the streams are closed.
+func FanIn(streams ...<-chan *Record) <-chan *Record {
+	var (
+		resultC = make(chan *Record, len(streams))
+		wg      sync.WaitGroup
+	)
+	wg.Add(len(streams))
+	for _, s := range streams {
+		go func(s <-chan *Record) {
+			defer wg.Done()
+			for r := range s {
+				resultC <- r
+			}
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(resultC)
+	}()
+	return resultC
+}
+
+// MergeConfig represents Merge() configurations.
+type MergeConfig struct {
+	// Window is the lateness window.
+	// Records are held up to the window to be ordered by event time.
+	// Default is 1 second.
+	Window time.Duration
+	// EventTime returns the event time of the record.
+	// Default is Record.EventTime, or Record.Time if EventTime is zero.
+	EventTime func(r *Record) time.Time
+}
+
+func newDefaultMergeConfig() *MergeConfig {
+	return &MergeConfig{
+		Window: time.Second,
+		EventTime: func(r *Record) time.Time {
+			if r.EventTime.IsZero() {
+				return r.Time
+			}
+			return r.EventTime
+		},
+	}
+}
+
+type MergeOption func(*MergeConfig)
+
+// WithMergeWindow sets MergeConfig.Window.
+func WithMergeWindow(window time.Duration) MergeOption {
+	return func(c *MergeConfig) {
+		c.Window = window
+	}
+}
+
+// WithMergeEventTime sets MergeConfig.EventTime.
+func WithMergeEventTime(f func(r *Record) time.Time) MergeOption {
+	return func(c *MergeConfig) {
+		c.EventTime = f
+	}
+}
+
+// Merge merges the record streams into one in event time order.
+//
+// Each record is held up to the window after its arrival,
+// and the held records are yielded in event time order.
+// A record whose event time is before the last yielded record is late,
+// it is yielded immediately with Record.Late.
+// Held records are flushed when all the streams are closed.
+func Merge(ctx context.Context, streams []<-chan *Record, opts ...MergeOption) <-chan *Record {
+	config := newDefaultMergeConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	resultC := make(chan *Record, len(streams))
+	go func() {
+		newMerger(config).loop(ctx, FanIn(streams...), resultC)
+		close(resultC)
+	}()
+	return resultC
+}
+
+type heldRecord struct {
+	record    *Record
+	eventTime time.Time
+	deadline  time.Time
+}
+
+type merger struct {
+	config *MergeConfig
+	// held records in event time order.
+	held []*heldRecord
+	// event time of the last yielded record.
+	last time.Time
+}
+
+func newMerger(config *MergeConfig) *merger {
+	return &merger{
+		config: config,
+	}
+}
+
+func (s *merger) add(r *Record, now time.Time) (*Record, bool) {
+	eventTime := s.config.EventTime(r)
+	if eventTime.Before(s.last) {
+		r.Late = true
+		return r, true
+	}
+	// after the records with the same event time to keep the arrival order
+	i := sort.Search(len(s.held), func(i int) bool { return s.held[i].eventTime.After(eventTime) })
+	s.held = append(s.held, nil)
+	copy(s.held[i+1:], s.held[i:])
+	s.held[i] = &heldRecord{
+		record:    r,
+		eventTime: eventTime,
+		deadline:  now.Add(s.config.Window),
+	}
+	return nil, false
+}
+
+// expired returns the records to be yielded.
+// All the records up to the last record whose deadline passed.
+func (s *merger) expired(now time.Time) []*Record {
+	n := 0
+	for i, x := range s.held {
+		if !x.deadline.After(now) {
+			n = i + 1
+		}
+	}
+	return s.pop(n)
+}
+
+func (s *merger) pop(n int) []*Record {
+	if n == 0 {
+		return nil
+	}
+	rs := make([]*Record, n)
+	for i, x := range s.held[:n] {
+		rs[i] = x.record
+	}
+	s.last = s.held[n-1].eventTime
+	s.held = s.held[n:]
+	return rs
+}
+
+func (s *merger) loop(ctx context.Context, inC <-chan *Record, resultC chan<- *Record) {
+	interval := s.config.Window / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the streams will be closed
+			for r := range inC {
+				if late, ok := s.add(r, time.Now()); ok {
+					resultC <- late
+				}
+			}
+			for _, r := range s.pop(len(s.held)) {
+				resultC <- r
+			}
+			return
+		case r, ok := <-inC:
+			if !ok {
+				for _, r := range s.pop(len(s.held)) {
+					resultC <- r
+				}
+				return
+			}
+			if late, ok := s.add(r, time.Now()); ok {
+				resultC <- late
+			}
+		case now := <-t.C:
+			for _, r := range s.expired(now) {
+				resultC <- r
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gotailf_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanIn(t *testing.T) {
+	t.Parallel()
+	var (
+		a = make(chan *gotailf.Record, 2)
+		b = make(chan *gotailf.Record, 1)
+	)
+	a <- &gotailf.Record{Text: "a1"}
+	a <- &gotailf.Record{Text: "a2"}
+	b <- &gotailf.Record{Text: "b1"}
+	close(a)
+	close(b)
+	got := []string{}
+	for r := range gotailf.FanIn(a, b) {
+		got = append(got, r.Text)
+	}
+	assert.ElementsMatch(t, []string{"a1", "a2", "b1"}, got)
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+	t.Run("reorder within window", func(t *testing.T) {
+		t.Parallel()
+		var (
+			a = make(chan *gotailf.Record)
+			b = make(chan *gotailf.Record)
+		)
+		go func() {
+			a <- &gotailf.Record{Text: "a3", EventTime: at(3)}
+			b <- &gotailf.Record{Text: "b1", EventTime: at(1)}
+			a <- &gotailf.Record{Text: "a4", EventTime: at(4)}
+			b <- &gotailf.Record{Text: "b2", EventTime: at(2)}
+			time.Sleep(150 * time.Millisecond)
+			// older than the yielded records
+			b <- &gotailf.Record{Text: "late", EventTime: at(0)}
+			a <- &gotailf.Record{Text: "a5", EventTime: at(5)}
+			close(a)
+			close(b)
+		}()
+		got := []string{}
+		late := []bool{}
+		for r := range gotailf.Merge(context.TODO(), []<-chan *gotailf.Record{a, b},
+			gotailf.WithMergeWindow(50*time.Millisecond),
+		) {
+			got = append(got, r.Text)
+			late = append(late, r.Late)
+		}
+		assert.Equal(t, []string{"b1", "b2", "a3", "a4", "late", "a5"}, got)
+		assert.Equal(t, []bool{false, false, false, false, true, false}, late)
+	})
+
+	t.Run("flush on close", func(t *testing.T) {
+		t.Parallel()
+		a := make(chan *gotailf.Record, 3)
+		a <- &gotailf.Record{Text: "2", EventTime: at(2)}
+		a <- &gotailf.Record{Text: "1", EventTime: at(1)}
+		a <- &gotailf.Record{Text: "1'", EventTime: at(1)}
+		close(a)
+		got := []string{}
+		for r := range gotailf.Merge(context.TODO(), []<-chan *gotailf.Record{a},
+			gotailf.WithMergeWindow(time.Hour),
+		) {
+			got = append(got, r.Text)
+		}
+		assert.Equal(t, []string{"1", "1'", "2"}, got)
+	})
+}
@@ -0,0 +1,159 @@
+package gotailf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what to do when the consumer is slow and the buffer of the tailer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading until the buffer has room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the line that does not fit in the buffer.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest line in the buffer to make room.
+	OverflowDropOldest
+	// OverflowSkipToEOF drops the lines up to the end of the file read so far,
+	// and yields a marker line "N lines skipped" instead of them.
+	OverflowSkipToEOF
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowSkipToEOF:
+		return "skip"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownOverflowPolicy means that the overflow policy name is invalid.
+var ErrUnknownOverflowPolicy = errors.New("unknown overflow policy")
+
+// ParseOverflowPolicy returns the overflow policy of the name, the result of OverflowPolicy.String().
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch strings.ToLower(name) {
+	case "block":
+		return OverflowBlock, nil
+	case "drop-newest":
+		return OverflowDropNewest, nil
+	case "drop-oldest":
+		return OverflowDropOldest, nil
+	case "skip":
+		return OverflowSkipToEOF, nil
+	default:
+		return OverflowBlock, fmt.Errorf("%w: %s", ErrUnknownOverflowPolicy, name)
+	}
+}
+
+// skippedText returns the text of the marker of OverflowSkipToEOF.
+func skippedText(n int64) string { return fmt.Sprintf("%d lines skipped", n) }
+
+// skippedRecord returns the marker record of OverflowSkipToEOF.
+func skippedRecord(first *Record, n int64) *Record {
+	return &Record{
+		File:    first.File,
+		Offset:  first.Offset,
+		End:     first.Offset,
+		Text:    skippedText(n),
+		Time:    first.Time,
+		Skipped: n,
+	}
+}
+
+// overflow sends the values to the channel by the policy.
+type overflow[T any] struct {
+	policy OverflowPolicy
+	c      chan T
+	// dropped is the number of the dropped values.
+	dropped *atomic.Int64
+	// marker returns the marker of the skipped values.
+	// first is the first skipped value.
+	marker func(first T, n int64) T
+	// done cancels the blocking sends, nil never cancels.
+	done <-chan struct{}
+	// skipped values until the end of the read.
+	skipped int64
+	first   T
+}
+
+func newOverflow[T any](policy OverflowPolicy, c chan T, dropped *atomic.Int64, marker func(first T, n int64) T) *overflow[T] {
+	return &overflow[T]{
+		policy:  policy,
+		c:       c,
+		dropped: dropped,
+		marker:  marker,
+	}
+}
+
+func (s *overflow[T]) send(v T) {
+	switch s.policy {
+	case OverflowDropNewest:
+		select {
+		case s.c <- v:
+		default:
+			s.dropped.Add(1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.c <- v:
+				return
+			default:
+			}
+			if cap(s.c) == 0 {
+				// nothing to drop
+				s.dropped.Add(1)
+				return
+			}
+			select {
+			case <-s.c:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case OverflowSkipToEOF:
+		if s.skipped > 0 {
+			s.skipped++
+			return
+		}
+		select {
+		case s.c <- v:
+		default:
+			s.skipped = 1
+			s.first = v
+		}
+	default:
+		s.block(v)
+	}
+}
+
+func (s *overflow[T]) block(v T) {
+	select {
+	case s.c <- v:
+	case <-s.done:
+	}
+}
+
+// flush is called when the read reaches the end of the file.
+// Sends the marker if some values are skipped.
+func (s *overflow[T]) flush() {
+	if s.skipped == 0 {
+		return
+	}
+	s.block(s.marker(s.first, s.skipped))
+	s.dropped.Add(s.skipped)
+	var zero T
+	s.skipped = 0
+	s.first = zero
+}
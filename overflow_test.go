@@ -0,0 +1,109 @@
+package gotailf_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, p := range []gotailf.OverflowPolicy{
+		gotailf.OverflowBlock,
+		gotailf.OverflowDropNewest,
+		gotailf.OverflowDropOldest,
+		gotailf.OverflowSkipToEOF,
+	} {
+		got, err := gotailf.ParseOverflowPolicy(p.String())
+		assert.Nil(t, err)
+		assert.Equal(t, p, got)
+	}
+	_, err := gotailf.ParseOverflowPolicy("unknown")
+	assert.ErrorIs(t, err, gotailf.ErrUnknownOverflowPolicy)
+}
+
+func TestTailerOverflow(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []*struct {
+		policy  gotailf.OverflowPolicy
+		want    []string
+		skipped []int64
+		dropped int64
+	}{
+		{
+			policy:  gotailf.OverflowBlock,
+			want:    []string{"l1", "l2", "l3", "l4", "l5", "l6"},
+			skipped: []int64{0, 0, 0, 0, 0, 0},
+		},
+		{
+			policy:  gotailf.OverflowDropNewest,
+			want:    []string{"l1", "l2", "l6"},
+			skipped: []int64{0, 0, 0},
+			dropped: 3,
+		},
+		{
+			policy:  gotailf.OverflowDropOldest,
+			want:    []string{"l4", "l5", "l6"},
+			skipped: []int64{0, 0, 0},
+			dropped: 3,
+		},
+		{
+			policy:  gotailf.OverflowSkipToEOF,
+			want:    []string{"l1", "l2", "3 lines skipped", "l6"},
+			skipped: []int64{0, 0, 3, 0},
+			dropped: 3,
+		},
+	} {
+		for _, kind := range []string{"tailer", "continue"} {
+			tc := tc
+			t.Run(kind+" "+tc.policy.String(), func(t *testing.T) {
+				t.Parallel()
+				f := test.NewTmpFile(t)
+				fmt.Fprint(f.File(), "l1\nl2\nl3\nl4\nl5\n")
+				defer func() {
+					f.Close(t)
+					f.Remove(t)
+				}()
+				opts := []gotailf.Option{
+					gotailf.WithFlushInterval(50 * time.Millisecond),
+					gotailf.WithOffset(0),
+					gotailf.WithBufferSize(2),
+					gotailf.WithOverflowPolicy(tc.policy),
+				}
+				var s gotailf.Tailer
+				if kind == "continue" {
+					// the policy applies to the channel of the continue tailer, not buffered twice
+					s = gotailf.NewContinueTailer(f.Name(), opts...)
+				} else {
+					var err error
+					s, err = gotailf.NewTailer(f.Name(), opts...)
+					assert.Nil(t, err)
+				}
+				ctx, cancel := context.WithTimeout(context.TODO(), 400*time.Millisecond)
+				defer cancel()
+				recordC := s.Records(ctx)
+				// slow consumer
+				time.Sleep(100 * time.Millisecond)
+				time.AfterFunc(150*time.Millisecond, func() {
+					fmt.Fprint(f.File(), "l6\n")
+				})
+				var (
+					got     = []string{}
+					skipped = []int64{}
+				)
+				for r := range recordC {
+					got = append(got, r.Text)
+					skipped = append(skipped, r.Skipped)
+				}
+				assert.Nil(t, s.Err())
+				assert.Equal(t, tc.want, got)
+				assert.Equal(t, tc.skipped, skipped)
+				assert.Equal(t, tc.dropped, s.Dropped())
+			})
+		}
+	}
+}
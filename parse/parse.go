@@ -0,0 +1,367 @@
+// Package parse provides parsers of structured lines.
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields is a parsed line.
+//
+// Values are one of string, float64, bool, nil or []interface{}.
+// Nested JSON objects are flattened into the keys joined by dot.
+type Fields map[string]interface{}
+
+// Get returns the value of the key.
+func (f Fields) Get(key string) (interface{}, bool) {
+	v, ok := f[key]
+	return v, ok
+}
+
+// Keys returns the sorted keys.
+func (f Fields) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Format is a format of a line.
+type Format int
+
+const (
+	// FormatAuto detects the format per line.
+	// JSON if the line looks like a JSON object,
+	// logfmt if the line consists of key=value pairs,
+	// otherwise key=value pairs embedded in the line.
+	FormatAuto Format = iota
+	// FormatJSON is a JSON object.
+	FormatJSON
+	// FormatLogfmt is logfmt, e.g. level=info msg="hello world".
+	FormatLogfmt
+	// FormatKV is key=value pairs embedded in the free text, e.g. GET /api status=200.
+	FormatKV
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatAuto:
+		return "auto"
+	case FormatJSON:
+		return "json"
+	case FormatLogfmt:
+		return "logfmt"
+	case FormatKV:
+		return "kv"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownFormat means that the format name is invalid.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat returns the format of the name.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "auto":
+		return FormatAuto, nil
+	case "json":
+		return FormatJSON, nil
+	case "logfmt":
+		return FormatLogfmt, nil
+	case "kv":
+		return FormatKV, nil
+	default:
+		return FormatAuto, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+}
+
+var (
+	// ErrMalformed means that the line is not the expected format.
+	ErrMalformed = errors.New("malformed line")
+	// ErrNoFields means that the line has no fields.
+	ErrNoFields = errors.New("no fields")
+)
+
+// Parse parses the line as format.
+func Parse(line string, format Format) (Fields, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(line)
+	case FormatLogfmt:
+		return parseLogfmt(line, false)
+	case FormatKV:
+		return parseKV(line)
+	default:
+		return parseAuto(line)
+	}
+}
+
+func parseAuto(line string) (Fields, error) {
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return parseJSON(line)
+	}
+	if fields, err := parseLogfmt(line, true); err == nil {
+		return fields, nil
+	}
+	return parseKV(line)
+}
+
+func parseJSON(line string) (Fields, error) {
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	fields := Fields{}
+	flatten(fields, "", v)
+	return fields, nil
+}
+
+func flatten(fields Fields, prefix string, v map[string]interface{}) {
+	for k, x := range v {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if m, ok := x.(map[string]interface{}); ok {
+			flatten(fields, key, m)
+			continue
+		}
+		fields[key] = x
+	}
+}
+
+// parseLogfmt parses logfmt line.
+// If strict, bare keys (keys without values) are not allowed.
+func parseLogfmt(line string, strict bool) (Fields, error) {
+	fields := Fields{}
+	sc := newScanner(line)
+	for {
+		p, ok, err := sc.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if !p.hasValue {
+			if strict {
+				return nil, fmt.Errorf("%w: bare key %s", ErrMalformed, p.key)
+			}
+			fields[p.key] = true
+			continue
+		}
+		fields[p.key] = p.typedValue()
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoFields
+	}
+	return fields, nil
+}
+
+func parseKV(line string) (Fields, error) {
+	fields := Fields{}
+	sc := newScanner(line)
+	for !sc.eof() {
+		p, ok, err := sc.next()
+		if err == nil && !ok {
+			break
+		}
+		if err != nil || !p.hasValue || !isKVKey(p.key) {
+			sc.skipToken()
+			continue
+		}
+		fields[p.key] = p.typedValue()
+	}
+	if len(fields) == 0 {
+		return nil, ErrNoFields
+	}
+	return fields, nil
+}
+
+func isKVKey(key string) bool {
+	for _, c := range key {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '_', c == '.', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type pair struct {
+	key      string
+	value    string
+	hasValue bool
+	quoted   bool
+}
+
+// typedValue infers the type of the unquoted value.
+func (p pair) typedValue() interface{} {
+	if p.quoted || p.value == "" {
+		return p.value
+	}
+	switch p.value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !isDecimal(p.value) {
+		return p.value
+	}
+	if f, err := strconv.ParseFloat(p.value, 64); err == nil {
+		return f
+	}
+	return p.value
+}
+
+// isDecimal returns true if s is a decimal number like -1.5e3.
+// Rejects the forms strconv.ParseFloat also accepts, e.g. nan, inf and hex,
+// because the fields should be encoded into JSON.
+func isDecimal(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := func() int {
+		start := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		return i - start
+	}
+	n := digits()
+	if i < len(s) && s[i] == '.' {
+		i++
+		n += digits()
+	}
+	if n == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+type scanner struct {
+	line string
+	pos  int
+	// head of the current token.
+	head int
+}
+
+func newScanner(line string) *scanner { return &scanner{line: line} }
+
+func (s *scanner) eof() bool { return s.pos >= len(s.line) }
+
+func (s *scanner) skipSpaces() {
+	for !s.eof() && isSpace(s.line[s.pos]) {
+		s.pos++
+	}
+}
+
+// skipToken moves past the current token, from its head to the next space,
+// so that a broken quoted value does not hide the following pairs.
+// The head is not a space, so the scanner always proceeds.
+func (s *scanner) skipToken() {
+	s.pos = s.head
+	for !s.eof() && !isSpace(s.line[s.pos]) {
+		s.pos++
+	}
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' }
+
+// next reads the next pair.
+// Returns false when no pairs are left.
+func (s *scanner) next() (pair, bool, error) {
+	s.skipSpaces()
+	s.head = s.pos
+	if s.eof() {
+		return pair{}, false, nil
+	}
+	for !s.eof() && !isSpace(s.line[s.pos]) && s.line[s.pos] != '=' {
+		if s.line[s.pos] == '"' {
+			return pair{}, false, fmt.Errorf("%w: quote in key at %d", ErrMalformed, s.pos)
+		}
+		s.pos++
+	}
+	key := s.line[s.head:s.pos]
+	if key == "" {
+		return pair{}, false, fmt.Errorf("%w: empty key at %d", ErrMalformed, s.pos)
+	}
+	if s.eof() || s.line[s.pos] != '=' {
+		return pair{key: key}, true, nil
+	}
+	s.pos++ // skip =
+	if s.eof() || s.line[s.pos] != '"' {
+		start := s.pos
+		for !s.eof() && !isSpace(s.line[s.pos]) {
+			s.pos++
+		}
+		return pair{
+			key:      key,
+			value:    s.line[start:s.pos],
+			hasValue: true,
+		}, true, nil
+	}
+	start := s.pos
+	s.pos++ // skip open quote
+	for !s.eof() && s.line[s.pos] != '"' {
+		if s.line[s.pos] == '\\' {
+			s.pos++
+		}
+		s.pos++
+	}
+	if s.eof() {
+		return pair{}, false, fmt.Errorf("%w: unterminated quote at %d", ErrMalformed, start)
+	}
+	s.pos++ // skip close quote
+	v, err := strconv.Unquote(s.line[start:s.pos])
+	if err != nil {
+		return pair{}, false, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return pair{
+		key:      key,
+		value:    v,
+		hasValue: true,
+		quoted:   true,
+	}, true, nil
+}
+
+// FormatValue returns the string representation of the value of Fields.
+func FormatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,157 @@
+package parse_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/berquerant/gotailf/parse"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []*struct {
+		title  string
+		line   string
+		format parse.Format
+		want   parse.Fields
+		err    error
+	}{
+		{
+			title: "auto json",
+			line:  `{"level":"info","status":200,"ok":true,"req":{"path":"/api"},"tags":["a"],"x":null}`,
+			want: parse.Fields{
+				"level":    "info",
+				"status":   float64(200),
+				"ok":       true,
+				"req.path": "/api",
+				"tags":     []interface{}{"a"},
+				"x":        nil,
+			},
+		},
+		{
+			title: "auto malformed json",
+			line:  `{"level":`,
+			err:   parse.ErrMalformed,
+		},
+		{
+			title: "auto logfmt",
+			line:  `level=warn msg="hello \"world\"" status=503 ok=false empty=`,
+			want: parse.Fields{
+				"level":  "warn",
+				"msg":    `hello "world"`,
+				"status": float64(503),
+				"ok":     false,
+				"empty":  "",
+			},
+		},
+		{
+			title: "auto kv",
+			line:  `GET /api status=200 took=1.5 "quoted text"`,
+			want: parse.Fields{
+				"status": float64(200),
+				"took":   1.5,
+			},
+		},
+		{
+			title: "auto plain",
+			line:  "hello world",
+			err:   parse.ErrNoFields,
+		},
+		{
+			title:  "logfmt bare key",
+			line:   `debug msg=x`,
+			format: parse.FormatLogfmt,
+			want: parse.Fields{
+				"debug": true,
+				"msg":   "x",
+			},
+		},
+		{
+			title:  "logfmt unterminated quote",
+			line:   `msg="x`,
+			format: parse.FormatLogfmt,
+			err:    parse.ErrMalformed,
+		},
+		{
+			title:  "json not object",
+			line:   `[1]`,
+			format: parse.FormatJSON,
+			err:    parse.ErrMalformed,
+		},
+		{
+			title:  "kv skips broken token",
+			line:   `a="b c=1`,
+			format: parse.FormatKV,
+			want: parse.Fields{
+				"c": float64(1),
+			},
+		},
+		{
+			title:  "logfmt not decimal numbers",
+			line:   `a=nan b=inf c=-Infinity d=0x10 e=1e999 f=1_000 g=+.5 h=2E-3 i=. j=1e`,
+			format: parse.FormatLogfmt,
+			want: parse.Fields{
+				"a": "nan",
+				"b": "inf",
+				"c": "-Infinity",
+				"d": "0x10",
+				"e": "1e999",
+				"f": "1_000",
+				"g": 0.5,
+				"h": 0.002,
+				"i": ".",
+				"j": "1e",
+			},
+		},
+		{
+			title:  "kv trailing space",
+			line:   "a=1 b ",
+			format: parse.FormatKV,
+			want: parse.Fields{
+				"a": float64(1),
+			},
+		},
+		{
+			title: "auto trailing space",
+			line:  "GET /api status=200 \t",
+			want: parse.Fields{
+				"status": float64(200),
+			},
+		},
+		{
+			title:  "logfmt trailing space",
+			line:   "a=1 ",
+			format: parse.FormatLogfmt,
+			want: parse.Fields{
+				"a": float64(1),
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := parse.Parse(tc.line, tc.format)
+			if tc.err != nil {
+				assert.True(t, errors.Is(err, tc.err), "%v", err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := parse.ParseFormat("logfmt")
+	assert.Nil(t, err)
+	assert.Equal(t, parse.FormatLogfmt, f)
+	_, err = parse.ParseFormat("xml")
+	assert.True(t, errors.Is(err, parse.ErrUnknownFormat))
+}
+
+func TestFormatValue(t *testing.T) {
+	assert.Equal(t, "null", parse.FormatValue(nil))
+	assert.Equal(t, "1.5", parse.FormatValue(1.5))
+	assert.Equal(t, "200", parse.FormatValue(float64(200)))
+	assert.Equal(t, "true", parse.FormatValue(true))
+	assert.Equal(t, `["a"]`, parse.FormatValue([]interface{}{"a"}))
+}
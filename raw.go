@@ -0,0 +1,139 @@
+package gotailf
+
+import (
+	"sync"
+	"time"
+)
+
+// RawRecord is a line yielded by Tailer.RawBatches() as bytes.
+type RawRecord struct {
+	// Offset is the offset of the head of the line in the target file.
+	Offset int64
+	// Line is the line without the trailing newline.
+	// Valid until RawBatch.Release() is called.
+	Line []byte
+	// Time is the time when the line was read.
+	Time time.Time
+}
+
+// RawBatch is a batch of RawRecords.
+//
+// The lines of the records share the buffer taken from a pool.
+// Release() returns the buffer to the pool, so the batch and its records
+// including Line must not be used after Release().
+// If Release() is not called, the buffer is just garbage collected.
+type RawBatch struct {
+	// File is the name of the target file.
+	File    string
+	Records []RawRecord
+	// Bytes is the total size of the lines.
+	Bytes int
+	buf   []byte
+}
+
+var rawBatchPool = sync.Pool{
+	New: func() interface{} { return new(RawBatch) },
+}
+
+func newRawBatch(file string, count, size int) *RawBatch {
+	b := rawBatchPool.Get().(*RawBatch)
+	b.File = file
+	if cap(b.Records) < count {
+		b.Records = make([]RawRecord, 0, count)
+	}
+	if cap(b.buf) < size {
+		b.buf = make([]byte, 0, size)
+	}
+	return b
+}
+
+// Release returns the batch to the pool.
+// The batch must not be used after Release().
+func (b *RawBatch) Release() {
+	if b == nil {
+		return
+	}
+	for i := range b.Records {
+		b.Records[i] = RawRecord{}
+	}
+	b.Records = b.Records[:0]
+	b.buf = b.buf[:0]
+	b.Bytes = 0
+	b.File = ""
+	rawBatchPool.Put(b)
+}
+
+// rawBatcher accumulates lines into raw batches.
+type rawBatcher struct {
+	file    string
+	config  *Config
+	resultC chan<- *RawBatch
+	batch   *RawBatch
+	// read time of the first record of the batch.
+	since time.Time
+}
+
+func newRawBatcher(file string, config *Config, resultC chan<- *RawBatch) *rawBatcher {
+	return &rawBatcher{
+		file:    file,
+		config:  config,
+		resultC: resultC,
+	}
+}
+
+const defaultRawBatchBufferSize = 1024 * 1024
+
+func (s *rawBatcher) newBatch() *RawBatch {
+	count := s.config.BatchMaxCount
+	if count <= 0 {
+		count = 1024
+	}
+	size := s.config.BatchMaxBytes
+	if size <= 0 {
+		size = defaultRawBatchBufferSize
+	}
+	return newRawBatch(s.file, count, size)
+}
+
+func (s *rawBatcher) add(line []byte, offset int64, now time.Time) {
+	if s.batch != nil && len(s.batch.buf)+len(line) > cap(s.batch.buf) {
+		// keep the lines in the buffer, not to be moved by append
+		s.flush()
+	}
+	if s.batch == nil {
+		s.batch = s.newBatch()
+		s.since = now
+	}
+	var data []byte
+	if len(line) > cap(s.batch.buf) {
+		// too long line
+		data = append([]byte(nil), line...)
+	} else {
+		head := len(s.batch.buf)
+		s.batch.buf = append(s.batch.buf, line...)
+		data = s.batch.buf[head:len(s.batch.buf):len(s.batch.buf)]
+	}
+	s.batch.Records = append(s.batch.Records, RawRecord{
+		Offset: offset,
+		Line:   data,
+		Time:   now,
+	})
+	s.batch.Bytes += len(line)
+	if s.isFull(now) {
+		s.flush()
+	}
+}
+
+func (s *rawBatcher) isFull(now time.Time) bool {
+	return (s.config.BatchMaxCount > 0 && len(s.batch.Records) >= s.config.BatchMaxCount) ||
+		(s.config.BatchMaxBytes > 0 && s.batch.Bytes >= s.config.BatchMaxBytes) ||
+		(s.config.BatchMaxLatency > 0 && now.Sub(s.since) >= s.config.BatchMaxLatency)
+}
+
+func (s *rawBatcher) flush() {
+	if s.batch == nil || len(s.batch.Records) == 0 {
+		return
+	}
+	s.resultC <- s.batch
+	s.batch = nil
+}
@@ -0,0 +1,79 @@
+package gotailf_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailerRawBatches(t *testing.T) {
+	t.Parallel()
+	f := test.NewTmpFile(t)
+	fmt.Fprint(f.File(), "first\r\nsecond\nthi")
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithFlushInterval(50*time.Millisecond),
+		gotailf.WithOffset(0),
+		gotailf.WithBatchMaxBytes(8),
+	)
+	assert.Nil(t, err)
+	time.AfterFunc(80*time.Millisecond, func() {
+		fmt.Fprint(f.File(), "rd\n")
+	})
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	type record struct {
+		line   string
+		offset int64
+	}
+	got := [][]record{}
+	for b := range s.RawBatches(ctx) {
+		assert.Equal(t, f.Name(), b.File)
+		rs := make([]record, len(b.Records))
+		for i, r := range b.Records {
+			rs[i] = record{
+				line:   string(r.Line),
+				offset: r.Offset,
+			}
+		}
+		got = append(got, rs)
+		b.Release()
+	}
+	assert.Nil(t, s.Err())
+	assert.Equal(t, [][]record{
+		{{"first", 0}},  // 5 bytes, second does not fit
+		{{"second", 7}}, // flushed at EOF
+		{{"third", 14}},
+	}, got)
+}
+
+func BenchmarkTailerRawBatches(b *testing.B) {
+	name, cleanup := newBenchmarkFile(b, benchmarkLines)
+	defer cleanup()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := gotailf.NewTailer(name, gotailf.WithOffset(0))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		var n int
+		for batch := range s.RawBatches(ctx) {
+			n += len(batch.Records)
+			batch.Release()
+			if n == benchmarkLines {
+				cancel()
+			}
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,59 @@
+package gotailf
+
+import (
+	"time"
+
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/timestamp"
+)
+
+// Record is a line yielded by Tailer with its metadata.
+type Record struct {
+	// File is the name of the target file.
+	File string
+	// Offset is the offset of the head of the line in the target file.
+	Offset int64
+	// End is the offset of the end of the line including the trailing newline,
+	// that is the offset of the next line.
+	End int64
+	// Line is the line number of the line in the target file, 1-based.
+	// Zero unless Config.LineNumbers.
+	Line int64
+	// Text is the line without the trailing newline.
+	Text string
+	// Time is the time when the line was read.
+	Time time.Time
+	// Fields are the parsed fields of the line.
+	// Nil until Parse() is called.
+	Fields parse.Fields
+	// ParseErr is the error of Parse().
+	// Text is kept as it is even if the line is malformed.
+	ParseErr error
+	// Level is the detected level of the line.
+	// Unknown until DetectLevel() is called.
+	Level level.Level
+	// EventTime is the time when the event of the line occurred.
+	// Zero until ExtractTime() is called or if not found.
+	EventTime time.Time
+	// Late is true if the record is yielded by Merge() after the records with the later event times.
+	Late bool
+	// Skipped is the number of the skipped lines if the record is the marker of OverflowSkipToEOF.
+	// Offset of the marker is the offset of the first skipped line.
+	Skipped int64
+}
+
+// Parse parses Text as format and sets Fields and ParseErr.
+func (r *Record) Parse(format parse.Format) {
+	r.Fields, r.ParseErr = parse.Parse(r.Text, format)
+}
+
+// DetectLevel detects the level from Fields and Text and sets Level.
+func (r *Record) DetectLevel() {
+	r.Level = level.Detect(r.Text, r.Fields)
+}
+
+// ExtractTime extracts the event time from Fields and Text and sets EventTime.
+func (r *Record) ExtractTime(e *timestamp.Extractor) {
+	r.EventTime, _ = e.Extract(r.Text, r.Fields)
+}
@@ -0,0 +1,299 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientConfig represents Client configurations.
+type ClientConfig struct {
+	// Token is the resume token to start from.
+	// Default is empty.
+	Token Token
+	// Offset is the offset to start from without Token, negative means the end of the file.
+	// Default is -1.
+	Offset int64
+	// ReadTimeout is the timeout of the reads from the server, regarded as disconnected.
+	// Should be longer than the heartbeat interval of the server.
+	// Default is 30 seconds.
+	ReadTimeout time.Duration
+	// RetryInitialInterval is the first interval of the reconnections.
+	// Default is 1 second.
+	RetryInitialInterval time.Duration
+	// RetryMaxInterval is the max interval of the reconnections.
+	// Default is 30 seconds.
+	RetryMaxInterval time.Duration
+	// OnRetry is called before the reconnections with the cause.
+	// Default is nil.
+	OnRetry func(err error, after time.Duration)
+}
+
+func newDefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Offset:               -1,
+		ReadTimeout:          30 * time.Second,
+		RetryInitialInterval: time.Second,
+		RetryMaxInterval:     30 * time.Second,
+	}
+}
+
+type ClientOption func(*ClientConfig)
+
+// WithToken sets ClientConfig.Token.
+func WithToken(token Token) ClientOption {
+	return func(c *ClientConfig) {
+		c.Token = token
+	}
+}
+
+// WithOffset sets ClientConfig.Offset.
+func WithOffset(offset int64) ClientOption {
+	return func(c *ClientConfig) {
+		c.Offset = offset
+	}
+}
+
+// WithReadTimeout sets ClientConfig.ReadTimeout.
+func WithReadTimeout(timeout time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.ReadTimeout = timeout
+	}
+}
+
+// WithRetryInitialInterval sets ClientConfig.RetryInitialInterval.
+func WithRetryInitialInterval(interval time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.RetryInitialInterval = interval
+	}
+}
+
+// WithRetryMaxInterval sets ClientConfig.RetryMaxInterval.
+func WithRetryMaxInterval(interval time.Duration) ClientOption {
+	return func(c *ClientConfig) {
+		c.RetryMaxInterval = interval
+	}
+}
+
+// WithOnRetry sets ClientConfig.OnRetry.
+func WithOnRetry(f func(err error, after time.Duration)) ClientOption {
+	return func(c *ClientConfig) {
+		c.OnRetry = f
+	}
+}
+
+// Client tails a file of a server.
+type Client struct {
+	network string
+	address string
+	name    string
+	config  *ClientConfig
+
+	mu    sync.Mutex
+	token Token
+}
+
+// NewClient returns a new Client that tails the file name of the server at address.
+func NewClient(network, address, name string, opts ...ClientOption) *Client {
+	config := newDefaultClientConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Client{
+		network: network,
+		address: address,
+		name:    name,
+		config:  config,
+		token:   config.Token,
+	}
+}
+
+// Token returns the resume token after the last line passed to yield.
+func (c *Client) Token() Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(t Token) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = t
+}
+
+// Run passes the lines to yield until ctx ends, reconnecting after the disconnections.
+//
+// Returns nil when ctx ends, the error of yield,
+// or *ServerError when the server rejects the request.
+func (c *Client) Run(ctx context.Context, yield func(line string) error) error {
+	interval := c.config.RetryInitialInterval
+	for {
+		connected, err := c.run(ctx, yield)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var (
+			se *ServerError
+			ye *yieldError
+		)
+		switch {
+		case errors.As(err, &se):
+			return err
+		case errors.As(err, &ye):
+			return ye.err
+		}
+		if connected {
+			interval = c.config.RetryInitialInterval
+		}
+		if c.config.OnRetry != nil {
+			c.config.OnRetry(err, interval)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, c.config.RetryMaxInterval)
+	}
+}
+
+// yieldError is the error of yield, not retried.
+type yieldError struct {
+	err error
+}
+
+func (e *yieldError) Error() string { return e.err.Error() }
+
+func (c *Client) request() string {
+	t := c.Token()
+	if t.Identity == "" {
+		return fmt.Sprintf("TAIL %s %d\n", c.name, c.config.Offset)
+	}
+	return fmt.Sprintf("TAIL %s %d %s\n", c.name, t.Offset, t.Identity)
+}
+
+// run tails once, reports whether the server accepted the request.
+func (c *Client) run(ctx context.Context, yield func(line string) error) (bool, error) {
+	conn, err := dial(ctx, c.network, c.address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	if _, err := io.WriteString(conn, c.request()); err != nil {
+		return false, err
+	}
+	var (
+		r         = bufio.NewReader(conn)
+		connected bool
+		token     = c.Token()
+	)
+	for {
+		if c.config.ReadTimeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout))
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return connected, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		kind, rest, _ := strings.Cut(line, " ")
+		switch kind {
+		case "OK":
+			id, offset, _ := strings.Cut(rest, " ")
+			n, err := strconv.ParseInt(offset, 10, 64)
+			if err != nil || id == "" {
+				return connected, fmt.Errorf("invalid response: %s", line)
+			}
+			connected = true
+			token = Token{
+				Identity: id,
+				Offset:   n,
+			}
+			c.setToken(token)
+		case "R":
+			if rest == "" {
+				return connected, fmt.Errorf("invalid response: %s", line)
+			}
+			token = Token{Identity: rest}
+			c.setToken(token)
+		case "L":
+			end, text, _ := strings.Cut(rest, " ")
+			n, err := strconv.ParseInt(end, 10, 64)
+			if err != nil {
+				return connected, fmt.Errorf("invalid response: %s", line)
+			}
+			if err := yield(text); err != nil {
+				return connected, &yieldError{err: err}
+			}
+			if token.Offset == 0 {
+				// the file may be empty when connected
+				token.Identity = Identity(text)
+			}
+			token.Offset = n
+			c.setToken(token)
+		case "P":
+		case "ERR":
+			return connected, &ServerError{Message: rest}
+		default:
+			return connected, fmt.Errorf("invalid response: %s", line)
+		}
+	}
+}
+
+func dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// List returns the names of the files of the server at address.
+func List(ctx context.Context, network, address string) ([]string, error) {
+	conn, err := dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	if _, err := io.WriteString(conn, "LIST\n"); err != nil {
+		return nil, err
+	}
+	var (
+		names   []string
+		scanner = bufio.NewScanner(conn)
+	)
+	for scanner.Scan() {
+		kind, rest, _ := strings.Cut(scanner.Text(), " ")
+		switch kind {
+		case "F":
+			names = append(names, rest)
+		case "END":
+			return names, nil
+		case "ERR":
+			return nil, &ServerError{Message: rest}
+		default:
+			return nil, fmt.Errorf("invalid response: %s", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
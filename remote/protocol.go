@@ -0,0 +1,104 @@
+// Package remote provides the streaming of the tails to the remote clients over TCP or unix sockets.
+//
+// The protocol is line based.
+// A client sends a request line:
+//
+//	TAIL NAME [OFFSET [IDENTITY]]
+//	LIST
+//
+// NAME is the name of the file in the allowlist of the server.
+// OFFSET is the offset to start from, negative means the end of the file, default is -1.
+// IDENTITY is the identity of the file the offset belongs to.
+// The file is read from the origin if IDENTITY does not match the current file,
+// and from the end if OFFSET is over the size of the file.
+// IDENTITY is - while the first line of the file is not complete, then OFFSET is not verified.
+//
+// The server responds to TAIL:
+//
+//	OK IDENTITY OFFSET
+//	L END TEXT
+//	R IDENTITY
+//	P
+//
+// OK is the identity of the file and the actual offset to start from.
+// L is a line, END is the offset of the next line.
+// R means that the file is replaced or truncated, the following lines are of the new file from the origin.
+// P is the heartbeat while no lines.
+//
+// The server responds to LIST:
+//
+//	F NAME
+//	END
+//
+// The server responds ERR MESSAGE to the invalid requests, and closes the connection.
+//
+// A resume token IDENTITY:OFFSET is the position after the last received line.
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxIdentitySize is the max size of the head of the first line for the identity.
+const maxIdentitySize = 1024
+
+// UnknownIdentity is the identity of the file whose first line is not complete yet.
+const UnknownIdentity = "-"
+
+// Identity returns the identity of the file that starts with the line.
+func Identity(firstLine string) string {
+	if len(firstLine) > maxIdentitySize {
+		firstLine = firstLine[:maxIdentitySize]
+	}
+	sum := sha256.Sum256([]byte(firstLine))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Token is a resume token.
+type Token struct {
+	Identity string
+	Offset   int64
+}
+
+func (t Token) String() string {
+	if t.Identity == "" {
+		return ""
+	}
+	return t.Identity + ":" + strconv.FormatInt(t.Offset, 10)
+}
+
+// ErrInvalidToken means that the resume token is invalid.
+var ErrInvalidToken = errors.New("invalid resume token")
+
+// ParseToken parses the result of Token.String().
+// Empty is the zero token.
+func ParseToken(s string) (Token, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Token{}, nil
+	}
+	id, offset, ok := strings.Cut(s, ":")
+	if !ok || id == "" || strings.ContainsAny(id, " \t") {
+		return Token{}, fmt.Errorf("%w: %s", ErrInvalidToken, s)
+	}
+	n, err := strconv.ParseInt(offset, 10, 64)
+	if err != nil || n < 0 {
+		return Token{}, fmt.Errorf("%w: %s", ErrInvalidToken, s)
+	}
+	return Token{
+		Identity: id,
+		Offset:   n,
+	}, nil
+}
+
+// ServerError is the ERR response.
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string { return "server: " + e.Message }
@@ -0,0 +1,306 @@
+package remote_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/remote"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToken(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		input string
+		want  remote.Token
+		err   error
+	}{
+		{
+			title: "empty",
+		},
+		{
+			title: "token",
+			input: "abc:10",
+			want:  remote.Token{Identity: "abc", Offset: 10},
+		},
+		{
+			title: "no offset",
+			input: "abc",
+			err:   remote.ErrInvalidToken,
+		},
+		{
+			title: "negative offset",
+			input: "abc:-1",
+			err:   remote.ErrInvalidToken,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := remote.ParseToken(tc.input)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.input, got.String())
+		})
+	}
+}
+
+// startServer serves the files at the unix socket until the returned function is called.
+func startServer(t *testing.T, socket string, files map[string]string, opts ...remote.Option) func() {
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		ctx, cancel = context.WithCancel(context.TODO())
+		done        = make(chan struct{})
+		server      = remote.NewServer(files, append([]remote.Option{
+			remote.WithHeartbeat(50 * time.Millisecond),
+			remote.WithTailerOptions(gotailf.WithFlushInterval(10 * time.Millisecond)),
+		}, opts...)...)
+	)
+	go func() {
+		defer close(done)
+		assert.Nil(t, server.Serve(ctx, ln))
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startClient runs the client until the returned function is called.
+func startClient(client *remote.Client) (<-chan string, <-chan error, func()) {
+	var (
+		ctx, cancel = context.WithCancel(context.TODO())
+		lineC       = make(chan string, 100)
+		errC        = make(chan error, 1)
+	)
+	go func() {
+		errC <- client.Run(ctx, func(line string) error {
+			lineC <- line
+			return nil
+		})
+	}()
+	return lineC, errC, cancel
+}
+
+func receive(t *testing.T, lineC <-chan string, want ...string) {
+	for _, w := range want {
+		select {
+		case got := <-lineC:
+			assert.Equal(t, w, got)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting %q", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, name, text string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(text)
+	assert.Nil(t, err)
+}
+
+func newClient(socket string, opts ...remote.ClientOption) *remote.Client {
+	return remote.NewClient("unix", socket, "app", append([]remote.ClientOption{
+		remote.WithRetryInitialInterval(10 * time.Millisecond),
+		remote.WithRetryMaxInterval(50 * time.Millisecond),
+		remote.WithReadTimeout(time.Second),
+	}, opts...)...)
+}
+
+func TestList(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	socket := dir.Path("sock")
+	stop := startServer(t, socket, map[string]string{
+		"b": dir.Path("b"),
+		"a": dir.Path("a"),
+	})
+	defer stop()
+
+	got, err := remote.List(context.TODO(), "unix", socket)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, got)
+}
+
+func TestTail(t *testing.T) {
+	t.Run("unknown file", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		socket := dir.Path("sock")
+		stop := startServer(t, socket, map[string]string{})
+		defer stop()
+
+		err := newClient(socket).Run(context.TODO(), func(string) error { return nil })
+		var se *remote.ServerError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, "unknown file: app", se.Message)
+	})
+
+	t.Run("from the end", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+		stop := startServer(t, socket, map[string]string{"app": name})
+		defer stop()
+
+		client := newClient(socket)
+		lineC, errC, cancel := startClient(client)
+		// wait for the connection
+		assert.Eventually(t, func() bool {
+			return client.Token().Identity != ""
+		}, 3*time.Second, 10*time.Millisecond)
+		appendFile(t, name, "c\n")
+		receive(t, lineC, "c")
+		cancel()
+		assert.Nil(t, <-errC)
+		assert.Equal(t, remote.Token{Identity: remote.Identity("a"), Offset: 6}, client.Token())
+	})
+
+	t.Run("no heartbeats", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("a\n"), 0o600))
+		stop := startServer(t, socket, map[string]string{"app": name}, remote.WithHeartbeat(0))
+		defer stop()
+
+		client := newClient(socket)
+		lineC, errC, cancel := startClient(client)
+		assert.Eventually(t, func() bool {
+			return client.Token().Identity != ""
+		}, 3*time.Second, 10*time.Millisecond)
+		appendFile(t, name, "b\n")
+		receive(t, lineC, "b")
+		cancel()
+		assert.Nil(t, <-errC)
+	})
+
+	t.Run("resume after reconnect", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+			files  = map[string]string{"app": name}
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+		stop := startServer(t, socket, files)
+
+		client := newClient(socket, remote.WithOffset(0))
+		lineC, errC, cancel := startClient(client)
+		defer func() {
+			cancel()
+			assert.Nil(t, <-errC)
+		}()
+		receive(t, lineC, "a", "b")
+		stop()
+
+		appendFile(t, name, "c\n")
+		stop = startServer(t, socket, files)
+		defer stop()
+		receive(t, lineC, "c")
+		appendFile(t, name, "d\n")
+		receive(t, lineC, "d")
+		select {
+		case line := <-lineC:
+			t.Fatalf("unexpected line %q", line)
+		default:
+		}
+	})
+
+	t.Run("resume from the partial first line", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+			files  = map[string]string{"app": name}
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("a"), 0o600))
+		stop := startServer(t, socket, files)
+
+		client := newClient(socket)
+		lineC, errC, cancel := startClient(client)
+		defer func() {
+			cancel()
+			assert.Nil(t, <-errC)
+		}()
+		assert.Eventually(t, func() bool {
+			return client.Token().Identity != ""
+		}, 3*time.Second, 10*time.Millisecond)
+		assert.Equal(t, remote.Token{Identity: remote.UnknownIdentity, Offset: 1}, client.Token())
+		appendFile(t, name, "b\nc\n")
+		receive(t, lineC, "b", "c")
+		stop()
+
+		stop = startServer(t, socket, files)
+		defer stop()
+		appendFile(t, name, "d\n")
+		receive(t, lineC, "d")
+	})
+
+	t.Run("replaced file", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("x\ny\n"), 0o600))
+		stop := startServer(t, socket, map[string]string{"app": name})
+		defer stop()
+
+		client := newClient(socket, remote.WithToken(remote.Token{
+			Identity: remote.Identity("a"),
+			Offset:   2,
+		}))
+		lineC, errC, cancel := startClient(client)
+		receive(t, lineC, "x", "y")
+		cancel()
+		assert.Nil(t, <-errC)
+		assert.Equal(t, remote.Token{Identity: remote.Identity("x"), Offset: 4}, client.Token())
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			socket = dir.Path("sock")
+			name   = dir.Path("app.log")
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+		stop := startServer(t, socket, map[string]string{"app": name})
+		defer stop()
+
+		client := newClient(socket, remote.WithOffset(0))
+		lineC, errC, cancel := startClient(client)
+		receive(t, lineC, "a", "b")
+		assert.Nil(t, os.WriteFile(name, []byte("x\n"), 0o600))
+		receive(t, lineC, "x")
+		cancel()
+		assert.Nil(t, <-errC)
+		assert.Equal(t, remote.Token{Identity: remote.Identity("x"), Offset: 2}, client.Token())
+	})
+}
@@ -0,0 +1,353 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal"
+)
+
+// Config represents Server configurations.
+type Config struct {
+	// Heartbeat is the interval of the heartbeats while no lines.
+	// Not positive disables the heartbeats, then the clients may time out while no lines.
+	// Default is 10 seconds.
+	Heartbeat time.Duration
+	// WriteTimeout is the timeout of the writes to a client.
+	// Default is 30 seconds.
+	WriteTimeout time.Duration
+	// RequestTimeout is the timeout of the request line.
+	// Default is 10 seconds.
+	RequestTimeout time.Duration
+	// TailerOptions are the options of the tailers of the clients.
+	// The offsets are overwritten by the requests.
+	// Default is empty.
+	TailerOptions []gotailf.Option
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Heartbeat:      10 * time.Second,
+		WriteTimeout:   30 * time.Second,
+		RequestTimeout: 10 * time.Second,
+	}
+}
+
+type Option func(*Config)
+
+// WithHeartbeat sets Config.Heartbeat.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *Config) {
+		c.Heartbeat = interval
+	}
+}
+
+// WithWriteTimeout sets Config.WriteTimeout.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.WriteTimeout = timeout
+	}
+}
+
+// WithRequestTimeout sets Config.RequestTimeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.RequestTimeout = timeout
+	}
+}
+
+// WithTailerOptions sets Config.TailerOptions.
+func WithTailerOptions(opts ...gotailf.Option) Option {
+	return func(c *Config) {
+		c.TailerOptions = opts
+	}
+}
+
+// Server serves the tails of the files in the allowlist.
+type Server struct {
+	// files maps the names to the paths.
+	files  map[string]string
+	config *Config
+}
+
+// NewServer returns a new Server.
+// files is the allowlist, maps the names requested by the clients to the paths.
+func NewServer(files map[string]string, opts ...Option) *Server {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Server{
+		files:  files,
+		config: config,
+	}
+}
+
+// Serve accepts the connections until ctx ends.
+// The listener is closed when ctx ends.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// conn is a connection to a client.
+type conn struct {
+	net.Conn
+	w       *bufio.Writer
+	timeout time.Duration
+}
+
+func (c *conn) writeLine(fields ...string) error {
+	if c.timeout > 0 {
+		_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	for i, x := range fields {
+		if i > 0 {
+			if err := c.w.WriteByte(' '); err != nil {
+				return err
+			}
+		}
+		if _, err := c.w.WriteString(x); err != nil {
+			return err
+		}
+	}
+	return c.w.WriteByte('\n')
+}
+
+func (c *conn) flush() error {
+	if c.timeout > 0 {
+		_ = c.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) fail(message string) {
+	_ = c.writeLine("ERR", message)
+	_ = c.flush()
+}
+
+func (s *Server) handle(ctx context.Context, nc net.Conn) {
+	defer nc.Close()
+	c := &conn{
+		Conn:    nc,
+		w:       bufio.NewWriter(nc),
+		timeout: s.config.WriteTimeout,
+	}
+	if s.config.RequestTimeout > 0 {
+		_ = nc.SetReadDeadline(time.Now().Add(s.config.RequestTimeout))
+	}
+	line, err := bufio.NewReader(io.LimitReader(nc, 4096)).ReadString('\n')
+	if err != nil {
+		c.fail("no request")
+		return
+	}
+	_ = nc.SetReadDeadline(time.Time{})
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		c.fail("no request")
+		return
+	}
+	switch fields[0] {
+	case "LIST":
+		s.list(c)
+	case "TAIL":
+		if err := s.tail(ctx, c, fields[1:]); err != nil {
+			c.fail(err.Error())
+		}
+	default:
+		c.fail("unknown command: " + fields[0])
+	}
+}
+
+func (s *Server) list(c *conn) {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := c.writeLine("F", name); err != nil {
+			return
+		}
+	}
+	_ = c.writeLine("END")
+	_ = c.flush()
+}
+
+// request is a TAIL request.
+type request struct {
+	name     string
+	offset   int64
+	identity string
+}
+
+func parseRequest(args []string) (*request, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, errors.New("usage: TAIL NAME [OFFSET [IDENTITY]]")
+	}
+	r := &request{
+		name:   args[0],
+		offset: -1,
+	}
+	if len(args) > 1 {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset: %s", args[1])
+		}
+		r.offset = n
+	}
+	if len(args) > 2 {
+		r.identity = args[2]
+	}
+	return r, nil
+}
+
+// start returns the identity of the file and the offset to start from.
+// The identity is UnknownIdentity unless the first line is complete,
+// not to be changed by the rest of the line.
+func start(path string, r *request) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	first, err := bufio.NewReader(io.LimitReader(f, maxIdentitySize)).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	var (
+		identity = UnknownIdentity
+		offset   = r.offset
+	)
+	if strings.HasSuffix(first, "\n") || len(first) >= maxIdentitySize {
+		identity = Identity(strings.TrimRight(first, "\r\n"))
+	}
+	switch {
+	case r.identity != "" && r.identity != UnknownIdentity && r.identity != identity:
+		// replaced
+		offset = 0
+	case offset < 0 || offset > stat.Size():
+		offset = stat.Size()
+	}
+	return identity, offset, nil
+}
+
+func (s *Server) tail(ctx context.Context, c *conn, args []string) error {
+	req, err := parseRequest(args)
+	if err != nil {
+		return err
+	}
+	path, ok := s.files[req.name]
+	if !ok {
+		return fmt.Errorf("unknown file: %s", req.name)
+	}
+	identity, offset, err := start(path, req)
+	if err != nil {
+		// do not leak the path
+		return fmt.Errorf("cannot open file: %s", req.name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// detect the disconnection
+	go func() {
+		_, _ = io.Copy(io.Discard, c)
+		cancel()
+	}()
+
+	if err := c.writeLine("OK", identity, strconv.FormatInt(offset, 10)); err != nil {
+		return nil
+	}
+	if err := c.flush(); err != nil {
+		return nil
+	}
+	opts := append(slices.Clone(s.config.TailerOptions),
+		gotailf.WithOffset(offset),
+		gotailf.WithLine(0),
+		gotailf.WithTailFromOriginWhenGone(true),
+		gotailf.WithTailFromOriginWhenTruncated(true),
+	)
+	var (
+		recordC = gotailf.NewContinueTailer(path, opts...).Records(ctx)
+		end     = offset
+		ticker  = internal.NewTicker(s.config.Heartbeat)
+	)
+	defer func() {
+		cancel()
+		for range recordC {
+		}
+	}()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := c.writeLine("P"); err != nil {
+				return nil
+			}
+			if err := c.flush(); err != nil {
+				return nil
+			}
+		case r, ok := <-recordC:
+			if !ok {
+				return nil
+			}
+			if r.Offset < end && r.Offset == 0 && r.Skipped == 0 {
+				// reopened from the origin
+				if err := c.writeLine("R", Identity(r.Text)); err != nil {
+					return nil
+				}
+			}
+			end = r.End
+			if err := c.writeLine("L", strconv.FormatInt(r.End, 10), r.Text); err != nil {
+				return nil
+			}
+			if len(recordC) == 0 {
+				if err := c.flush(); err != nil {
+					return nil
+				}
+				ticker.Reset()
+			}
+		}
+	}
+}
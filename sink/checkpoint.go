@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/berquerant/gotailf"
+)
+
+// Checkpoint persists the offsets of the delivered records of each file.
+// Tailers resume from the offsets, e.g. gotailf.WithOffset(c.Offset(filename)).
+type Checkpoint struct {
+	filename string
+
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+// OpenCheckpoint loads the checkpoint file.
+// The checkpoint is empty if the file does not exist.
+func OpenCheckpoint(filename string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		filename: filename,
+		offsets:  map[string]int64{},
+	}
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.offsets); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Offset returns the offset of the next line to be delivered of the file.
+// Returns -1 if the file is not checkpointed, that is the end of the file for gotailf.WithOffset.
+func (c *Checkpoint) Offset(file string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if x, ok := c.offsets[file]; ok {
+		return x
+	}
+	return -1
+}
+
+// Advance sets the offsets to the ends of the last records of the files and persists them.
+// The offsets go back when the files are truncated or rotated.
+func (c *Checkpoint) Advance(records []*gotailf.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var changed bool
+	for _, r := range records {
+		if r.Skipped > 0 {
+			continue
+		}
+		if x, ok := c.offsets[r.File]; !ok || x != r.End {
+			c.offsets[r.File] = r.End
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *Checkpoint) save() error {
+	b, err := json.Marshal(c.offsets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0o755); err != nil {
+		return err
+	}
+	tmp := c.filename + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.filename)
+}
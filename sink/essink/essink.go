@@ -0,0 +1,431 @@
+// Package essink provides a sink that writes the records into Elasticsearch or OpenSearch by the bulk API.
+package essink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/httpsink"
+)
+
+// Action is the bulk action of the documents.
+type Action string
+
+const (
+	// ActionIndex creates or replaces the documents.
+	ActionIndex Action = "index"
+	// ActionCreate creates the documents, required by data streams.
+	// The documents already exist are regarded as delivered.
+	ActionCreate Action = "create"
+)
+
+// Config represents the sink configurations.
+type Config struct {
+	// Index is the text/template of the index name, executed with IndexData.
+	// Default is "gotailf-{{.Date}}".
+	Index string
+	// Action is the bulk action.
+	// Default is ActionIndex.
+	Action Action
+	// DocumentID returns the id of the document of the record.
+	// Default is DefaultDocumentID.
+	DocumentID func(*gotailf.Record) string
+	// Header is the additional request header, e.g. Authorization.
+	// Default is empty.
+	Header http.Header
+	// Timeout is the timeout of a request.
+	// Default is 30 seconds.
+	Timeout time.Duration
+	// Client is the HTTP client.
+	// Default is http.DefaultClient.
+	Client *http.Client
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Index:      "gotailf-{{.Date}}",
+		Action:     ActionIndex,
+		DocumentID: DefaultDocumentID,
+		Header:     http.Header{},
+		Timeout:    30 * time.Second,
+		Client:     http.DefaultClient,
+	}
+}
+
+type Option func(*Config)
+
+// WithIndex sets Config.Index.
+func WithIndex(index string) Option {
+	return func(c *Config) {
+		c.Index = index
+	}
+}
+
+// WithAction sets Config.Action.
+func WithAction(action Action) Option {
+	return func(c *Config) {
+		c.Action = action
+	}
+}
+
+// WithDocumentID sets Config.DocumentID.
+func WithDocumentID(f func(*gotailf.Record) string) Option {
+	return func(c *Config) {
+		c.DocumentID = f
+	}
+}
+
+// WithHeader adds the value to Config.Header.
+func WithHeader(key, value string) Option {
+	return func(c *Config) {
+		c.Header.Add(key, value)
+	}
+}
+
+// WithTimeout sets Config.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithClient sets Config.Client.
+func WithClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.Client = client
+	}
+}
+
+// IndexData is the data of the index name template.
+type IndexData struct {
+	// Date is the UTC date of the record, yyyy.mm.dd.
+	Date string
+	// Time is the event time of the record, or the read time if not extracted.
+	Time time.Time
+	// File is the file name of the record.
+	File string
+	// Base is the base name of the file.
+	Base string
+	// Level is the level name of the record.
+	Level string
+	// Fields are the parsed fields of the record.
+	Fields parse.Fields
+}
+
+func recordTime(r *gotailf.Record) time.Time {
+	if !r.EventTime.IsZero() {
+		return r.EventTime
+	}
+	return r.Time
+}
+
+func newIndexData(r *gotailf.Record) *IndexData {
+	t := recordTime(r)
+	return &IndexData{
+		Date:   t.UTC().Format("2006.01.02"),
+		Time:   t,
+		File:   r.File,
+		Base:   filepath.Base(r.File),
+		Level:  r.Level.String(),
+		Fields: r.Fields,
+	}
+}
+
+// identities caches the identities of the files by the absolute paths.
+var identities sync.Map
+
+// fileIdentity is the identity of the opened file.
+type fileIdentity struct {
+	info  os.FileInfo
+	first string
+	id    string
+}
+
+// maxIdentityLineSize is the max size of the first line for the identity.
+const maxIdentityLineSize = 1024
+
+// FileIdentity returns the identity of the file of the record.
+//
+// The identity is the hash of the absolute path, the device and the inode, and the first line of the file,
+// so it changes when the file is rotated or replaced, even if the files start with the same lines.
+// The identity also changes when the file is truncated and starts over with another first line.
+// The first line is read from the file unless the record is at the head of the file.
+func FileIdentity(r *gotailf.Record) string {
+	abs, err := filepath.Abs(r.File)
+	if err != nil {
+		abs = r.File
+	}
+	var (
+		head    = r.Offset == 0 && r.Skipped == 0
+		info, _ = os.Stat(abs)
+		first   = r.Text
+	)
+	if len(first) > maxIdentityLineSize {
+		// as firstLine()
+		first = first[:maxIdentityLineSize]
+	}
+	if v, ok := identities.Load(abs); ok {
+		x := v.(*fileIdentity)
+		if sameFile(x.info, info) && (!head || x.first == first) {
+			return x.id
+		}
+	}
+	if !head {
+		first = firstLine(abs)
+	}
+	var key string
+	if info != nil {
+		key = fileKey(info)
+	}
+	x := &fileIdentity{
+		info:  info,
+		first: first,
+		id:    identity(abs, key, first),
+	}
+	identities.Store(abs, x)
+	return x.id
+}
+
+// sameFile returns true if the files are the same, or both are unknown.
+func sameFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b)
+}
+
+func identity(abs, key, first string) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, abs)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, key)
+	_, _ = h.Write([]byte{0})
+	_, _ = io.WriteString(h, first)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// firstLine returns the first line of the file without the newline.
+func firstLine(filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(io.LimitReader(f, maxIdentityLineSize)).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+// DefaultDocumentID returns FILE_IDENTITY-OFFSET,
+// so the retries of the record write the same document.
+func DefaultDocumentID(r *gotailf.Record) string {
+	return FileIdentity(r) + "-" + strconv.FormatInt(r.Offset, 10)
+}
+
+// document is the source of the document.
+type document struct {
+	Timestamp time.Time `json:"@timestamp"`
+	*format.JSONRecord
+}
+
+type esSink struct {
+	url    string
+	config *Config
+	index  *template.Template
+}
+
+// New returns a sink that writes the records into the cluster at url by the bulk API.
+//
+// The items failed in the bulk response by 429 or 5xx are retried,
+// and the other failed items are rejected.
+func New(url string, opts ...Option) (sink.Sink, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	index, err := template.New("index").Parse(config.Index)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+	switch config.Action {
+	case ActionIndex, ActionCreate:
+	default:
+		return nil, fmt.Errorf("unknown action: %s", config.Action)
+	}
+	return &esSink{
+		url:    strings.TrimSuffix(url, "/") + "/_bulk",
+		config: config,
+		index:  index,
+	}, nil
+}
+
+// action is the action line of the bulk request.
+type action struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+func (s *esSink) indexName(r *gotailf.Record) (string, error) {
+	var b strings.Builder
+	if err := s.index.Execute(&b, newIndexData(r)); err != nil {
+		return "", err
+	}
+	return strings.ToLower(b.String()), nil
+}
+
+func (s *esSink) encode(records []*gotailf.Record) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		enc = json.NewEncoder(&buf)
+	)
+	for _, r := range records {
+		index, err := s.indexName(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(map[Action]*action{
+			s.config.Action: {
+				Index: index,
+				ID:    s.config.DocumentID(r),
+			},
+		}); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&document{
+			Timestamp:  recordTime(r),
+			JSONRecord: format.NewJSONRecord(r),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *esSink) Write(ctx context.Context, records []*gotailf.Record) error {
+	body, err := s.encode(records)
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	for k, v := range s.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return httpsink.ResponseError(resp)
+	}
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid bulk response: %w", err)
+	}
+	return s.itemsError(records, &result)
+}
+
+// bulkResponse is the response of the bulk API.
+type bulkResponse struct {
+	Errors bool                   `json:"errors"`
+	Items  []map[string]*bulkItem `json:"items"`
+}
+
+type bulkItem struct {
+	Status int        `json:"status"`
+	Error  *itemError `json:"error"`
+}
+
+type itemError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ItemError is the error of the failed items in the bulk response.
+type ItemError struct {
+	// Status is the status of the first failed item.
+	Status int
+	Type   string
+	Reason string
+	// Failed is the number of the failed items.
+	Failed int
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%d bulk items failed: %d %s: %s", e.Failed, e.Status, e.Type, e.Reason)
+}
+
+// itemsError returns the partial error of the failed items.
+func (s *esSink) itemsError(records []*gotailf.Record, result *bulkResponse) error {
+	if !result.Errors {
+		return nil
+	}
+	if len(result.Items) != len(records) {
+		return fmt.Errorf("bulk response has %d items for %d records", len(result.Items), len(records))
+	}
+	var (
+		retry, rejected []*gotailf.Record
+		err             *ItemError
+	)
+	for i, x := range result.Items {
+		var item *bulkItem
+		for _, v := range x {
+			item = v
+		}
+		if item == nil || item.Status < 300 {
+			continue
+		}
+		if s.config.Action == ActionCreate && item.Status == http.StatusConflict {
+			// created by the previous attempt
+			continue
+		}
+		if err == nil {
+			err = &ItemError{Status: item.Status}
+			if item.Error != nil {
+				err.Type = item.Error.Type
+				err.Reason = item.Error.Reason
+			}
+		}
+		err.Failed++
+		if item.Status == http.StatusTooManyRequests || item.Status >= 500 {
+			retry = append(retry, records[i])
+		} else {
+			rejected = append(rejected, records[i])
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return sink.Partial(retry, rejected, err)
+}
+
+func (s *esSink) Close() error {
+	s.config.Client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,252 @@
+package essink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/essink"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type action struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+type bulkRequest struct {
+	action string
+	meta   action
+	text   string
+}
+
+// server is a bulk API, fails the items by the texts.
+type server struct {
+	mu sync.Mutex
+	// failures are the statuses of the items by the texts, removed after failed.
+	failures map[string]int
+	requests [][]*bulkRequest
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var (
+		sc    = bufio.NewScanner(r.Body)
+		reqs  []*bulkRequest
+		items []map[string]any
+		errs  bool
+	)
+	for sc.Scan() {
+		var a map[string]action
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil || !sc.Scan() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var doc struct {
+			Timestamp time.Time `json:"@timestamp"`
+			Text      string    `json:"text"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &doc); err != nil || doc.Timestamp.IsZero() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req := &bulkRequest{text: doc.Text}
+		for k, v := range a {
+			req.action = k
+			req.meta = v
+		}
+		reqs = append(reqs, req)
+		item := map[string]any{"status": 201}
+		if status, ok := s.failures[doc.Text]; ok {
+			delete(s.failures, doc.Text)
+			item = map[string]any{
+				"status": status,
+				"error": map[string]string{
+					"type":   "error",
+					"reason": fmt.Sprintf("%s failed", doc.Text),
+				},
+			}
+			errs = true
+		}
+		items = append(items, map[string]any{req.action: item})
+	}
+	s.requests = append(s.requests, reqs)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": errs,
+		"items":  items,
+	})
+}
+
+func (s *server) texts() [][]string {
+	var xs [][]string
+	for _, reqs := range s.requests {
+		var ts []string
+		for _, r := range reqs {
+			ts = append(ts, r.text)
+		}
+		xs = append(xs, ts)
+	}
+	return xs
+}
+
+var eventTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newRecords(file string, texts ...string) []*gotailf.Record {
+	var (
+		rs     []*gotailf.Record
+		offset int64
+	)
+	for _, x := range texts {
+		rs = append(rs, &gotailf.Record{
+			File:      file,
+			Offset:    offset,
+			Text:      x,
+			EventTime: eventTime,
+		})
+		offset += int64(len(x)) + 1
+	}
+	return rs
+}
+
+func TestSink(t *testing.T) {
+	t.Run("index and id", func(t *testing.T) {
+		srv := &server{}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		s, err := essink.New(ts.URL, essink.WithIndex("Logs-{{.Base}}-{{.Date}}"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		records := newRecords("/var/log/app.log", "a", "b")
+		assert.Nil(t, s.Write(context.TODO(), records))
+		if !assert.Equal(t, 1, len(srv.requests)) {
+			return
+		}
+		reqs := srv.requests[0]
+		for i, r := range reqs {
+			assert.Equal(t, "index", r.action)
+			assert.Equal(t, "logs-app.log-2026.01.02", r.meta.Index)
+			assert.Equal(t, essink.DefaultDocumentID(records[i]), r.meta.ID)
+		}
+		assert.True(t, strings.HasSuffix(reqs[1].meta.ID, "-2"))
+		// the same id by the retry
+		assert.Nil(t, s.Write(context.TODO(), records[1:]))
+		assert.Equal(t, reqs[1].meta.ID, srv.requests[1][0].meta.ID)
+	})
+
+	t.Run("retry failed items", func(t *testing.T) {
+		srv := &server{
+			failures: map[string]int{
+				"b": http.StatusTooManyRequests,
+				"c": http.StatusBadRequest,
+				"d": http.StatusServiceUnavailable,
+			},
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		s, err := essink.New(ts.URL)
+		if !assert.Nil(t, err) {
+			return
+		}
+		recordC := make(chan *gotailf.Record, 4)
+		for _, r := range newRecords("f", "a", "b", "c", "d") {
+			recordC <- r
+		}
+		close(recordC)
+		d := sink.New(s,
+			sink.WithRetryInitialInterval(time.Millisecond),
+			sink.WithRetryMaxInterval(time.Millisecond),
+			sink.WithDeadLetter(dir.Path("dead")),
+		)
+		assert.Nil(t, d.Run(context.TODO(), recordC))
+		assert.Equal(t, [][]string{{"a", "b", "c", "d"}, {"b", "d"}}, srv.texts())
+		assert.Equal(t, sink.Stats{
+			Batches:      1,
+			Records:      3,
+			Retries:      1,
+			DeadLettered: 1,
+		}, d.Stats())
+		b, err := os.ReadFile(dir.Path("dead"))
+		assert.Nil(t, err)
+		assert.Contains(t, string(b), `"text":"c"`)
+	})
+
+	t.Run("create conflict", func(t *testing.T) {
+		srv := &server{
+			failures: map[string]int{"a": http.StatusConflict},
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		s, err := essink.New(ts.URL, essink.WithAction(essink.ActionCreate))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Nil(t, s.Write(context.TODO(), newRecords("f", "a")))
+		assert.Equal(t, "create", srv.requests[0][0].action)
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		_, err := essink.New("http://localhost", essink.WithIndex("{{"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestFileIdentity(t *testing.T) {
+	f := test.NewTmpFile(t)
+	defer f.Remove(t)
+	_, err := f.File().WriteString("first\nsecond\n")
+	assert.Nil(t, err)
+	f.Close(t)
+
+	var (
+		head = &gotailf.Record{File: f.Name(), Offset: 0, Text: "first"}
+		next = &gotailf.Record{File: f.Name(), Offset: 6, Text: "second"}
+	)
+	id := essink.FileIdentity(next)
+	assert.Equal(t, id, essink.FileIdentity(head))
+
+	// truncated and starts over
+	assert.Nil(t, os.WriteFile(f.Name(), []byte("another\n"), 0o644))
+	truncated := essink.FileIdentity(&gotailf.Record{File: f.Name(), Offset: 0, Text: "another"})
+	assert.NotEqual(t, id, truncated)
+	assert.Equal(t, truncated, essink.FileIdentity(next))
+
+	// rotated, the new file is not read from the head and starts with the same line
+	assert.Nil(t, os.Rename(f.Name(), f.Name()+".1"))
+	defer os.Remove(f.Name() + ".1")
+	assert.Nil(t, os.WriteFile(f.Name(), []byte("another\nline\n"), 0o644))
+	rotated := essink.FileIdentity(&gotailf.Record{File: f.Name(), Offset: 8, Text: "line"})
+	assert.NotEqual(t, truncated, rotated)
+	assert.Equal(t, rotated, essink.FileIdentity(&gotailf.Record{File: f.Name(), Offset: 0, Text: "another"}))
+}
+
+func TestFileIdentityLongFirstLine(t *testing.T) {
+	f := test.NewTmpFile(t)
+	defer f.Remove(t)
+	first := strings.Repeat("x", 2000)
+	_, err := f.File().WriteString(first + "\nsecond\n")
+	assert.Nil(t, err)
+	f.Close(t)
+
+	id := essink.FileIdentity(&gotailf.Record{File: f.Name(), Offset: 2001, Text: "second"})
+	assert.Equal(t, id, essink.FileIdentity(&gotailf.Record{File: f.Name(), Offset: 0, Text: first}))
+}
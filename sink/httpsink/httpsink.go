@@ -0,0 +1,295 @@
+// Package httpsink provides a sink that posts the batches of the records to an HTTP endpoint.
+package httpsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/sink"
+)
+
+// Encoding is the encoding of the request body.
+type Encoding int
+
+const (
+	// EncodingJSON encodes a batch as a JSON array of format.JSONRecord.
+	EncodingJSON Encoding = iota
+	// EncodingNDJSON encodes a batch as JSON lines of format.JSONRecord.
+	EncodingNDJSON
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingJSON:
+		return "json"
+	case EncodingNDJSON:
+		return "ndjson"
+	default:
+		return "unknown"
+	}
+}
+
+// ContentType returns the content type of the encoding.
+func (e Encoding) ContentType() string {
+	if e == EncodingNDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// ErrUnknownEncoding means that the encoding name is invalid.
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// ParseEncoding returns the encoding of the name, the result of Encoding.String().
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return EncodingJSON, nil
+	case "ndjson", "jsonl":
+		return EncodingNDJSON, nil
+	default:
+		return EncodingJSON, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+}
+
+// Config represents the sink configurations.
+type Config struct {
+	// Encoding is the encoding of the request body.
+	// Default is EncodingJSON.
+	Encoding Encoding
+	// Header is the additional request header.
+	// Default is empty.
+	Header http.Header
+	// Gzip compresses the request body.
+	// Default is false.
+	Gzip bool
+	// Timeout is the timeout of a request.
+	// Default is 30 seconds.
+	Timeout time.Duration
+	// Client is the HTTP client.
+	// Default is http.DefaultClient.
+	Client *http.Client
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Header:  http.Header{},
+		Timeout: 30 * time.Second,
+		Client:  http.DefaultClient,
+	}
+}
+
+type Option func(*Config)
+
+// WithEncoding sets Config.Encoding.
+func WithEncoding(encoding Encoding) Option {
+	return func(c *Config) {
+		c.Encoding = encoding
+	}
+}
+
+// WithHeader adds the value to Config.Header.
+func WithHeader(key, value string) Option {
+	return func(c *Config) {
+		c.Header.Add(key, value)
+	}
+}
+
+// WithGzip sets Config.Gzip.
+func WithGzip(enabled bool) Option {
+	return func(c *Config) {
+		c.Gzip = enabled
+	}
+}
+
+// WithTimeout sets Config.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithClient sets Config.Client.
+func WithClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.Client = client
+	}
+}
+
+// StatusError is an error response.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// Body is the head of the response body.
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Body)
+}
+
+// Retryable returns true if the request may succeed later.
+func (e *StatusError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxErrorBodySize is the max size of StatusError.Body.
+const maxErrorBodySize = 1024
+
+type httpSink struct {
+	url    string
+	config *Config
+}
+
+// New returns a sink that posts the batches to the url.
+//
+// The responses of 408, 429 and 5xx are retried, honoring Retry-After.
+// The other non-2xx responses are permanent errors.
+func New(url string, opts ...Option) sink.Sink {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &httpSink{
+		url:    url,
+		config: config,
+	}
+}
+
+func (s *httpSink) Write(ctx context.Context, records []*gotailf.Record) error {
+	body, err := s.encode(records)
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	for k, v := range s.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", s.config.Encoding.ContentType())
+	if s.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return ResponseError(resp)
+}
+
+// ResponseError returns the error of the non-2xx response.
+// The error is permanent unless StatusError.Retryable(),
+// and retried after Retry-After if given.
+func ResponseError(resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	err := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(b)),
+	}
+	if !err.Retryable() {
+		return sink.Permanent(err)
+	}
+	if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return sink.RetryAfter(err, after)
+	}
+	return err
+}
+
+// parseRetryAfter parses Retry-After, delay seconds or HTTP-date.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		if n < 0 {
+			return 0, false
+		}
+		return time.Duration(n) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+func (s *httpSink) encode(records []*gotailf.Record) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.Writer = &buf
+		gz  *gzip.Writer
+	)
+	if s.config.Gzip {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+	if err := s.encodeTo(w, records); err != nil {
+		return nil, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *httpSink) encodeTo(w io.Writer, records []*gotailf.Record) error {
+	xs := make([]*format.JSONRecord, len(records))
+	for i, r := range records {
+		xs[i] = format.NewJSONRecord(r)
+	}
+	if s.config.Encoding == EncodingJSON {
+		return json.NewEncoder(w).Encode(xs)
+	}
+	enc := json.NewEncoder(w)
+	for _, x := range xs {
+		if err := enc.Encode(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.config.Client.CloseIdleConnections()
+	return nil
+}
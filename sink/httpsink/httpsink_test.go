@@ -0,0 +1,198 @@
+package httpsink_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/httpsink"
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	header http.Header
+	texts  []string
+}
+
+// server records the requests and responds by statuses in order, then 200.
+type server struct {
+	mu       sync.Mutex
+	requests []*request
+	statuses []int
+	header   http.Header
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	var xs []*format.JSONRecord
+	if r.Header.Get("Content-Type") == "application/x-ndjson" {
+		sc := bufio.NewScanner(body)
+		for sc.Scan() {
+			var x format.JSONRecord
+			if err := json.Unmarshal(sc.Bytes(), &x); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			xs = append(xs, &x)
+		}
+	} else if err := json.NewDecoder(body).Decode(&xs); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req := &request{header: r.Header}
+	for _, x := range xs {
+		req.texts = append(req.texts, x.Text)
+	}
+	s.requests = append(s.requests, req)
+	if len(s.statuses) > 0 {
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(s.statuses[0])
+		s.statuses = s.statuses[1:]
+		_, _ = io.WriteString(w, "failed\n")
+	}
+}
+
+var records = []*gotailf.Record{
+	{File: "f", Offset: 0, Text: "a"},
+	{File: "f", Offset: 2, Text: "b"},
+}
+
+func TestSink(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		opts        []httpsink.Option
+		contentType string
+		gzip        bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+		},
+		{
+			name:        "ndjson",
+			opts:        []httpsink.Option{httpsink.WithEncoding(httpsink.EncodingNDJSON)},
+			contentType: "application/x-ndjson",
+		},
+		{
+			name:        "gzip",
+			opts:        []httpsink.Option{httpsink.WithGzip(true)},
+			contentType: "application/json",
+			gzip:        true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := &server{}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+
+			opts := append([]httpsink.Option{
+				httpsink.WithHeader("Authorization", "Bearer token"),
+			}, tc.opts...)
+			s := httpsink.New(ts.URL, opts...)
+			defer s.Close()
+			assert.Nil(t, s.Write(context.TODO(), records))
+			if !assert.Equal(t, 1, len(srv.requests)) {
+				return
+			}
+			req := srv.requests[0]
+			assert.Equal(t, []string{"a", "b"}, req.texts)
+			assert.Equal(t, tc.contentType, req.header.Get("Content-Type"))
+			assert.Equal(t, "Bearer token", req.header.Get("Authorization"))
+			assert.Equal(t, tc.gzip, req.header.Get("Content-Encoding") == "gzip")
+		})
+	}
+
+	t.Run("permanent", func(t *testing.T) {
+		srv := &server{statuses: []int{http.StatusBadRequest}}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		err := httpsink.New(ts.URL).Write(context.TODO(), records)
+		assert.True(t, sink.IsPermanent(err))
+		var serr *httpsink.StatusError
+		if assert.True(t, errors.As(err, &serr)) {
+			assert.Equal(t, http.StatusBadRequest, serr.StatusCode)
+			assert.Equal(t, "failed", serr.Body)
+		}
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		srv := &server{
+			statuses: []int{http.StatusTooManyRequests},
+			header:   http.Header{"Retry-After": []string{"3"}},
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		err := httpsink.New(ts.URL).Write(context.TODO(), records)
+		assert.False(t, sink.IsPermanent(err))
+		var rerr *sink.RetryAfterError
+		if assert.True(t, errors.As(err, &rerr)) {
+			assert.Equal(t, 3*time.Second, rerr.After)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer ts.Close()
+		defer close(release)
+		err := httpsink.New(ts.URL, httpsink.WithTimeout(10*time.Millisecond)).Write(context.TODO(), records)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.False(t, sink.IsPermanent(err))
+	})
+
+	t.Run("deliver with retries", func(t *testing.T) {
+		srv := &server{
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			header:   http.Header{"Retry-After": []string{"0"}},
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		recordC := make(chan *gotailf.Record, len(records))
+		for _, r := range records {
+			recordC <- r
+		}
+		close(recordC)
+		d := sink.New(httpsink.New(ts.URL),
+			sink.WithRetryInitialInterval(time.Millisecond),
+			sink.WithRetryMaxInterval(time.Millisecond),
+		)
+		assert.Nil(t, d.Run(context.TODO(), recordC))
+		assert.Equal(t, 3, len(srv.requests))
+		assert.Equal(t, int64(2), d.Stats().Retries)
+	})
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, e := range []httpsink.Encoding{httpsink.EncodingJSON, httpsink.EncodingNDJSON} {
+		got, err := httpsink.ParseEncoding(e.String())
+		assert.Nil(t, err)
+		assert.Equal(t, e, got)
+	}
+	_, err := httpsink.ParseEncoding("xml")
+	assert.True(t, errors.Is(err, httpsink.ErrUnknownEncoding))
+}
@@ -0,0 +1,474 @@
+// Package lokisink provides a sink that pushes the records to Grafana Loki.
+package lokisink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal/protowire"
+	"github.com/berquerant/gotailf/internal/snappy"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/httpsink"
+)
+
+// Encoding is the encoding of the push request.
+type Encoding int
+
+const (
+	// EncodingProtobuf is the snappy-compressed protobuf.
+	EncodingProtobuf Encoding = iota
+	// EncodingJSON is the JSON.
+	EncodingJSON
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingProtobuf:
+		return "protobuf"
+	case EncodingJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownEncoding means that the encoding name is invalid.
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// ParseEncoding returns the encoding of the name, the result of Encoding.String().
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "protobuf", "proto":
+		return EncodingProtobuf, nil
+	case "json":
+		return EncodingJSON, nil
+	default:
+		return EncodingProtobuf, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+}
+
+// PushPath is the path of the push API.
+const PushPath = "/loki/api/v1/push"
+
+// Config represents the sink configurations.
+type Config struct {
+	// Encoding is the encoding of the push requests.
+	// Default is EncodingProtobuf.
+	Encoding Encoding
+	// Labels are the static labels of all the streams.
+	// Default is {job="gotailf"}.
+	Labels map[string]string
+	// FilenameLabel is the label name of the file name of the record.
+	// Empty disables the label.
+	// Default is "filename".
+	FilenameLabel string
+	// LevelLabel is the label name of the detected level of the record.
+	// Empty disables the label.
+	// Default is empty.
+	LevelLabel string
+	// FieldLabels are the names of the parsed fields used as the labels.
+	// The label names are the field names with the invalid characters replaced by _.
+	// Default is empty.
+	FieldLabels []string
+	// TenantID is the X-Scope-OrgID header.
+	// Default is empty.
+	TenantID string
+	// Header is the additional request header.
+	// Default is empty.
+	Header http.Header
+	// Timeout is the timeout of a request.
+	// Default is 30 seconds.
+	Timeout time.Duration
+	// Client is the HTTP client.
+	// Default is http.DefaultClient.
+	Client *http.Client
+	// OnRejected is called when Loki rejects some entries as out of order or too old.
+	// Default is nil.
+	OnRejected func(err error)
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Labels:        map[string]string{"job": "gotailf"},
+		FilenameLabel: "filename",
+		Header:        http.Header{},
+		Timeout:       30 * time.Second,
+		Client:        http.DefaultClient,
+	}
+}
+
+type Option func(*Config)
+
+// WithEncoding sets Config.Encoding.
+func WithEncoding(encoding Encoding) Option {
+	return func(c *Config) {
+		c.Encoding = encoding
+	}
+}
+
+// WithLabels sets Config.Labels.
+func WithLabels(labels map[string]string) Option {
+	return func(c *Config) {
+		c.Labels = labels
+	}
+}
+
+// WithFilenameLabel sets Config.FilenameLabel.
+func WithFilenameLabel(name string) Option {
+	return func(c *Config) {
+		c.FilenameLabel = name
+	}
+}
+
+// WithLevelLabel sets Config.LevelLabel.
+func WithLevelLabel(name string) Option {
+	return func(c *Config) {
+		c.LevelLabel = name
+	}
+}
+
+// WithFieldLabels sets Config.FieldLabels.
+func WithFieldLabels(names ...string) Option {
+	return func(c *Config) {
+		c.FieldLabels = names
+	}
+}
+
+// WithTenantID sets Config.TenantID.
+func WithTenantID(id string) Option {
+	return func(c *Config) {
+		c.TenantID = id
+	}
+}
+
+// WithHeader adds the value to Config.Header.
+func WithHeader(key, value string) Option {
+	return func(c *Config) {
+		c.Header.Add(key, value)
+	}
+}
+
+// WithTimeout sets Config.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithClient sets Config.Client.
+func WithClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.Client = client
+	}
+}
+
+// WithOnRejected sets Config.OnRejected.
+func WithOnRejected(f func(err error)) Option {
+	return func(c *Config) {
+		c.OnRejected = f
+	}
+}
+
+// labelName returns the valid label name, [a-zA-Z_][a-zA-Z0-9_]*.
+func labelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// label is a label pair.
+type label struct {
+	name  string
+	value string
+}
+
+// entry is a log entry of a stream.
+type entry struct {
+	ts   time.Time
+	line string
+}
+
+// stream is the entries of the same labels.
+type stream struct {
+	// key is the label set in the Loki format, e.g. {filename="a", job="gotailf"}.
+	key     string
+	labels  []label
+	entries []entry
+}
+
+type lokiSink struct {
+	url    string
+	config *Config
+
+	mu sync.Mutex
+	// last is the timestamp of the last pushed entry of each stream.
+	last map[string]time.Time
+}
+
+// New returns a sink that pushes the records to Loki at url.
+// PushPath is appended if url has no path.
+//
+// The records are grouped into the streams by the labels.
+// The timestamps of the entries never go back in a stream,
+// the event time older than the last pushed entry is replaced by the time of that entry.
+// The entries rejected as out of order or too old are passed to Config.OnRejected and not retried,
+// they are likely pushed before the restart.
+func New(u string, opts ...Option) (sink.Sink, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	x, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if x.Path == "" || x.Path == "/" {
+		x.Path = PushPath
+	}
+	return &lokiSink{
+		url:    x.String(),
+		config: config,
+		last:   map[string]time.Time{},
+	}, nil
+}
+
+func (s *lokiSink) labels(r *gotailf.Record) []label {
+	m := map[string]string{}
+	for k, v := range s.config.Labels {
+		m[labelName(k)] = v
+	}
+	if s.config.FilenameLabel != "" {
+		m[labelName(s.config.FilenameLabel)] = r.File
+	}
+	if s.config.LevelLabel != "" {
+		m[labelName(s.config.LevelLabel)] = r.Level.String()
+	}
+	for _, k := range s.config.FieldLabels {
+		v, ok := r.Fields[k]
+		if !ok || v == nil {
+			continue
+		}
+		if x, ok := v.(string); ok {
+			m[labelName(k)] = x
+		} else {
+			m[labelName(k)] = fmt.Sprint(v)
+		}
+	}
+	labels := make([]label, 0, len(m))
+	for k, v := range m {
+		if v == "" {
+			// empty labels are dropped by Loki
+			continue
+		}
+		labels = append(labels, label{name: k, value: v})
+	}
+	slices.SortFunc(labels, func(a, b label) int { return strings.Compare(a.name, b.name) })
+	return labels
+}
+
+func labelsKey(labels []label) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, x := range labels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(x.name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(x.value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func recordTime(r *gotailf.Record) time.Time {
+	if !r.EventTime.IsZero() {
+		return r.EventTime
+	}
+	return r.Time
+}
+
+// streams groups the records into the streams in order of the first records.
+func (s *lokiSink) streams(records []*gotailf.Record) []*stream {
+	var (
+		streams []*stream
+		index   = map[string]*stream{}
+	)
+	for _, r := range records {
+		labels := s.labels(r)
+		key := labelsKey(labels)
+		x, ok := index[key]
+		if !ok {
+			x = &stream{
+				key:    key,
+				labels: labels,
+			}
+			index[key] = x
+			streams = append(streams, x)
+		}
+		ts := recordTime(r)
+		// keep the order in the stream
+		last := s.last[key]
+		if n := len(x.entries); n > 0 {
+			last = x.entries[n-1].ts
+		}
+		if ts.Before(last) {
+			ts = last
+		}
+		x.entries = append(x.entries, entry{
+			ts:   ts,
+			line: r.Text,
+		})
+	}
+	return streams
+}
+
+func (s *lokiSink) Write(ctx context.Context, records []*gotailf.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		streams     = s.streams(records)
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch s.config.Encoding {
+	case EncodingJSON:
+		contentType = "application/json"
+		body, err = encodeJSON(streams)
+	default:
+		contentType = "application/x-protobuf"
+		body = snappy.Encode(nil, encodeProtobuf(streams))
+	}
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	if err := s.push(ctx, body, contentType); err != nil {
+		return err
+	}
+	for _, x := range streams {
+		s.last[x.key] = x.entries[len(x.entries)-1].ts
+	}
+	return nil
+}
+
+func (s *lokiSink) push(ctx context.Context, body []byte, contentType string) error {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	for k, v := range s.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.config.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.config.TenantID)
+	}
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = httpsink.ResponseError(resp)
+	if isRejected(err) {
+		// the other entries are accepted
+		if s.config.OnRejected != nil {
+			s.config.OnRejected(err)
+		}
+		return nil
+	}
+	return err
+}
+
+// isRejected returns true if the error is the rejection of the old entries.
+func isRejected(err error) bool {
+	var serr *httpsink.StatusError
+	if !errors.As(err, &serr) || serr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body := strings.ToLower(serr.Body)
+	return strings.Contains(body, "out of order") ||
+		strings.Contains(body, "too far behind") ||
+		strings.Contains(body, "greater than max sample age")
+}
+
+// jsonStream is a stream of the JSON push request.
+type jsonStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func encodeJSON(streams []*stream) ([]byte, error) {
+	xs := make([]*jsonStream, len(streams))
+	for i, x := range streams {
+		js := &jsonStream{
+			Stream: map[string]string{},
+			Values: make([][2]string, len(x.entries)),
+		}
+		for _, l := range x.labels {
+			js.Stream[l.name] = l.value
+		}
+		for j, e := range x.entries {
+			js.Values[j] = [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line}
+		}
+		xs[i] = js
+	}
+	return json.Marshal(map[string]any{"streams": xs})
+}
+
+// encodeProtobuf encodes the streams as logproto.PushRequest.
+//
+//	message PushRequest { repeated StreamAdapter streams = 1; }
+//	message StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	message EntryAdapter { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+//	message Timestamp { int64 seconds = 1; int32 nanos = 2; }
+func encodeProtobuf(streams []*stream) []byte {
+	var b []byte
+	for _, x := range streams {
+		b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+			b = protowire.AppendStringField(b, 1, x.key)
+			for _, e := range x.entries {
+				b = protowire.AppendMessageField(b, 2, func(b []byte) []byte {
+					b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+						b = protowire.AppendVarintField(b, 1, uint64(e.ts.Unix()))
+						return protowire.AppendVarintField(b, 2, uint64(e.ts.Nanosecond()))
+					})
+					return protowire.AppendStringField(b, 2, e.line)
+				})
+			}
+			return b
+		})
+	}
+	return b
+}
+
+func (s *lokiSink) Close() error {
+	s.config.Client.CloseIdleConnections()
+	return nil
+}
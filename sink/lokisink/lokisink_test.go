@@ -0,0 +1,278 @@
+package lokisink_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal/protowire"
+	"github.com/berquerant/gotailf/internal/snappy"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/sink/lokisink"
+	"github.com/stretchr/testify/assert"
+)
+
+type entry struct {
+	ts   int64
+	line string
+}
+
+type stream struct {
+	labels  string
+	entries []entry
+}
+
+// server decodes the push requests into the streams.
+type server struct {
+	mu      sync.Mutex
+	pushes  [][]*stream
+	header  http.Header
+	status  int
+	message string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = r.Header
+	if r.URL.Path != lokisink.PushPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var streams []*stream
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		streams, err = decodeProtobuf(body)
+	case "application/json":
+		streams, err = decodeJSON(body)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.pushes = append(s.pushes, streams)
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		_, _ = io.WriteString(w, s.message)
+		s.status = 0
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeProtobuf(body []byte) ([]*stream, error) {
+	b, err := snappy.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := protowire.Fields(b)
+	if err != nil {
+		return nil, err
+	}
+	var streams []*stream
+	for _, f := range req {
+		fs, err := protowire.Fields(f.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		x := &stream{}
+		for _, f := range fs {
+			switch f.Num {
+			case 1:
+				x.labels = string(f.Bytes)
+			case 2:
+				efs, err := protowire.Fields(f.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				var e entry
+				for _, ef := range efs {
+					switch ef.Num {
+					case 1:
+						tfs, err := protowire.Fields(ef.Bytes)
+						if err != nil {
+							return nil, err
+						}
+						var sec, nsec int64
+						for _, tf := range tfs {
+							if tf.Num == 1 {
+								sec = int64(tf.Uint)
+							} else {
+								nsec = int64(tf.Uint)
+							}
+						}
+						e.ts = time.Unix(sec, nsec).UnixNano()
+					case 2:
+						e.line = string(ef.Bytes)
+					}
+				}
+				x.entries = append(x.entries, e)
+			}
+		}
+		streams = append(streams, x)
+	}
+	return streams, nil
+}
+
+func decodeJSON(body []byte) ([]*stream, error) {
+	var req struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	var streams []*stream
+	for _, s := range req.Streams {
+		x := &stream{
+			// only the filename for the comparison
+			labels: `{filename="` + s.Stream["filename"] + `"}`,
+		}
+		for _, v := range s.Values {
+			ts, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			x.entries = append(x.entries, entry{ts: ts, line: v[1]})
+		}
+		streams = append(streams, x)
+	}
+	return streams, nil
+}
+
+var base = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func at(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+func TestSink(t *testing.T) {
+	t.Run("labels", func(t *testing.T) {
+		srv := &server{}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		s, err := lokisink.New(ts.URL,
+			lokisink.WithLabels(map[string]string{"env": "dev"}),
+			lokisink.WithLevelLabel("level"),
+			lokisink.WithFieldLabels("app.name", "missing"),
+			lokisink.WithTenantID("team"),
+		)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{
+			{File: "a", Text: "a1", EventTime: at(1), Level: level.Info, Fields: parse.Fields{"app.name": "x"}},
+			{File: "b", Text: "b1", EventTime: at(2), Level: level.Info, Fields: parse.Fields{"app.name": "x"}},
+			{File: "a", Text: "a2", EventTime: at(3), Level: level.Info, Fields: parse.Fields{"app.name": "x"}},
+			{File: "a", Text: "a3", EventTime: at(4), Level: level.Error},
+		}))
+		assert.Equal(t, "team", srv.header.Get("X-Scope-OrgID"))
+		assert.Equal(t, [][]*stream{{
+			{
+				labels: `{app_name="x", env="dev", filename="a", level="info"}`,
+				entries: []entry{
+					{ts: at(1).UnixNano(), line: "a1"},
+					{ts: at(3).UnixNano(), line: "a2"},
+				},
+			},
+			{
+				labels:  `{app_name="x", env="dev", filename="b", level="info"}`,
+				entries: []entry{{ts: at(2).UnixNano(), line: "b1"}},
+			},
+			{
+				labels:  `{env="dev", filename="a", level="error"}`,
+				entries: []entry{{ts: at(4).UnixNano(), line: "a3"}},
+			},
+		}}, srv.pushes)
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		for _, encoding := range []lokisink.Encoding{lokisink.EncodingProtobuf, lokisink.EncodingJSON} {
+			t.Run(encoding.String(), func(t *testing.T) {
+				srv := &server{}
+				ts := httptest.NewServer(srv)
+				defer ts.Close()
+				s, err := lokisink.New(ts.URL,
+					lokisink.WithLabels(nil),
+					lokisink.WithEncoding(encoding),
+				)
+				if !assert.Nil(t, err) {
+					return
+				}
+				defer s.Close()
+				assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{
+					{File: "a", Text: "1", EventTime: at(5)},
+					{File: "a", Text: "2", EventTime: at(3)},
+				}))
+				assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{
+					{File: "a", Text: "3", EventTime: at(4)},
+					{File: "a", Text: "4", EventTime: at(6)},
+				}))
+				assert.Equal(t, [][]*stream{
+					{{
+						labels: `{filename="a"}`,
+						entries: []entry{
+							{ts: at(5).UnixNano(), line: "1"},
+							{ts: at(5).UnixNano(), line: "2"},
+						},
+					}},
+					{{
+						labels: `{filename="a"}`,
+						entries: []entry{
+							{ts: at(5).UnixNano(), line: "3"},
+							{ts: at(6).UnixNano(), line: "4"},
+						},
+					}},
+				}, srv.pushes)
+			})
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		srv := &server{
+			status:  http.StatusBadRequest,
+			message: "entry with timestamp 2026-01-02 03:04:05 ignored, reason: 'entry out of order' for stream: {filename=\"a\"}",
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		var rejected []error
+		s, err := lokisink.New(ts.URL, lokisink.WithOnRejected(func(err error) {
+			rejected = append(rejected, err)
+		}))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{{File: "a", Text: "1", EventTime: at(1)}}))
+		assert.Equal(t, 1, len(rejected))
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		srv := &server{
+			status:  http.StatusBadRequest,
+			message: "invalid labels",
+		}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		s, err := lokisink.New(ts.URL)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.NotNil(t, s.Write(context.TODO(), []*gotailf.Record{{File: "a", Text: "1", EventTime: at(1)}}))
+	})
+}
@@ -0,0 +1,599 @@
+// Package otlpsink provides a sink that exports the records as OpenTelemetry logs by OTLP/HTTP.
+package otlpsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal/protowire"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/sink/httpsink"
+)
+
+// Encoding is the encoding of the export request.
+type Encoding int
+
+const (
+	// EncodingProtobuf is the binary protobuf.
+	EncodingProtobuf Encoding = iota
+	// EncodingJSON is the JSON protobuf encoding.
+	EncodingJSON
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingProtobuf:
+		return "protobuf"
+	case EncodingJSON:
+		return "json"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownEncoding means that the encoding name is invalid.
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// ParseEncoding returns the encoding of the name, the result of Encoding.String().
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "protobuf", "proto":
+		return EncodingProtobuf, nil
+	case "json":
+		return EncodingJSON, nil
+	default:
+		return EncodingProtobuf, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+}
+
+// LogsPath is the path of the logs export.
+const LogsPath = "/v1/logs"
+
+// ScopeName is the name of the instrumentation scope of the logs.
+const ScopeName = "github.com/berquerant/gotailf"
+
+// Config represents the sink configurations.
+type Config struct {
+	// Encoding is the encoding of the export requests.
+	// Default is EncodingProtobuf.
+	Encoding Encoding
+	// ResourceAttributes are the additional resource attributes.
+	// Default is {"service.name": "gotailf"}.
+	ResourceAttributes map[string]string
+	// FieldAttributes adds the parsed fields of the records as the log attributes.
+	// Default is false.
+	FieldAttributes bool
+	// Gzip compresses the request body.
+	// Default is false.
+	Gzip bool
+	// Header is the additional request header.
+	// Default is empty.
+	Header http.Header
+	// Timeout is the timeout of a request.
+	// Default is 10 seconds.
+	Timeout time.Duration
+	// Client is the HTTP client.
+	// Default is http.DefaultClient.
+	Client *http.Client
+	// OnRejected is called when the collector rejects some log records by the partial success.
+	// Default is nil.
+	OnRejected func(err error)
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		ResourceAttributes: map[string]string{"service.name": "gotailf"},
+		Header:             http.Header{},
+		Timeout:            10 * time.Second,
+		Client:             http.DefaultClient,
+	}
+}
+
+type Option func(*Config)
+
+// WithEncoding sets Config.Encoding.
+func WithEncoding(encoding Encoding) Option {
+	return func(c *Config) {
+		c.Encoding = encoding
+	}
+}
+
+// WithResourceAttributes sets Config.ResourceAttributes.
+func WithResourceAttributes(attrs map[string]string) Option {
+	return func(c *Config) {
+		c.ResourceAttributes = attrs
+	}
+}
+
+// WithFieldAttributes sets Config.FieldAttributes.
+func WithFieldAttributes(enabled bool) Option {
+	return func(c *Config) {
+		c.FieldAttributes = enabled
+	}
+}
+
+// WithGzip sets Config.Gzip.
+func WithGzip(enabled bool) Option {
+	return func(c *Config) {
+		c.Gzip = enabled
+	}
+}
+
+// WithHeader adds the value to Config.Header.
+func WithHeader(key, value string) Option {
+	return func(c *Config) {
+		c.Header.Add(key, value)
+	}
+}
+
+// WithTimeout sets Config.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithClient sets Config.Client.
+func WithClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.Client = client
+	}
+}
+
+// WithOnRejected sets Config.OnRejected.
+func WithOnRejected(f func(err error)) Option {
+	return func(c *Config) {
+		c.OnRejected = f
+	}
+}
+
+// SeverityNumber returns the OpenTelemetry severity number of the level, 0 if unknown.
+func SeverityNumber(l level.Level) int {
+	switch l {
+	case level.Trace:
+		return 1
+	case level.Debug:
+		return 5
+	case level.Info:
+		return 9
+	case level.Warn:
+		return 13
+	case level.Error:
+		return 17
+	case level.Fatal:
+		return 21
+	default:
+		return 0
+	}
+}
+
+// severityText returns the severity text of the level, empty if unknown.
+func severityText(l level.Level) string {
+	if l == level.Unknown {
+		return ""
+	}
+	return strings.ToUpper(l.String())
+}
+
+// keyValue is an attribute.
+type keyValue struct {
+	key   string
+	value any
+}
+
+// resourceLogs are the log records of a file.
+type resourceLogs struct {
+	attributes []keyValue
+	records    []*gotailf.Record
+}
+
+type otlpSink struct {
+	url    string
+	config *Config
+}
+
+// New returns a sink that exports the records to the collector at u.
+// LogsPath is appended if u has no path.
+//
+// The records of a file are exported as a resource with log.file.path and log.file.name attributes.
+func New(u string, opts ...Option) (sink.Sink, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	x, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if x.Path == "" || x.Path == "/" {
+		x.Path = LogsPath
+	}
+	return &otlpSink{
+		url:    x.String(),
+		config: config,
+	}, nil
+}
+
+// resources groups the records by the files in order of the first records.
+func (s *otlpSink) resources(records []*gotailf.Record) []*resourceLogs {
+	var (
+		xs    []*resourceLogs
+		index = map[string]*resourceLogs{}
+	)
+	for _, r := range records {
+		x, ok := index[r.File]
+		if !ok {
+			path, err := filepath.Abs(r.File)
+			if err != nil {
+				path = r.File
+			}
+			x = &resourceLogs{
+				attributes: append(sortedAttributes(s.config.ResourceAttributes),
+					keyValue{key: "log.file.path", value: path},
+					keyValue{key: "log.file.name", value: filepath.Base(r.File)},
+				),
+			}
+			index[r.File] = x
+			xs = append(xs, x)
+		}
+		x.records = append(x.records, r)
+	}
+	return xs
+}
+
+func sortedAttributes(m map[string]string) []keyValue {
+	xs := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		xs = append(xs, keyValue{key: k, value: v})
+	}
+	slices.SortFunc(xs, func(a, b keyValue) int { return strings.Compare(a.key, b.key) })
+	return xs
+}
+
+func (s *otlpSink) fieldAttributes(r *gotailf.Record) []keyValue {
+	if !s.config.FieldAttributes || len(r.Fields) == 0 {
+		return nil
+	}
+	return mapKeyValues(r.Fields)
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+func (s *otlpSink) Write(ctx context.Context, records []*gotailf.Record) error {
+	var (
+		resources   = s.resources(records)
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch s.config.Encoding {
+	case EncodingJSON:
+		contentType = "application/json"
+		body, err = json.Marshal(s.jsonRequest(resources))
+	default:
+		contentType = "application/x-protobuf"
+		body = s.appendRequest(nil, resources)
+	}
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	if s.config.Gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, _ = gz.Write(body)
+		if err := gz.Close(); err != nil {
+			return sink.Permanent(err)
+		}
+		body = buf.Bytes()
+	}
+	return s.export(ctx, body, contentType)
+}
+
+func (s *otlpSink) export(ctx context.Context, body []byte, contentType string) error {
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return sink.Permanent(err)
+	}
+	for k, v := range s.config.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return httpsink.ResponseError(resp)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		// exported anyway
+		return nil
+	}
+	if err := partialSuccess(b, resp.Header.Get("Content-Type")); err != nil && s.config.OnRejected != nil {
+		s.config.OnRejected(err)
+	}
+	return nil
+}
+
+// partialSuccess returns the error of the rejected log records in ExportLogsServiceResponse.
+//
+//	message ExportLogsServiceResponse { ExportLogsPartialSuccess partial_success = 1; }
+//	message ExportLogsPartialSuccess { int64 rejected_log_records = 1; string error_message = 2; }
+func partialSuccess(b []byte, contentType string) error {
+	var (
+		rejected int64
+		message  string
+	)
+	if strings.HasPrefix(contentType, "application/json") {
+		var resp struct {
+			PartialSuccess struct {
+				RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+				ErrorMessage       string      `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return nil
+		}
+		rejected, _ = resp.PartialSuccess.RejectedLogRecords.Int64()
+		message = resp.PartialSuccess.ErrorMessage
+	} else {
+		fs, err := protowire.Fields(b)
+		if err != nil {
+			return nil
+		}
+		for _, f := range fs {
+			if f.Num != 1 {
+				continue
+			}
+			ps, err := protowire.Fields(f.Bytes)
+			if err != nil {
+				return nil
+			}
+			for _, p := range ps {
+				switch p.Num {
+				case 1:
+					rejected = int64(p.Uint)
+				case 2:
+					message = string(p.Bytes)
+				}
+			}
+		}
+	}
+	if rejected == 0 && message == "" {
+		return nil
+	}
+	return fmt.Errorf("%d log records rejected: %s", rejected, message)
+}
+
+// appendRequest appends ExportLogsServiceRequest.
+//
+//	message ExportLogsServiceRequest { repeated ResourceLogs resource_logs = 1; }
+//	message ResourceLogs { Resource resource = 1; repeated ScopeLogs scope_logs = 2; }
+//	message Resource { repeated KeyValue attributes = 1; }
+//	message ScopeLogs { InstrumentationScope scope = 1; repeated LogRecord log_records = 2; }
+//	message InstrumentationScope { string name = 1; }
+//	message LogRecord {
+//	  fixed64 time_unix_nano = 1; SeverityNumber severity_number = 2; string severity_text = 3;
+//	  AnyValue body = 5; repeated KeyValue attributes = 6; fixed64 observed_time_unix_nano = 11;
+//	}
+func (s *otlpSink) appendRequest(b []byte, resources []*resourceLogs) []byte {
+	for _, x := range resources {
+		b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+			b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+				return appendKeyValues(b, 1, x.attributes)
+			})
+			return protowire.AppendMessageField(b, 2, func(b []byte) []byte {
+				b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+					return protowire.AppendStringField(b, 1, ScopeName)
+				})
+				for _, r := range x.records {
+					b = protowire.AppendMessageField(b, 2, func(b []byte) []byte {
+						return s.appendLogRecord(b, r)
+					})
+				}
+				return b
+			})
+		})
+	}
+	return b
+}
+
+func (s *otlpSink) appendLogRecord(b []byte, r *gotailf.Record) []byte {
+	b = protowire.AppendFixed64Field(b, 1, unixNano(r.EventTime))
+	b = protowire.AppendVarintField(b, 2, uint64(SeverityNumber(r.Level)))
+	b = protowire.AppendStringField(b, 3, severityText(r.Level))
+	b = protowire.AppendMessageField(b, 5, func(b []byte) []byte {
+		return appendAnyValue(b, r.Text)
+	})
+	b = appendKeyValues(b, 6, s.fieldAttributes(r))
+	return protowire.AppendFixed64Field(b, 11, unixNano(r.Time))
+}
+
+// appendKeyValues appends the repeated KeyValue field.
+//
+//	message KeyValue { string key = 1; AnyValue value = 2; }
+func appendKeyValues(b []byte, num int, kvs []keyValue) []byte {
+	for _, kv := range kvs {
+		b = protowire.AppendMessageField(b, num, func(b []byte) []byte {
+			b = protowire.AppendStringField(b, 1, kv.key)
+			return protowire.AppendMessageField(b, 2, func(b []byte) []byte {
+				return appendAnyValue(b, kv.value)
+			})
+		})
+	}
+	return b
+}
+
+// appendAnyValue appends the fields of AnyValue.
+//
+//	message AnyValue {
+//	  oneof value {
+//	    string string_value = 1; bool bool_value = 2; int64 int_value = 3; double double_value = 4;
+//	    ArrayValue array_value = 5; KeyValueList kvlist_value = 6;
+//	  }
+//	}
+//	message ArrayValue { repeated AnyValue values = 1; }
+//	message KeyValueList { repeated KeyValue values = 1; }
+func appendAnyValue(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		// empty value
+		return b
+	case string:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		if v {
+			return protowire.AppendVarint(b, 1)
+		}
+		return protowire.AppendVarint(b, 0)
+	case int:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v))
+	case int64:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(v))
+	case float64:
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v))
+	case []any:
+		return protowire.AppendMessageField(b, 5, func(b []byte) []byte {
+			for _, x := range v {
+				b = protowire.AppendMessageField(b, 1, func(b []byte) []byte {
+					return appendAnyValue(b, x)
+				})
+			}
+			return b
+		})
+	case map[string]any:
+		return protowire.AppendMessageField(b, 6, func(b []byte) []byte {
+			return appendKeyValues(b, 1, mapKeyValues(v))
+		})
+	default:
+		return appendAnyValue(b, fmt.Sprint(v))
+	}
+}
+
+func mapKeyValues(m map[string]any) []keyValue {
+	xs := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		xs = append(xs, keyValue{key: k, value: v})
+	}
+	slices.SortFunc(xs, func(a, b keyValue) int { return strings.Compare(a.key, b.key) })
+	return xs
+}
+
+// jsonRequest returns ExportLogsServiceRequest in the OTLP/JSON encoding.
+func (s *otlpSink) jsonRequest(resources []*resourceLogs) map[string]any {
+	xs := make([]any, len(resources))
+	for i, x := range resources {
+		records := make([]any, len(x.records))
+		for j, r := range x.records {
+			records[j] = s.jsonLogRecord(r)
+		}
+		xs[i] = map[string]any{
+			"resource": map[string]any{
+				"attributes": jsonKeyValues(x.attributes),
+			},
+			"scopeLogs": []any{
+				map[string]any{
+					"scope":      map[string]any{"name": ScopeName},
+					"logRecords": records,
+				},
+			},
+		}
+	}
+	return map[string]any{"resourceLogs": xs}
+}
+
+func (s *otlpSink) jsonLogRecord(r *gotailf.Record) map[string]any {
+	x := map[string]any{
+		"observedTimeUnixNano": strconv.FormatUint(unixNano(r.Time), 10),
+		"body":                 jsonAnyValue(r.Text),
+	}
+	if t := unixNano(r.EventTime); t > 0 {
+		x["timeUnixNano"] = strconv.FormatUint(t, 10)
+	}
+	if n := SeverityNumber(r.Level); n > 0 {
+		x["severityNumber"] = n
+		x["severityText"] = severityText(r.Level)
+	}
+	if attrs := s.fieldAttributes(r); len(attrs) > 0 {
+		x["attributes"] = jsonKeyValues(attrs)
+	}
+	return x
+}
+
+func jsonKeyValues(kvs []keyValue) []any {
+	xs := make([]any, len(kvs))
+	for i, kv := range kvs {
+		xs[i] = map[string]any{
+			"key":   kv.key,
+			"value": jsonAnyValue(kv.value),
+		}
+	}
+	return xs
+}
+
+func jsonAnyValue(v any) map[string]any {
+	switch v := v.(type) {
+	case nil:
+		return map[string]any{}
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	case []any:
+		values := make([]any, len(v))
+		for i, x := range v {
+			values[i] = jsonAnyValue(x)
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	case map[string]any:
+		return map[string]any{"kvlistValue": map[string]any{"values": jsonKeyValues(mapKeyValues(v))}}
+	default:
+		return jsonAnyValue(fmt.Sprint(v))
+	}
+}
+
+func (s *otlpSink) Close() error {
+	s.config.Client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,342 @@
+package otlpsink_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal/protowire"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/sink/otlpsink"
+	"github.com/stretchr/testify/assert"
+)
+
+// logRecord is the decoded log record for the comparison.
+type logRecord struct {
+	Time       uint64
+	Observed   uint64
+	Severity   int
+	Text       string
+	Body       string
+	Attributes map[string]any
+}
+
+type resource struct {
+	Attributes map[string]any
+	Scope      string
+	Records    []*logRecord
+}
+
+type server struct {
+	mu        sync.Mutex
+	resources [][]*resource
+	// response is the body of the response.
+	response []byte
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != otlpsink.LogsPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var rs []*resource
+	switch r.Header.Get("Content-Type") {
+	case "application/x-protobuf":
+		rs, err = decodeProtobuf(body)
+	case "application/json":
+		rs, err = decodeJSON(body)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.resources = append(s.resources, rs)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(s.response)
+}
+
+func fields(b []byte) []*protowire.Field {
+	fs, err := protowire.Fields(b)
+	if err != nil {
+		panic(err)
+	}
+	return fs
+}
+
+func decodeAnyValue(b []byte) any {
+	for _, f := range fields(b) {
+		switch f.Num {
+		case 1:
+			return string(f.Bytes)
+		case 2:
+			return f.Uint == 1
+		case 3:
+			return int64(f.Uint)
+		case 4:
+			return math.Float64frombits(f.Uint)
+		case 5:
+			var xs []any
+			for _, v := range fields(f.Bytes) {
+				xs = append(xs, decodeAnyValue(v.Bytes))
+			}
+			return xs
+		case 6:
+			m := map[string]any{}
+			for _, v := range fields(f.Bytes) {
+				k, x := decodeKeyValue(v.Bytes)
+				m[k] = x
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+func decodeKeyValue(b []byte) (string, any) {
+	var (
+		key   string
+		value any
+	)
+	for _, f := range fields(b) {
+		switch f.Num {
+		case 1:
+			key = string(f.Bytes)
+		case 2:
+			value = decodeAnyValue(f.Bytes)
+		}
+	}
+	return key, value
+}
+
+func decodeProtobuf(b []byte) (rs []*resource, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = protowire.ErrInvalid
+		}
+	}()
+	for _, rl := range fields(b) {
+		res := &resource{Attributes: map[string]any{}}
+		for _, f := range fields(rl.Bytes) {
+			switch f.Num {
+			case 1:
+				for _, a := range fields(f.Bytes) {
+					k, v := decodeKeyValue(a.Bytes)
+					res.Attributes[k] = v
+				}
+			case 2:
+				for _, sf := range fields(f.Bytes) {
+					switch sf.Num {
+					case 1:
+						res.Scope = string(fields(sf.Bytes)[0].Bytes)
+					case 2:
+						lr := &logRecord{}
+						for _, x := range fields(sf.Bytes) {
+							switch x.Num {
+							case 1:
+								lr.Time = x.Uint
+							case 2:
+								lr.Severity = int(x.Uint)
+							case 3:
+								lr.Text = string(x.Bytes)
+							case 5:
+								lr.Body, _ = decodeAnyValue(x.Bytes).(string)
+							case 6:
+								if lr.Attributes == nil {
+									lr.Attributes = map[string]any{}
+								}
+								k, v := decodeKeyValue(x.Bytes)
+								lr.Attributes[k] = v
+							case 11:
+								lr.Observed = x.Uint
+							}
+						}
+						res.Records = append(res.Records, lr)
+					}
+				}
+			}
+		}
+		rs = append(rs, res)
+	}
+	return rs, nil
+}
+
+func decodeJSON(b []byte) ([]*resource, error) {
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string            `json:"key"`
+					Value map[string]string `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano         uint64 `json:"timeUnixNano,string"`
+					ObservedTimeUnixNano uint64 `json:"observedTimeUnixNano,string"`
+					SeverityNumber       int    `json:"severityNumber"`
+					SeverityText         string `json:"severityText"`
+					Body                 struct {
+						StringValue string `json:"stringValue"`
+					} `json:"body"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, err
+	}
+	var rs []*resource
+	for _, rl := range req.ResourceLogs {
+		res := &resource{Attributes: map[string]any{}}
+		for _, a := range rl.Resource.Attributes {
+			res.Attributes[a.Key] = a.Value["stringValue"]
+		}
+		for _, sl := range rl.ScopeLogs {
+			res.Scope = sl.Scope.Name
+			for _, lr := range sl.LogRecords {
+				res.Records = append(res.Records, &logRecord{
+					Time:     lr.TimeUnixNano,
+					Observed: lr.ObservedTimeUnixNano,
+					Severity: lr.SeverityNumber,
+					Text:     lr.SeverityText,
+					Body:     lr.Body.StringValue,
+				})
+			}
+		}
+		rs = append(rs, res)
+	}
+	return rs, nil
+}
+
+var (
+	readTime  = time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC)
+	eventTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	records   = []*gotailf.Record{
+		{File: "dir/a.log", Text: "a1", Time: readTime, EventTime: eventTime, Level: level.Warn},
+		{File: "b.log", Text: "b1", Time: readTime},
+		{File: "dir/a.log", Text: "a2", Time: readTime, Level: level.Error, Fields: parse.Fields{
+			"n":    float64(1.5),
+			"ok":   true,
+			"tags": []any{"x"},
+			"user": map[string]any{"id": "u"},
+		}},
+	}
+)
+
+func resourceAttributes(file string) map[string]any {
+	path, _ := filepath.Abs(file)
+	return map[string]any{
+		"service.name":  "gotailf",
+		"log.file.path": path,
+		"log.file.name": filepath.Base(file),
+	}
+}
+
+func TestSink(t *testing.T) {
+	for _, tc := range []struct {
+		encoding   otlpsink.Encoding
+		attributes map[string]any
+	}{
+		{
+			encoding: otlpsink.EncodingProtobuf,
+			attributes: map[string]any{
+				"n":    float64(1.5),
+				"ok":   true,
+				"tags": []any{"x"},
+				"user": map[string]any{"id": "u"},
+			},
+		},
+		{
+			encoding: otlpsink.EncodingJSON,
+		},
+	} {
+		t.Run(tc.encoding.String(), func(t *testing.T) {
+			srv := &server{}
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+			s, err := otlpsink.New(ts.URL,
+				otlpsink.WithEncoding(tc.encoding),
+				otlpsink.WithFieldAttributes(tc.encoding == otlpsink.EncodingProtobuf),
+			)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer s.Close()
+			assert.Nil(t, s.Write(context.TODO(), records))
+			assert.Equal(t, [][]*resource{{
+				{
+					Attributes: resourceAttributes("dir/a.log"),
+					Scope:      otlpsink.ScopeName,
+					Records: []*logRecord{
+						{
+							Time:     uint64(eventTime.UnixNano()),
+							Observed: uint64(readTime.UnixNano()),
+							Severity: 13,
+							Text:     "WARN",
+							Body:     "a1",
+						},
+						{
+							Observed:   uint64(readTime.UnixNano()),
+							Severity:   17,
+							Text:       "ERROR",
+							Body:       "a2",
+							Attributes: tc.attributes,
+						},
+					},
+				},
+				{
+					Attributes: resourceAttributes("b.log"),
+					Scope:      otlpsink.ScopeName,
+					Records: []*logRecord{
+						{
+							Observed: uint64(readTime.UnixNano()),
+							Body:     "b1",
+						},
+					},
+				},
+			}}, srv.resources)
+		})
+	}
+
+	t.Run("partial success", func(t *testing.T) {
+		var resp []byte
+		resp = protowire.AppendMessageField(resp, 1, func(b []byte) []byte {
+			b = protowire.AppendVarintField(b, 1, 2)
+			return protowire.AppendStringField(b, 2, "too old")
+		})
+		srv := &server{response: resp}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		var rejected []error
+		s, err := otlpsink.New(ts.URL, otlpsink.WithOnRejected(func(err error) {
+			rejected = append(rejected, err)
+		}))
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), records[:1]))
+		if assert.Equal(t, 1, len(rejected)) {
+			assert.Equal(t, "2 log records rejected: too old", rejected[0].Error())
+		}
+	})
+}
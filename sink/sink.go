@@ -0,0 +1,429 @@
+// Package sink provides the delivery of records to external systems.
+//
+// A Sink writes a batch of records.
+// A Deliverer batches the records yielded by a Tailer, retries the failed batches
+// with exponential backoff, writes the permanently failed batches into a dead-letter file,
+// and advances the checkpoint after the batches are delivered.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+)
+
+// Sink writes records to an external system.
+type Sink interface {
+	// Write writes the batch of the records.
+	// Returns a permanent error by Permanent() if the batch should not be retried.
+	Write(ctx context.Context, records []*gotailf.Record) error
+	// Close closes the sink.
+	Close() error
+}
+
+// PermanentError is an error that should not be retried.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps the error not to be retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent returns true if the error should not be retried.
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
+
+// RetryAfterError is an error that should be retried after the duration.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter wraps the error to be retried after the duration.
+// The duration takes precedence over the backoff if longer.
+func RetryAfter(err error, after time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryAfterError{
+		Err:   err,
+		After: after,
+	}
+}
+
+// PartialError means that some records of the batch failed.
+type PartialError struct {
+	// Retry are the records to be retried.
+	Retry []*gotailf.Record
+	// Rejected are the records failed permanently.
+	Rejected []*gotailf.Record
+	Err      error
+}
+
+func (e *PartialError) Error() string { return e.Err.Error() }
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// Partial returns an error that retries only the records in retry,
+// and dead-letters the records in rejected.
+// The other records of the batch are regarded as delivered.
+func Partial(retry, rejected []*gotailf.Record, err error) error {
+	return &PartialError{
+		Retry:    retry,
+		Rejected: rejected,
+		Err:      err,
+	}
+}
+
+// Config represents Deliverer configurations.
+type Config struct {
+	// BatchMaxCount is the max number of the records in a batch.
+	// Default is 500.
+	BatchMaxCount int
+	// BatchMaxBytes is the max total bytes of the texts of the records in a batch.
+	// Default is 1MiB.
+	BatchMaxBytes int
+	// BatchMaxLatency is the max time to hold the first record in a batch.
+	// Default is 1 second.
+	BatchMaxLatency time.Duration
+	// RetryInitialInterval is the interval before the first retry.
+	// Default is 100 milliseconds.
+	RetryInitialInterval time.Duration
+	// RetryMaxInterval is the max interval between retries.
+	// Default is 30 seconds.
+	RetryMaxInterval time.Duration
+	// RetryMultiplier is the factor of the interval for each retry.
+	// Default is 2.
+	RetryMultiplier float64
+	// RetryJitter is the ratio of the randomization of the interval, 0 to 1.
+	// Default is 0.2.
+	RetryJitter float64
+	// MaxRetries is the max number of the retries of a batch.
+	// Negative means unlimited.
+	// Default is 5.
+	MaxRetries int
+	// DeadLetter is the file to append the batches failed permanently or exceeded MaxRetries as JSON lines.
+	// If empty, Run() fails by such batches.
+	// Default is empty.
+	DeadLetter string
+	// Checkpoint is advanced after the batches are delivered or dead-lettered.
+	// Default is nil.
+	Checkpoint *Checkpoint
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		BatchMaxCount:        500,
+		BatchMaxBytes:        1024 * 1024,
+		BatchMaxLatency:      time.Second,
+		RetryInitialInterval: 100 * time.Millisecond,
+		RetryMaxInterval:     30 * time.Second,
+		RetryMultiplier:      2,
+		RetryJitter:          0.2,
+		MaxRetries:           5,
+	}
+}
+
+type Option func(*Config)
+
+// WithBatchMaxCount sets Config.BatchMaxCount.
+func WithBatchMaxCount(count int) Option {
+	return func(c *Config) {
+		c.BatchMaxCount = count
+	}
+}
+
+// WithBatchMaxBytes sets Config.BatchMaxBytes.
+func WithBatchMaxBytes(size int) Option {
+	return func(c *Config) {
+		c.BatchMaxBytes = size
+	}
+}
+
+// WithBatchMaxLatency sets Config.BatchMaxLatency.
+func WithBatchMaxLatency(latency time.Duration) Option {
+	return func(c *Config) {
+		c.BatchMaxLatency = latency
+	}
+}
+
+// WithRetryInitialInterval sets Config.RetryInitialInterval.
+func WithRetryInitialInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.RetryInitialInterval = interval
+	}
+}
+
+// WithRetryMaxInterval sets Config.RetryMaxInterval.
+func WithRetryMaxInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.RetryMaxInterval = interval
+	}
+}
+
+// WithRetryMultiplier sets Config.RetryMultiplier.
+func WithRetryMultiplier(multiplier float64) Option {
+	return func(c *Config) {
+		c.RetryMultiplier = multiplier
+	}
+}
+
+// WithRetryJitter sets Config.RetryJitter.
+func WithRetryJitter(jitter float64) Option {
+	return func(c *Config) {
+		c.RetryJitter = jitter
+	}
+}
+
+// WithMaxRetries sets Config.MaxRetries.
+func WithMaxRetries(n int) Option {
+	return func(c *Config) {
+		c.MaxRetries = n
+	}
+}
+
+// WithDeadLetter sets Config.DeadLetter.
+func WithDeadLetter(filename string) Option {
+	return func(c *Config) {
+		c.DeadLetter = filename
+	}
+}
+
+// WithCheckpoint sets Config.Checkpoint.
+func WithCheckpoint(c *Checkpoint) Option {
+	return func(config *Config) {
+		config.Checkpoint = c
+	}
+}
+
+// Stats is the statistics of the delivery.
+type Stats struct {
+	// Batches is the number of the delivered batches.
+	Batches int64
+	// Records is the number of the delivered records.
+	Records int64
+	// Retries is the number of the retries.
+	Retries int64
+	// DeadLettered is the number of the records written into the dead-letter file.
+	DeadLettered int64
+}
+
+// Deliverer delivers records to a sink.
+type Deliverer struct {
+	sink   Sink
+	config *Config
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New returns a new Deliverer.
+func New(sink Sink, opts ...Option) *Deliverer {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Deliverer{
+		sink:   sink,
+		config: config,
+	}
+}
+
+// Stats returns the statistics of the delivery.
+func (d *Deliverer) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+func (d *Deliverer) addStats(f func(s *Stats)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f(&d.stats)
+}
+
+// Run delivers the records in batches until the records are closed or ctx ends.
+// Returns nil when all the records are delivered.
+// The batch in delivery when ctx ends is not checkpointed.
+func (d *Deliverer) Run(ctx context.Context, recordC <-chan *gotailf.Record) error {
+	var (
+		batch []*gotailf.Record
+		size  int
+		timer = time.NewTimer(d.config.BatchMaxLatency)
+	)
+	timer.Stop()
+	defer timer.Stop()
+	flush := func() error {
+		timer.Stop()
+		if len(batch) == 0 {
+			return nil
+		}
+		err := d.deliver(ctx, batch)
+		batch = nil
+		size = 0
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case r, ok := <-recordC:
+			if !ok {
+				return flush()
+			}
+			if len(batch) > 0 && size+len(r.Text) > d.config.BatchMaxBytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if len(batch) == 0 {
+				timer.Reset(d.config.BatchMaxLatency)
+			}
+			batch = append(batch, r)
+			size += len(r.Text)
+			if len(batch) >= d.config.BatchMaxCount || size >= d.config.BatchMaxBytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-timer.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver writes the batch with retries.
+// Only the records in PartialError.Retry are retried.
+func (d *Deliverer) deliver(ctx context.Context, batch []*gotailf.Record) error {
+	all := batch
+	for attempt := 0; ; attempt++ {
+		err := d.sink.Write(ctx, batch)
+		var partial *PartialError
+		if err != nil && !IsPermanent(err) && errors.As(err, &partial) {
+			if len(partial.Rejected) > 0 {
+				if err := d.deadLetter(partial.Rejected, partial.Err); err != nil {
+					return err
+				}
+				d.addStats(func(s *Stats) { s.DeadLettered += int64(len(partial.Rejected)) })
+			}
+			delivered := len(batch) - len(partial.Retry) - len(partial.Rejected)
+			d.addStats(func(s *Stats) { s.Records += int64(delivered) })
+			batch = partial.Retry
+			if len(batch) == 0 {
+				err = nil
+			}
+		} else if err == nil {
+			d.addStats(func(s *Stats) { s.Records += int64(len(batch)) })
+		}
+		if err == nil {
+			d.addStats(func(s *Stats) { s.Batches++ })
+			return d.checkpoint(all)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if IsPermanent(err) || (d.config.MaxRetries >= 0 && attempt >= d.config.MaxRetries) {
+			if err := d.deadLetter(batch, err); err != nil {
+				return err
+			}
+			d.addStats(func(s *Stats) { s.DeadLettered += int64(len(batch)) })
+			return d.checkpoint(all)
+		}
+		t := time.NewTimer(d.wait(attempt, err))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		d.addStats(func(s *Stats) { s.Retries++ })
+	}
+}
+
+// wait returns the interval before the retry after the attempt failed by err.
+func (d *Deliverer) wait(attempt int, err error) time.Duration {
+	interval := d.backoff(attempt)
+	var r *RetryAfterError
+	if errors.As(err, &r) && r.After > interval {
+		return r.After
+	}
+	return interval
+}
+
+// backoff returns the interval before the retry after the attempt, 0-based.
+func (d *Deliverer) backoff(attempt int) time.Duration {
+	interval := float64(d.config.RetryInitialInterval)
+	for i := 0; i < attempt && interval < float64(d.config.RetryMaxInterval); i++ {
+		interval *= d.config.RetryMultiplier
+	}
+	if interval > float64(d.config.RetryMaxInterval) {
+		interval = float64(d.config.RetryMaxInterval)
+	}
+	interval *= 1 + d.config.RetryJitter*(rand.Float64()*2-1)
+	return time.Duration(interval)
+}
+
+func (d *Deliverer) checkpoint(batch []*gotailf.Record) error {
+	if d.config.Checkpoint == nil {
+		return nil
+	}
+	return d.config.Checkpoint.Advance(batch)
+}
+
+// deadLetterRecord is a line of the dead-letter file.
+type deadLetterRecord struct {
+	Error  string             `json:"error"`
+	Time   time.Time          `json:"time"`
+	Record *format.JSONRecord `json:"record"`
+}
+
+func (d *Deliverer) deadLetter(batch []*gotailf.Record, cause error) error {
+	if d.config.DeadLetter == "" {
+		return fmt.Errorf("delivery failed: %w", cause)
+	}
+	f, err := os.OpenFile(d.config.DeadLetter, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	var (
+		enc = json.NewEncoder(f)
+		now = time.Now()
+	)
+	for _, r := range batch {
+		if err := enc.Encode(&deadLetterRecord{
+			Error:  cause.Error(),
+			Time:   now,
+			Record: format.NewJSONRecord(r),
+		}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
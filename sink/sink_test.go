@@ -0,0 +1,314 @@
+package sink_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/sink"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockSink records the batches and fails by errs in order.
+type mockSink struct {
+	mu      sync.Mutex
+	batches [][]string
+	errs    []error
+	closed  bool
+}
+
+func (s *mockSink) Write(_ context.Context, records []*gotailf.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	texts := make([]string, len(records))
+	for i, r := range records {
+		texts[i] = r.Text
+	}
+	s.batches = append(s.batches, texts)
+	return nil
+}
+
+func (s *mockSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func newRecordC(file string, texts ...string) <-chan *gotailf.Record {
+	c := make(chan *gotailf.Record, len(texts))
+	var offset int64
+	for _, x := range texts {
+		end := offset + int64(len(x)) + 1
+		c <- &gotailf.Record{
+			File:   file,
+			Offset: offset,
+			End:    end,
+			Text:   x,
+		}
+		offset = end
+	}
+	close(c)
+	return c
+}
+
+func fastRetry() []sink.Option {
+	return []sink.Option{
+		sink.WithRetryInitialInterval(time.Millisecond),
+		sink.WithRetryMaxInterval(time.Millisecond),
+	}
+}
+
+func TestDeliverer(t *testing.T) {
+	t.Run("batch by count", func(t *testing.T) {
+		s := &mockSink{}
+		d := sink.New(s, sink.WithBatchMaxCount(2))
+		assert.Nil(t, d.Run(context.TODO(), newRecordC("f", "a", "b", "c", "d", "e")))
+		assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, s.batches)
+		assert.Equal(t, sink.Stats{
+			Batches: 3,
+			Records: 5,
+		}, d.Stats())
+	})
+
+	t.Run("batch by bytes", func(t *testing.T) {
+		s := &mockSink{}
+		d := sink.New(s, sink.WithBatchMaxBytes(4))
+		assert.Nil(t, d.Run(context.TODO(), newRecordC("f", "aa", "bb", "ccc", "dd")))
+		assert.Equal(t, [][]string{{"aa", "bb"}, {"ccc"}, {"dd"}}, s.batches)
+	})
+
+	t.Run("batch by latency", func(t *testing.T) {
+		var (
+			s       = &mockSink{}
+			d       = sink.New(s, sink.WithBatchMaxLatency(10*time.Millisecond))
+			recordC = make(chan *gotailf.Record)
+			errC    = make(chan error)
+		)
+		go func() {
+			errC <- d.Run(context.TODO(), recordC)
+		}()
+		recordC <- &gotailf.Record{Text: "a"}
+		assert.Eventually(t, func() bool {
+			return d.Stats().Batches == 1
+		}, time.Second, 5*time.Millisecond)
+		recordC <- &gotailf.Record{Text: "b"}
+		close(recordC)
+		assert.Nil(t, <-errC)
+		assert.Equal(t, [][]string{{"a"}, {"b"}}, s.batches)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		s := &mockSink{
+			errs: []error{errors.New("e1"), errors.New("e2")},
+		}
+		d := sink.New(s, fastRetry()...)
+		assert.Nil(t, d.Run(context.TODO(), newRecordC("f", "a")))
+		assert.Equal(t, [][]string{{"a"}}, s.batches)
+		assert.Equal(t, sink.Stats{
+			Batches: 1,
+			Records: 1,
+			Retries: 2,
+		}, d.Stats())
+	})
+
+	t.Run("exceeded max retries without dead letter", func(t *testing.T) {
+		s := &mockSink{
+			errs: []error{errors.New("e1"), errors.New("e2")},
+		}
+		d := sink.New(s, append(fastRetry(), sink.WithMaxRetries(1))...)
+		err := d.Run(context.TODO(), newRecordC("f", "a"))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "e2")
+		}
+		assert.Equal(t, 0, len(s.batches))
+	})
+
+	t.Run("dead letter", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			deadLetter = dir.Path("dead")
+			cp, err    = sink.OpenCheckpoint(dir.Path("checkpoint"))
+		)
+		if !assert.Nil(t, err) {
+			return
+		}
+		s := &mockSink{
+			errs: []error{sink.Permanent(errors.New("bad")), nil},
+		}
+		d := sink.New(s,
+			sink.WithBatchMaxCount(2),
+			sink.WithDeadLetter(deadLetter),
+			sink.WithCheckpoint(cp),
+		)
+		assert.Nil(t, d.Run(context.TODO(), newRecordC("f", "a", "b", "c")))
+		assert.Equal(t, [][]string{{"c"}}, s.batches)
+		assert.Equal(t, sink.Stats{
+			Batches:      1,
+			Records:      1,
+			DeadLettered: 2,
+		}, d.Stats())
+		assert.Equal(t, int64(6), cp.Offset("f"))
+
+		b, err := os.ReadFile(deadLetter)
+		if !assert.Nil(t, err) {
+			return
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if !assert.Equal(t, 2, len(lines)) {
+			return
+		}
+		var x struct {
+			Error  string             `json:"error"`
+			Record *format.JSONRecord `json:"record"`
+		}
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &x))
+		assert.Equal(t, "bad", x.Error)
+		assert.Equal(t, "b", x.Record.Text)
+		assert.Equal(t, int64(2), x.Record.Offset)
+	})
+
+	t.Run("checkpoint only delivered", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		cp, err := sink.OpenCheckpoint(dir.Path("checkpoint"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		s := &mockSink{
+			errs: []error{nil, errors.New("down")},
+		}
+		d := sink.New(s, append(fastRetry(),
+			sink.WithBatchMaxCount(2),
+			sink.WithMaxRetries(0),
+			sink.WithCheckpoint(cp),
+		)...)
+		assert.NotNil(t, d.Run(context.TODO(), newRecordC("f", "a", "b", "c")))
+		assert.Equal(t, int64(4), cp.Offset("f"))
+		assert.Equal(t, int64(-1), cp.Offset("g"))
+
+		reopened, err := sink.OpenCheckpoint(dir.Path("checkpoint"))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Equal(t, int64(4), reopened.Offset("f"))
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		s := &partialSink{}
+		d := sink.New(s, append(fastRetry(), sink.WithDeadLetter(dir.Path("dead")))...)
+		assert.Nil(t, d.Run(context.TODO(), newRecordC("f", "a", "b", "c")))
+		assert.Equal(t, [][]string{{"a", "b", "c"}, {"b"}}, s.batches)
+		assert.Equal(t, sink.Stats{
+			Batches:      1,
+			Records:      2,
+			Retries:      1,
+			DeadLettered: 1,
+		}, d.Stats())
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		s := &mockSink{}
+		d := sink.New(s)
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		err := d.Run(ctx, make(chan *gotailf.Record))
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	cp, err := sink.OpenCheckpoint(dir.Path("checkpoint"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, cp.Advance([]*gotailf.Record{
+		{File: "f", Offset: 0, End: 2},
+		{File: "g", Offset: 0, End: 3},
+		{File: "f", Offset: 2, End: 5},
+		{File: "g", Offset: 3, End: 3, Skipped: 10},
+	}))
+	assert.Equal(t, int64(5), cp.Offset("f"))
+	assert.Equal(t, int64(3), cp.Offset("g"))
+	// rotated
+	assert.Nil(t, cp.Advance([]*gotailf.Record{
+		{File: "f", Offset: 0, End: 1},
+	}))
+	assert.Equal(t, int64(1), cp.Offset("f"))
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := sink.NewWriter(&buf, format.NewRaw())
+		assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{
+			{Text: "a"},
+			{Text: "b"},
+		}))
+		assert.Nil(t, s.Close())
+		assert.Equal(t, "a\nb\n", buf.String())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		var (
+			path   = dir.Path("out")
+			s, err = sink.NewFile(path, format.NewRaw())
+		)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{{Text: "a"}}))
+		assert.Nil(t, s.Close())
+
+		s, err = sink.NewFile(path, format.NewRaw())
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Nil(t, s.Write(context.TODO(), []*gotailf.Record{{Text: "b"}}))
+		assert.Nil(t, s.Close())
+
+		b, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "a\nb\n", string(b))
+	})
+}
+
+// partialSink retries the second record and rejects the last record of the first batch.
+type partialSink struct {
+	batches [][]string
+}
+
+func (s *partialSink) Write(_ context.Context, records []*gotailf.Record) error {
+	texts := make([]string, len(records))
+	for i, r := range records {
+		texts[i] = r.Text
+	}
+	s.batches = append(s.batches, texts)
+	if len(s.batches) == 1 {
+		return sink.Partial(records[1:2], records[2:], errors.New("partial"))
+	}
+	return nil
+}
+
+func (*partialSink) Close() error { return nil }
@@ -0,0 +1,509 @@
+// Package syslogsink provides a sink that forwards the records to syslog.
+//
+// The messages are formatted by RFC 5424 or RFC 3164,
+// and sent over UDP, TCP, TLS or a unix socket such as /dev/log.
+package syslogsink
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/sink"
+)
+
+// Format is the format of the syslog messages.
+type Format int
+
+const (
+	// RFC5424 is the syslog protocol.
+	RFC5424 Format = iota
+	// RFC3164 is the BSD syslog protocol.
+	RFC3164
+)
+
+func (f Format) String() string {
+	switch f {
+	case RFC5424:
+		return "rfc5424"
+	case RFC3164:
+		return "rfc3164"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownFormat means that the format name is invalid.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ParseFormat returns the format of the name, the result of Format.String().
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "rfc5424", "5424":
+		return RFC5424, nil
+	case "rfc3164", "3164", "bsd":
+		return RFC3164, nil
+	default:
+		return RFC5424, fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+	}
+}
+
+// Framing is the framing of the messages on the stream transports (RFC 6587).
+type Framing int
+
+const (
+	// FramingAuto is FramingOctetCounting with RFC5424 and FramingNonTransparent with RFC3164.
+	FramingAuto Framing = iota
+	// FramingOctetCounting prefixes the messages with the lengths.
+	FramingOctetCounting
+	// FramingNonTransparent terminates the messages with LF.
+	FramingNonTransparent
+)
+
+func (f Framing) String() string {
+	switch f {
+	case FramingAuto:
+		return "auto"
+	case FramingOctetCounting:
+		return "octet-counting"
+	case FramingNonTransparent:
+		return "non-transparent"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownFraming means that the framing name is invalid.
+var ErrUnknownFraming = errors.New("unknown framing")
+
+// ParseFraming returns the framing of the name, the result of Framing.String().
+func ParseFraming(name string) (Framing, error) {
+	switch strings.ToLower(name) {
+	case "auto":
+		return FramingAuto, nil
+	case "octet-counting", "octet":
+		return FramingOctetCounting, nil
+	case "non-transparent", "lf":
+		return FramingNonTransparent, nil
+	default:
+		return FramingAuto, fmt.Errorf("%w: %s", ErrUnknownFraming, name)
+	}
+}
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// ErrUnknownFacility means that the facility is invalid.
+var ErrUnknownFacility = errors.New("unknown facility")
+
+// ParseFacility returns the facility code of the name, e.g. user or local0, or the number.
+func ParseFacility(name string) (int, error) {
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < len(facilities) {
+		return n, nil
+	}
+	for i, x := range facilities {
+		if strings.EqualFold(x, name) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownFacility, name)
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// ErrUnknownSeverity means that the severity is invalid.
+var ErrUnknownSeverity = errors.New("unknown severity")
+
+// ParseSeverity returns the severity code of the name, e.g. err or info, or the number.
+func ParseSeverity(name string) (int, error) {
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 && n < len(severities) {
+		return n, nil
+	}
+	for i, x := range severities {
+		if strings.EqualFold(x, name) {
+			return i, nil
+		}
+	}
+	switch strings.ToLower(name) {
+	case "emergency", "panic":
+		return 0, nil
+	case "critical":
+		return 2, nil
+	case "error":
+		return 3, nil
+	case "warn":
+		return 4, nil
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknownSeverity, name)
+}
+
+// DefaultSocket is the local syslog socket used when the address is empty.
+const DefaultSocket = "/dev/log"
+
+// Config represents the sink configurations.
+type Config struct {
+	// Format is the format of the messages.
+	// Default is RFC5424.
+	Format Format
+	// Framing is the framing of the messages on tcp, tls and unix.
+	// Default is FramingAuto.
+	Framing Framing
+	// Facility is the facility code.
+	// Default is 1 (user).
+	Facility int
+	// Severity is the severity code.
+	// Default is 6 (info).
+	Severity int
+	// SeverityFromLevel uses the severity of the detected level of the record if known.
+	// Default is false.
+	SeverityFromLevel bool
+	// AppName is the APP-NAME of RFC5424 or the TAG of RFC3164.
+	// Default is the base name of the command.
+	AppName string
+	// Hostname is the HOSTNAME.
+	// Empty means NILVALUE on RFC5424, and omitted on RFC3164.
+	// Default is os.Hostname().
+	Hostname string
+	// ProcID is the PROCID.
+	// Default is empty, NILVALUE.
+	ProcID string
+	// MsgID is the MSGID of RFC5424.
+	// Default is empty, NILVALUE.
+	MsgID string
+	// TLSConfig is the configuration of the tls network.
+	// Default is nil, the default configuration with the server name of the address.
+	TLSConfig *tls.Config
+	// Timeout is the timeout of the dial and the write of a batch.
+	// Default is 10 seconds.
+	Timeout time.Duration
+}
+
+func newDefaultConfig() *Config {
+	c := &Config{
+		Facility: 1,
+		Severity: 6,
+		AppName:  filepath.Base(os.Args[0]),
+		Timeout:  10 * time.Second,
+	}
+	if h, err := os.Hostname(); err == nil {
+		c.Hostname = h
+	}
+	return c
+}
+
+type Option func(*Config)
+
+// WithFormat sets Config.Format.
+func WithFormat(format Format) Option {
+	return func(c *Config) {
+		c.Format = format
+	}
+}
+
+// WithFraming sets Config.Framing.
+func WithFraming(framing Framing) Option {
+	return func(c *Config) {
+		c.Framing = framing
+	}
+}
+
+// WithFacility sets Config.Facility.
+func WithFacility(facility int) Option {
+	return func(c *Config) {
+		c.Facility = facility
+	}
+}
+
+// WithSeverity sets Config.Severity.
+func WithSeverity(severity int) Option {
+	return func(c *Config) {
+		c.Severity = severity
+	}
+}
+
+// WithSeverityFromLevel sets Config.SeverityFromLevel.
+func WithSeverityFromLevel(enabled bool) Option {
+	return func(c *Config) {
+		c.SeverityFromLevel = enabled
+	}
+}
+
+// WithAppName sets Config.AppName.
+func WithAppName(name string) Option {
+	return func(c *Config) {
+		c.AppName = name
+	}
+}
+
+// WithHostname sets Config.Hostname.
+func WithHostname(name string) Option {
+	return func(c *Config) {
+		c.Hostname = name
+	}
+}
+
+// WithProcID sets Config.ProcID.
+func WithProcID(id string) Option {
+	return func(c *Config) {
+		c.ProcID = id
+	}
+}
+
+// WithMsgID sets Config.MsgID.
+func WithMsgID(id string) Option {
+	return func(c *Config) {
+		c.MsgID = id
+	}
+}
+
+// WithTLSConfig sets Config.TLSConfig.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = config
+	}
+}
+
+// WithTimeout sets Config.Timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// ErrUnknownNetwork means that the network is not supported.
+var ErrUnknownNetwork = errors.New("unknown network")
+
+type syslogSink struct {
+	network string
+	address string
+	config  *Config
+
+	mu   sync.Mutex
+	conn net.Conn
+	// stream is true if conn is not datagram.
+	stream bool
+}
+
+// New returns a sink that sends the records to the syslog server.
+//
+// The network is udp, tcp, tls, unix (stream), unixgram or empty.
+// Empty network with empty address is the local syslog, DefaultSocket by unixgram or unix.
+// The connection is established by the first write, and reestablished after failures.
+func New(network, address string, opts ...Option) (sink.Sink, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	switch network {
+	case "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls", "unix", "unixgram":
+	case "":
+		if address == "" {
+			address = DefaultSocket
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNetwork, network)
+	}
+	if address == "" {
+		return nil, errors.New("address is required")
+	}
+	if config.Facility < 0 || config.Facility >= len(facilities) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownFacility, config.Facility)
+	}
+	if config.Severity < 0 || config.Severity >= len(severities) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSeverity, config.Severity)
+	}
+	return &syslogSink{
+		network: network,
+		address: address,
+		config:  config,
+	}, nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, bool, error) {
+	d := &net.Dialer{
+		Timeout: s.config.Timeout,
+	}
+	switch s.network {
+	case "tls":
+		config := s.config.TLSConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+		conn, err := (&tls.Dialer{
+			NetDialer: d,
+			Config:    config,
+		}).DialContext(ctx, "tcp", s.address)
+		return conn, true, err
+	case "":
+		// the local syslog accepts either of them
+		if conn, err := d.DialContext(ctx, "unixgram", s.address); err == nil {
+			return conn, false, nil
+		}
+		conn, err := d.DialContext(ctx, "unix", s.address)
+		return conn, true, err
+	default:
+		conn, err := d.DialContext(ctx, s.network, s.address)
+		stream := s.network != "unixgram" && !strings.HasPrefix(s.network, "udp")
+		return conn, stream, err
+	}
+}
+
+func (s *syslogSink) Write(ctx context.Context, records []*gotailf.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, stream, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.stream = stream
+	}
+	if s.config.Timeout > 0 {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+	}
+	if err := s.write(records); err != nil {
+		// reconnect by the retry
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *syslogSink) write(records []*gotailf.Record) error {
+	if !s.stream {
+		// a message per datagram
+		var buf []byte
+		for _, r := range records {
+			buf = s.appendMessage(buf[:0], r)
+			if _, err := s.conn.Write(buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var buf, msg []byte
+	for _, r := range records {
+		msg = s.appendMessage(msg[:0], r)
+		buf = s.appendFrame(buf, msg)
+	}
+	_, err := s.conn.Write(buf)
+	return err
+}
+
+func (s *syslogSink) appendFrame(buf, msg []byte) []byte {
+	framing := s.config.Framing
+	if framing == FramingAuto {
+		framing = FramingOctetCounting
+		if s.config.Format == RFC3164 {
+			framing = FramingNonTransparent
+		}
+	}
+	if framing == FramingOctetCounting {
+		buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+		buf = append(buf, ' ')
+		return append(buf, msg...)
+	}
+	buf = append(buf, msg...)
+	return append(buf, '\n')
+}
+
+// priority returns the PRI of the record.
+func (s *syslogSink) priority(r *gotailf.Record) int {
+	severity := s.config.Severity
+	if s.config.SeverityFromLevel {
+		if x := r.Level.SyslogSeverity(); x >= 0 {
+			severity = x
+		}
+	}
+	return s.config.Facility*8 + severity
+}
+
+// appendMessage appends the syslog message of the record, not framed.
+func (s *syslogSink) appendMessage(buf []byte, r *gotailf.Record) []byte {
+	t := r.EventTime
+	if t.IsZero() {
+		t = r.Time
+	}
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(s.priority(r)), 10)
+	buf = append(buf, '>')
+	if s.config.Format == RFC3164 {
+		buf = t.AppendFormat(buf, time.Stamp)
+		buf = append(buf, ' ')
+		if s.config.Hostname != "" {
+			buf = append(buf, headerValue(s.config.Hostname, 255)...)
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, headerValue(s.config.AppName, 32)...)
+		if s.config.ProcID != "" {
+			buf = append(buf, '[')
+			buf = append(buf, headerValue(s.config.ProcID, 128)...)
+			buf = append(buf, ']')
+		}
+		buf = append(buf, ": "...)
+		return append(buf, r.Text...)
+	}
+	buf = append(buf, "1 "...)
+	if t.IsZero() {
+		buf = append(buf, '-')
+	} else {
+		buf = t.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+	}
+	for _, x := range []struct {
+		v string
+		n int
+	}{
+		{v: s.config.Hostname, n: 255},
+		{v: s.config.AppName, n: 48},
+		{v: s.config.ProcID, n: 128},
+		{v: s.config.MsgID, n: 32},
+	} {
+		buf = append(buf, ' ')
+		buf = append(buf, headerValue(x.v, x.n)...)
+	}
+	// no STRUCTURED-DATA
+	buf = append(buf, " - "...)
+	return append(buf, r.Text...)
+}
+
+// headerValue returns the printable US-ASCII value up to n bytes, or NILVALUE if empty.
+func headerValue(v string, n int) string {
+	if v == "" {
+		return "-"
+	}
+	b := []byte(v)
+	if len(b) > n {
+		b = b[:n]
+	}
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,253 @@
+package syslogsink_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/sink/syslogsink"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	eventTime = time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	records   = []*gotailf.Record{
+		{Text: "hello", EventTime: eventTime},
+		{Text: "failed", EventTime: eventTime, Level: level.Error},
+	}
+)
+
+func defaultOpts(opts ...syslogsink.Option) []syslogsink.Option {
+	return append([]syslogsink.Option{
+		syslogsink.WithAppName("app"),
+		syslogsink.WithHostname("host"),
+	}, opts...)
+}
+
+// readPackets reads n datagrams.
+func readPackets(t *testing.T, conn net.PacketConn, n int) []string {
+	var (
+		buf = make([]byte, 65536)
+		got []string
+	)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < n; i++ {
+		m, _, err := conn.ReadFrom(buf)
+		if !assert.Nil(t, err) {
+			return got
+		}
+		got = append(got, string(buf[:m]))
+	}
+	return got
+}
+
+// readOctetCounting reads n frames.
+func readOctetCounting(t *testing.T, r *bufio.Reader, n int) []string {
+	var got []string
+	for i := 0; i < n; i++ {
+		size, err := r.ReadString(' ')
+		if !assert.Nil(t, err) {
+			return got
+		}
+		m, err := strconv.Atoi(strings.TrimSuffix(size, " "))
+		if !assert.Nil(t, err) {
+			return got
+		}
+		buf := make([]byte, m)
+		if _, err := io.ReadFull(r, buf); !assert.Nil(t, err) {
+			return got
+		}
+		got = append(got, string(buf))
+	}
+	return got
+}
+
+func acceptOne(t *testing.T, ln net.Listener) <-chan *bufio.Reader {
+	c := make(chan *bufio.Reader, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if !assert.Nil(t, err) {
+			close(c)
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		if x, ok := conn.(*tls.Conn); ok {
+			// the client waits for the handshake
+			assert.Nil(t, x.Handshake())
+		}
+		c <- bufio.NewReader(conn)
+	}()
+	return c
+}
+
+func TestSink(t *testing.T) {
+	t.Run("udp rfc5424", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+		s, err := syslogsink.New("udp", conn.LocalAddr().String(), defaultOpts(
+			syslogsink.WithFacility(16),
+			syslogsink.WithSeverityFromLevel(true),
+			syslogsink.WithMsgID("m"),
+		)...)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), records))
+		assert.Equal(t, []string{
+			"<134>1 2026-01-02T03:04:05.600000Z host app - m - hello",
+			"<131>1 2026-01-02T03:04:05.600000Z host app - m - failed",
+		}, readPackets(t, conn, 2))
+	})
+
+	t.Run("tcp octet counting", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer ln.Close()
+		connC := acceptOne(t, ln)
+		s, err := syslogsink.New("tcp", ln.Addr().String(), defaultOpts(
+			syslogsink.WithProcID("42"),
+		)...)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), records))
+		assert.Equal(t, []string{
+			"<14>1 2026-01-02T03:04:05.600000Z host app 42 - - hello",
+			"<14>1 2026-01-02T03:04:05.600000Z host app 42 - - failed",
+		}, readOctetCounting(t, <-connC, 2))
+	})
+
+	t.Run("tls rfc3164", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+		ts.StartTLS()
+		defer ts.Close()
+		var (
+			serverConfig = ts.TLS.Clone()
+			clientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+		)
+		serverConfig.NextProtos = nil
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer ln.Close()
+		connC := acceptOne(t, ln)
+		s, err := syslogsink.New("tls", ln.Addr().String(), defaultOpts(
+			syslogsink.WithFormat(syslogsink.RFC3164),
+			syslogsink.WithProcID("42"),
+			syslogsink.WithTLSConfig(clientConfig),
+		)...)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), records[:1]))
+		r := <-connC
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		assert.Equal(t, "<14>Jan  2 03:04:05 host app[42]: hello\n", line)
+	})
+
+	t.Run("local socket", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		path := dir.Path("log")
+		conn, err := net.ListenPacket("unixgram", path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+		s, err := syslogsink.New("", path, defaultOpts(
+			syslogsink.WithFormat(syslogsink.RFC3164),
+			syslogsink.WithHostname(""),
+		)...)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		assert.Nil(t, s.Write(context.TODO(), records[:1]))
+		assert.Equal(t, []string{
+			"<14>Jan  2 03:04:05 app: hello",
+		}, readPackets(t, conn, 1))
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		path := dir.Path("log")
+		s, err := syslogsink.New("unix", path, defaultOpts()...)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer s.Close()
+		// not listening yet
+		assert.NotNil(t, s.Write(context.TODO(), records[:1]))
+
+		ln, err := net.Listen("unix", path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer ln.Close()
+		connC := acceptOne(t, ln)
+		assert.Nil(t, s.Write(context.TODO(), records[:1]))
+		assert.Equal(t, []string{
+			"<14>1 2026-01-02T03:04:05.600000Z host app - - - hello",
+		}, readOctetCounting(t, <-connC, 1))
+	})
+
+	t.Run("unknown network", func(t *testing.T) {
+		_, err := syslogsink.New("sctp", "x")
+		assert.ErrorIs(t, err, syslogsink.ErrUnknownNetwork)
+	})
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want int
+	}{
+		{name: "user", want: 1},
+		{name: "LOCAL0", want: 16},
+		{name: "23", want: 23},
+	} {
+		got, err := syslogsink.ParseFacility(tc.name)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+	_, err := syslogsink.ParseFacility("24")
+	assert.ErrorIs(t, err, syslogsink.ErrUnknownFacility)
+
+	for _, tc := range []struct {
+		name string
+		want int
+	}{
+		{name: "err", want: 3},
+		{name: "warning", want: 4},
+		{name: "warn", want: 4},
+		{name: "7", want: 7},
+	} {
+		got, err := syslogsink.ParseSeverity(tc.name)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+	_, err = syslogsink.ParseSeverity("loud")
+	assert.ErrorIs(t, err, syslogsink.ErrUnknownSeverity)
+}
@@ -0,0 +1,66 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/format"
+)
+
+type writerSink struct {
+	w         io.Writer
+	formatter format.Formatter
+	buf       bytes.Buffer
+	// sync is called after each batch if not nil.
+	sync  func() error
+	close func() error
+}
+
+// NewWriter returns a sink that writes the formatted records into w.
+// A batch is written by a call of w.Write.
+func NewWriter(w io.Writer, f format.Formatter) Sink {
+	return &writerSink{
+		w:         w,
+		formatter: f,
+		close:     func() error { return nil },
+	}
+}
+
+// NewStdout returns a sink that writes the formatted records into the stdout.
+func NewStdout(f format.Formatter) Sink { return NewWriter(os.Stdout, f) }
+
+// NewFile returns a sink that appends the formatted records to the file.
+// The file is synced after each batch.
+func NewFile(filename string, f format.Formatter) (Sink, error) {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &writerSink{
+		w:         file,
+		formatter: f,
+		sync:      file.Sync,
+		close:     file.Close,
+	}, nil
+}
+
+func (s *writerSink) Write(_ context.Context, records []*gotailf.Record) error {
+	s.buf.Reset()
+	for _, r := range records {
+		if err := s.formatter.Format(&s.buf, r); err != nil {
+			return Permanent(err)
+		}
+	}
+	if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+		return err
+	}
+	if s.sync != nil {
+		return s.sync()
+	}
+	return nil
+}
+
+func (s *writerSink) Close() error { return s.close() }
@@ -0,0 +1,200 @@
+package spill
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+)
+
+// Segment file layout:
+//
+//	entry := length(4, big endian) crc32(4, big endian, IEEE, of payload) payload
+//	payload := JSON of entryRecord
+const entryHeaderSize = 8
+
+const segmentExt = ".seg"
+
+// segment is a file of the entries.
+type segment struct {
+	// first is the sequence number of the first entry.
+	first uint64
+	path  string
+	size  int64
+}
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", first, segmentExt))
+}
+
+// listSegments returns the segments in the directory in order.
+func listSegments(dir string) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []*segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, &segment{
+			first: first,
+			path:  filepath.Join(dir, name),
+			size:  info.Size(),
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].first < segments[j].first })
+	return segments, nil
+}
+
+// entryRecord is the persisted record.
+type entryRecord struct {
+	File      string       `json:"file"`
+	Offset    int64        `json:"offset"`
+	End       int64        `json:"end"`
+	Line      int64        `json:"line,omitempty"`
+	Text      string       `json:"text"`
+	Time      time.Time    `json:"time"`
+	Fields    parse.Fields `json:"fields,omitempty"`
+	ParseErr  string       `json:"parse_err,omitempty"`
+	Level     int          `json:"level,omitempty"`
+	EventTime time.Time    `json:"event_time"`
+	Late      bool         `json:"late,omitempty"`
+	Skipped   int64        `json:"skipped,omitempty"`
+}
+
+// appendEntry appends the frame of the record to buf.
+func appendEntry(buf []byte, r *gotailf.Record) ([]byte, error) {
+	x := &entryRecord{
+		File:      r.File,
+		Offset:    r.Offset,
+		End:       r.End,
+		Line:      r.Line,
+		Text:      r.Text,
+		Time:      r.Time,
+		Fields:    r.Fields,
+		Level:     int(r.Level),
+		EventTime: r.EventTime,
+		Late:      r.Late,
+		Skipped:   r.Skipped,
+	}
+	if r.ParseErr != nil {
+		x.ParseErr = r.ParseErr.Error()
+	}
+	payload, err := json.Marshal(x)
+	if err != nil {
+		return buf, err
+	}
+	var header [entryHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload))
+	buf = append(buf, header[:]...)
+	return append(buf, payload...), nil
+}
+
+var errCorrupted = errors.New("corrupted entry")
+
+// readEntry reads the next entry of limit bytes at most.
+// Returns io.EOF if no entries, errCorrupted if the entry is broken or incomplete.
+func readEntry(r *bufio.Reader, limit int64) (*gotailf.Record, int, error) {
+	var header [entryHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	if int64(size) > limit-entryHeaderSize {
+		// the broken length, not to allocate the huge buffer
+		return nil, 0, errCorrupted
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, 0, errCorrupted
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, errCorrupted
+	}
+	var x entryRecord
+	if err := json.Unmarshal(payload, &x); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errCorrupted, err)
+	}
+	rec := &gotailf.Record{
+		File:      x.File,
+		Offset:    x.Offset,
+		End:       x.End,
+		Line:      x.Line,
+		Text:      x.Text,
+		Time:      x.Time,
+		Fields:    x.Fields,
+		Level:     level.Level(x.Level),
+		EventTime: x.EventTime,
+		Late:      x.Late,
+		Skipped:   x.Skipped,
+	}
+	if x.ParseErr != "" {
+		rec.ParseErr = errors.New(x.ParseErr)
+	}
+	return rec, entryHeaderSize + len(payload), nil
+}
+
+// recoverSegment counts the entries of the segment
+// and truncates the broken tail left by a crash.
+func recoverSegment(s *segment) (uint64, error) {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var (
+		r     = bufio.NewReader(f)
+		count uint64
+		size  int64
+	)
+	for {
+		_, n, err := readEntry(r, s.size-size)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, errCorrupted) {
+			if err := f.Truncate(size); err != nil {
+				return 0, err
+			}
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+		size += int64(n)
+	}
+	s.size = size
+	return count, nil
+}
@@ -0,0 +1,608 @@
+// Package spill provides a disk-backed queue of records between Tailer and the consumer.
+//
+// Records are appended to segment files in a directory and delivered to the consumer in order.
+// The consumer acks the delivered records, and the segments are removed when all the records in them are acked.
+// The records not acked are delivered again when the queue is opened again.
+package spill
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/berquerant/gotailf"
+)
+
+// Config represents Queue configurations.
+type Config struct {
+	// SegmentBytes is the max size of a segment file.
+	// Default is 16MiB.
+	SegmentBytes int64
+	// MaxBytes is the max total size of the segment files.
+	// Default is 1GiB.
+	MaxBytes int64
+	// OverflowPolicy decides what to do when the queue is full.
+	// OverflowBlock waits for acks, OverflowDropNewest drops the new records
+	// and OverflowDropOldest drops the oldest segment even if the records in it are not acked.
+	// Default is OverflowBlock.
+	OverflowPolicy gotailf.OverflowPolicy
+	// Sync is true if the segment file is synced after each write.
+	// Default is false.
+	Sync bool
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		SegmentBytes: 16 * 1024 * 1024,
+		MaxBytes:     1024 * 1024 * 1024,
+	}
+}
+
+type Option func(*Config)
+
+// WithSegmentBytes sets Config.SegmentBytes.
+func WithSegmentBytes(size int64) Option {
+	return func(c *Config) {
+		c.SegmentBytes = size
+	}
+}
+
+// WithMaxBytes sets Config.MaxBytes.
+func WithMaxBytes(size int64) Option {
+	return func(c *Config) {
+		c.MaxBytes = size
+	}
+}
+
+// WithOverflowPolicy sets Config.OverflowPolicy.
+func WithOverflowPolicy(policy gotailf.OverflowPolicy) Option {
+	return func(c *Config) {
+		c.OverflowPolicy = policy
+	}
+}
+
+// WithSync sets Config.Sync.
+func WithSync(b bool) Option {
+	return func(c *Config) {
+		c.Sync = b
+	}
+}
+
+var (
+	// ErrClosed means that the queue is closed.
+	ErrClosed = errors.New("queue closed")
+	// ErrNotDelivered means that the acked entry is not delivered yet.
+	ErrNotDelivered = errors.New("not delivered")
+	// ErrUnsupportedOverflowPolicy means that the overflow policy is not available for the queue.
+	ErrUnsupportedOverflowPolicy = errors.New("unsupported overflow policy")
+)
+
+// Entry is a record in the queue.
+type Entry struct {
+	// Seq is the sequence number of the entry, increasing by 1 from 1.
+	Seq    uint64
+	Record *gotailf.Record
+}
+
+// Queue is a disk-backed queue of records.
+type Queue struct {
+	dir    string
+	config *Config
+
+	mu sync.Mutex
+	// changed is closed when the state is changed.
+	changed  chan struct{}
+	segments []*segment
+	// writer of the last segment.
+	w *os.File
+	// reader of the segment rseg.
+	r    *os.File
+	rbuf *bufio.Reader
+	rseg *segment
+	// next is the sequence number of the next put.
+	next uint64
+	// readSeq is the sequence number of the next get.
+	readSeq uint64
+	// acked is the last acked sequence number.
+	acked   uint64
+	size    int64
+	dropped int64
+	// tooLarge is the number of the records skipped because they do not fit in Config.MaxBytes.
+	tooLarge int64
+	// eof is true if no more records are put.
+	eof    bool
+	closed bool
+	err    error
+	buf    []byte
+}
+
+const ackFile = "ack"
+
+// Open opens the queue in the directory.
+// The directory is created if not exist.
+// The records not acked in the directory are delivered again.
+func Open(dir string, opts ...Option) (*Queue, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	switch config.OverflowPolicy {
+	case gotailf.OverflowBlock, gotailf.OverflowDropNewest, gotailf.OverflowDropOldest:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedOverflowPolicy, config.OverflowPolicy)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	acked, err := readAck(dir)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{
+		dir:      dir,
+		config:   config,
+		changed:  make(chan struct{}),
+		segments: segments,
+		acked:    acked,
+	}
+	if len(segments) == 0 {
+		q.next = acked + 1
+		if err := q.createSegment(); err != nil {
+			return nil, err
+		}
+	} else {
+		last := segments[len(segments)-1]
+		count, err := recoverSegment(last)
+		if err != nil {
+			return nil, err
+		}
+		q.next = last.first + count
+		if q.w, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0); err != nil {
+			return nil, err
+		}
+	}
+	// the ack may be inconsistent with the segments if they are removed manually
+	if first := q.segments[0].first; q.acked+1 < first {
+		q.acked = first - 1
+	}
+	if q.acked >= q.next {
+		q.acked = q.next - 1
+	}
+	q.readSeq = q.acked + 1
+	for _, s := range q.segments {
+		q.size += s.size
+	}
+	if err := q.removeAcked(); err != nil {
+		q.w.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func readAck(dir string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ackFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func (q *Queue) writeAck() error {
+	var (
+		name = filepath.Join(q.dir, ackFile)
+		tmp  = name + ".tmp"
+	)
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(q.acked, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (q *Queue) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// waitChange waits for the change of the state.
+// q.mu must be locked.
+func (q *Queue) waitChange(ctx context.Context) error {
+	c := q.changed
+	q.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		q.mu.Lock()
+		return ctx.Err()
+	case <-c:
+		q.mu.Lock()
+		if q.closed {
+			return ErrClosed
+		}
+		return nil
+	}
+}
+
+// createSegment starts a new segment from the next entry.
+func (q *Queue) createSegment() error {
+	s := &segment{
+		first: q.next,
+		path:  segmentPath(q.dir, q.next),
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if q.w != nil {
+		if err := q.w.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	q.w = f
+	q.segments = append(q.segments, s)
+	return nil
+}
+
+func (q *Queue) lastSegment() *segment { return q.segments[len(q.segments)-1] }
+
+// removeSegment removes the first segment.
+func (q *Queue) removeSegment() error {
+	s := q.segments[0]
+	if q.rseg == s {
+		q.closeReader()
+	}
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	q.segments = q.segments[1:]
+	q.size -= s.size
+	return nil
+}
+
+// removeAcked removes the segments whose entries are all acked.
+func (q *Queue) removeAcked() error {
+	for len(q.segments) > 1 && q.segments[1].first <= q.acked+1 {
+		if err := q.removeSegment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropOldest drops the oldest segment even if the entries in it are not acked.
+func (q *Queue) dropOldest() error {
+	if len(q.segments) == 1 {
+		if err := q.createSegment(); err != nil {
+			return err
+		}
+	}
+	var (
+		end  = q.segments[1].first
+		from = q.segments[0].first
+	)
+	if from <= q.acked {
+		from = q.acked + 1
+	}
+	if end > from {
+		q.dropped += int64(end - from)
+	}
+	if err := q.removeSegment(); err != nil {
+		return err
+	}
+	q.acked = end - 1
+	if q.readSeq < end {
+		q.readSeq = end
+	}
+	return q.writeAck()
+}
+
+// reserve makes room for n bytes.
+// Returns false if the entry should be dropped.
+func (q *Queue) reserve(ctx context.Context, n int64) (bool, error) {
+	for q.size+n > q.config.MaxBytes {
+		if q.acked+1 == q.next && q.lastSegment().size > 0 {
+			// all acked, start a new segment to remove the acked ones
+			if err := q.createSegment(); err != nil {
+				return false, err
+			}
+			if err := q.removeAcked(); err != nil {
+				return false, err
+			}
+			continue
+		}
+		switch q.config.OverflowPolicy {
+		case gotailf.OverflowDropNewest:
+			q.dropped++
+			return false, nil
+		case gotailf.OverflowDropOldest:
+			if err := q.dropOldest(); err != nil {
+				return false, err
+			}
+		default:
+			if err := q.waitChange(ctx); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func (q *Queue) write(frame []byte) error {
+	n := int64(len(frame))
+	if s := q.lastSegment(); s.size > 0 && s.size+n > q.config.SegmentBytes {
+		if err := q.createSegment(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.w.Write(frame); err != nil {
+		return err
+	}
+	if q.config.Sync {
+		if err := q.w.Sync(); err != nil {
+			return err
+		}
+	}
+	q.lastSegment().size += n
+	q.size += n
+	q.next++
+	return nil
+}
+
+// Put appends the records to the queue.
+// Blocks while the queue is full with OverflowBlock.
+// The records that do not fit in Config.MaxBytes are skipped and counted by TooLarge().
+func (q *Queue) Put(ctx context.Context, records ...*gotailf.Record) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.broadcast()
+	for _, r := range records {
+		if q.closed {
+			return ErrClosed
+		}
+		frame, err := appendEntry(q.buf[:0], r)
+		if err != nil {
+			return err
+		}
+		q.buf = frame
+		n := int64(len(frame))
+		if n > q.config.MaxBytes {
+			q.tooLarge++
+			continue
+		}
+		ok, err := q.reserve(ctx, n)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := q.write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queue) closeReader() {
+	if q.r != nil {
+		q.r.Close()
+	}
+	q.r = nil
+	q.rbuf = nil
+	q.rseg = nil
+}
+
+// readNext reads the entry of readSeq.
+func (q *Queue) readNext() (*gotailf.Record, error) {
+	var s *segment
+	for _, x := range q.segments {
+		if x.first <= q.readSeq {
+			s = x
+		}
+	}
+	if q.rseg != s {
+		q.closeReader()
+		f, err := os.Open(s.path)
+		if err != nil {
+			return nil, err
+		}
+		q.r = f
+		q.rbuf = bufio.NewReader(f)
+		q.rseg = s
+		for i := s.first; i < q.readSeq; i++ {
+			if _, _, err := readEntry(q.rbuf, s.size); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r, _, err := readEntry(q.rbuf, s.size)
+	return r, err
+}
+
+// Get returns the next entry.
+// Blocks until the entry is available.
+// Returns io.EOF if no more records are put by Spill().
+func (q *Queue) Get(ctx context.Context) (*Entry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.readSeq >= q.next {
+		if q.closed {
+			return nil, ErrClosed
+		}
+		if q.eof {
+			return nil, io.EOF
+		}
+		if err := q.waitChange(ctx); err != nil {
+			return nil, err
+		}
+	}
+	r, err := q.readNext()
+	if err != nil {
+		return nil, err
+	}
+	e := &Entry{
+		Seq:    q.readSeq,
+		Record: r,
+	}
+	q.readSeq++
+	return e, nil
+}
+
+// Ack acks the entries up to seq.
+// The segments are removed when all the entries in them are acked.
+func (q *Queue) Ack(seq uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if seq <= q.acked {
+		return nil
+	}
+	if seq >= q.readSeq {
+		return fmt.Errorf("%w: %d", ErrNotDelivered, seq)
+	}
+	q.acked = seq
+	if err := q.writeAck(); err != nil {
+		return err
+	}
+	if err := q.removeAcked(); err != nil {
+		return err
+	}
+	q.broadcast()
+	return nil
+}
+
+// Len returns the number of the entries not acked.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int(q.next - q.acked - 1)
+}
+
+// Size returns the total size of the segment files.
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Dropped returns the number of the records dropped by Config.OverflowPolicy.
+func (q *Queue) Dropped() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// TooLarge returns the number of the records skipped because they do not fit in Config.MaxBytes.
+func (q *Queue) TooLarge() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tooLarge
+}
+
+// Err returns the error of Spill().
+func (q *Queue) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+func (q *Queue) setErr(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+func (q *Queue) closeInput() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.eof = true
+	q.broadcast()
+}
+
+// Close closes the queue.
+// The entries not acked are kept in the directory.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.closeReader()
+	q.broadcast()
+	return q.w.Close()
+}
+
+// spillBatchSize is the max number of the records written at once by Spill().
+const spillBatchSize = 1000
+
+// Spill reads the records eagerly into the queue and yields the entries of the queue.
+// The consumer should ack the entries by Ack() after delivering them.
+// The result is closed when the records are closed and all the entries are yielded, or ctx ends.
+// Err() reports the error of the queue.
+func (q *Queue) Spill(ctx context.Context, recordC <-chan *gotailf.Record) <-chan *Entry {
+	resultC := make(chan *Entry)
+	go func() {
+		defer q.closeInput()
+		rs := make([]*gotailf.Record, 0, spillBatchSize)
+		for r := range recordC {
+			rs = append(rs[:0], r)
+		drain:
+			for len(rs) < spillBatchSize {
+				select {
+				case r, ok := <-recordC:
+					if !ok {
+						break drain
+					}
+					rs = append(rs, r)
+				default:
+					break drain
+				}
+			}
+			if err := q.Put(ctx, rs...); err != nil {
+				if ctx.Err() == nil {
+					q.setErr(err)
+				}
+				// not to block the tailer
+				for range recordC {
+				}
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(resultC)
+		for {
+			e, err := q.Get(ctx)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					q.setErr(err)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case resultC <- e:
+			}
+		}
+	}()
+	return resultC
+}
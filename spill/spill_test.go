@@ -0,0 +1,349 @@
+package spill_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/spill"
+	"github.com/berquerant/gotailf/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRecords(texts ...string) []*gotailf.Record {
+	rs := make([]*gotailf.Record, len(texts))
+	for i, x := range texts {
+		rs[i] = &gotailf.Record{
+			File:   "file",
+			Offset: int64(i),
+			Text:   x,
+		}
+	}
+	return rs
+}
+
+func getTexts(t *testing.T, q *spill.Queue, n int) ([]string, uint64) {
+	var (
+		texts = []string{}
+		last  uint64
+	)
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	for i := 0; i < n; i++ {
+		e, err := q.Get(ctx)
+		if !assert.Nil(t, err) {
+			break
+		}
+		texts = append(texts, e.Record.Text)
+		last = e.Seq
+	}
+	return texts, last
+}
+
+func totalAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.TotalAlloc
+}
+
+func segmentCount(t *testing.T, dir string) int {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.Nil(t, err)
+	return len(matches)
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("record", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		defer q.Close()
+		want := &gotailf.Record{
+			File:      "file",
+			Offset:    10,
+			End:       27,
+			Line:      3,
+			Text:      `{"level":"warn"}`,
+			Time:      time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC),
+			Fields:    parse.Fields{"level": "warn"},
+			ParseErr:  errors.New("malformed"),
+			Level:     level.Warn,
+			EventTime: time.Date(2021, 1, 2, 3, 4, 0, 0, time.UTC),
+			Late:      true,
+		}
+		assert.Nil(t, q.Put(context.TODO(), want))
+		e, err := q.Get(context.TODO())
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), e.Seq)
+		assert.Equal(t, want, e.Record)
+	})
+
+	t.Run("redelivered", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), spill.WithSegmentBytes(100))
+		assert.Nil(t, err)
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b", "c", "d")...))
+		assert.Equal(t, 4, segmentCount(t, dir.Dir()))
+		got, last := getTexts(t, q, 2)
+		assert.Equal(t, []string{"a", "b"}, got)
+		assert.ErrorIs(t, q.Ack(last+1), spill.ErrNotDelivered)
+		assert.Nil(t, q.Ack(last))
+		assert.Equal(t, 2, q.Len())
+		assert.Equal(t, 2, segmentCount(t, dir.Dir()))
+		_, _ = getTexts(t, q, 1) // not acked
+		assert.Nil(t, q.Close())
+
+		q, err = spill.Open(dir.Dir(), spill.WithSegmentBytes(100))
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Equal(t, 2, q.Len())
+		got, last = getTexts(t, q, 2)
+		assert.Equal(t, []string{"c", "d"}, got)
+		assert.Equal(t, uint64(4), last)
+		assert.Nil(t, q.Put(context.TODO(), newRecords("e")...))
+		got, _ = getTexts(t, q, 1)
+		assert.Equal(t, []string{"e"}, got)
+	})
+
+	t.Run("broken tail", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b")...))
+		size := q.Size()
+		assert.Nil(t, q.Close())
+		matches, _ := filepath.Glob(filepath.Join(dir.Dir(), "*.seg"))
+		f, err := os.OpenFile(matches[0], os.O_WRONLY|os.O_APPEND, 0)
+		assert.Nil(t, err)
+		_, _ = f.Write([]byte{0, 0, 0, 10, 1, 2})
+		assert.Nil(t, f.Close())
+
+		q, err = spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Equal(t, size, q.Size())
+		assert.Nil(t, q.Put(context.TODO(), newRecords("c")...))
+		got, _ := getTexts(t, q, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, got)
+	})
+
+	t.Run("corrupted length", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b")...))
+		size := q.Size()
+		assert.Nil(t, q.Close())
+		matches, _ := filepath.Glob(filepath.Join(dir.Dir(), "*.seg"))
+		f, err := os.OpenFile(matches[0], os.O_WRONLY, 0)
+		assert.Nil(t, err)
+		// the length of the second entry
+		_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, size/2)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+
+		alloc := totalAlloc()
+		q, err = spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Less(t, totalAlloc()-alloc, uint64(1<<30), "not allocated by the length")
+		assert.Equal(t, size/2, q.Size())
+		got, _ := getTexts(t, q, 1)
+		assert.Equal(t, []string{"a"}, got)
+	})
+
+	t.Run("corrupted length of the first segment", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), spill.WithSegmentBytes(1))
+		assert.Nil(t, err)
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b")...))
+		assert.Nil(t, q.Close())
+		matches, _ := filepath.Glob(filepath.Join(dir.Dir(), "*.seg"))
+		f, err := os.OpenFile(matches[0], os.O_WRONLY, 0)
+		assert.Nil(t, err)
+		_, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+
+		q, err = spill.Open(dir.Dir(), spill.WithSegmentBytes(1))
+		assert.Nil(t, err)
+		defer q.Close()
+		alloc := totalAlloc()
+		_, err = q.Get(context.TODO())
+		assert.NotNil(t, err)
+		assert.Less(t, totalAlloc()-alloc, uint64(1<<30), "not allocated by the length")
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), spill.WithMaxBytes(1000))
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", strings.Repeat("x", 1000), "c")...))
+		assert.Equal(t, int64(1), q.TooLarge())
+		assert.Equal(t, 2, q.Len())
+		got, _ := getTexts(t, q, 2)
+		assert.Equal(t, []string{"a", "c"}, got)
+	})
+
+	t.Run("unsupported policy", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		_, err := spill.Open(dir.Dir(), spill.WithOverflowPolicy(gotailf.OverflowSkipToEOF))
+		assert.ErrorIs(t, err, spill.ErrUnsupportedOverflowPolicy)
+	})
+}
+
+func TestQueueOverflow(t *testing.T) {
+	t.Parallel()
+	frameSize := func() int64 {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir())
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a")...))
+		return q.Size()
+	}()
+	// a segment has a frame, the queue has 3 frames
+	opts := func(policy gotailf.OverflowPolicy) []spill.Option {
+		return []spill.Option{
+			spill.WithSegmentBytes(frameSize),
+			spill.WithMaxBytes(frameSize*3 + frameSize/2),
+			spill.WithOverflowPolicy(policy),
+		}
+	}
+
+	t.Run("drop newest", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), opts(gotailf.OverflowDropNewest)...)
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b", "c", "d", "e")...))
+		assert.Equal(t, int64(2), q.Dropped())
+		got, last := getTexts(t, q, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, got)
+		assert.Nil(t, q.Ack(last))
+		assert.Nil(t, q.Put(context.TODO(), newRecords("f")...))
+		got, _ = getTexts(t, q, 1)
+		assert.Equal(t, []string{"f"}, got)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), opts(gotailf.OverflowDropOldest)...)
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b", "c", "d", "e")...))
+		assert.Equal(t, int64(2), q.Dropped())
+		assert.Equal(t, 3, q.Len())
+		got, _ := getTexts(t, q, 3)
+		assert.Equal(t, []string{"c", "d", "e"}, got)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		t.Parallel()
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		q, err := spill.Open(dir.Dir(), opts(gotailf.OverflowBlock)...)
+		assert.Nil(t, err)
+		defer q.Close()
+		assert.Nil(t, q.Put(context.TODO(), newRecords("a", "b", "c")...))
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, q.Put(ctx, newRecords("d")...), context.DeadlineExceeded)
+
+		_, last := getTexts(t, q, 1)
+		time.AfterFunc(50*time.Millisecond, func() {
+			assert.Nil(t, q.Ack(last))
+		})
+		assert.Nil(t, q.Put(context.TODO(), newRecords("d")...))
+		got, _ := getTexts(t, q, 3)
+		assert.Equal(t, []string{"b", "c", "d"}, got)
+	})
+}
+
+func TestQueueSpill(t *testing.T) {
+	t.Parallel()
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	q, err := spill.Open(dir.Dir(), spill.WithSegmentBytes(1000))
+	assert.Nil(t, err)
+	defer q.Close()
+
+	const n = 100
+	recordC := make(chan *gotailf.Record)
+	go func() {
+		// never blocked by the consumer
+		for i := 0; i < n; i++ {
+			recordC <- &gotailf.Record{Text: fmt.Sprint(i)}
+		}
+		close(recordC)
+	}()
+	entryC := q.Spill(context.TODO(), recordC)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, q.Len())
+	var i int
+	for e := range entryC {
+		assert.Equal(t, fmt.Sprint(i), e.Record.Text)
+		assert.Nil(t, q.Ack(e.Seq))
+		i++
+	}
+	assert.Equal(t, n, i)
+	assert.Nil(t, q.Err())
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, 1, segmentCount(t, dir.Dir()))
+}
+
+func TestQueueSpillTooLarge(t *testing.T) {
+	t.Parallel()
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	q, err := spill.Open(dir.Dir(), spill.WithMaxBytes(1000))
+	assert.Nil(t, err)
+	defer q.Close()
+
+	recordC := make(chan *gotailf.Record)
+	go func() {
+		for _, x := range []string{"a", strings.Repeat("x", 1000), "c", "d"} {
+			recordC <- &gotailf.Record{Text: x}
+		}
+		close(recordC)
+	}()
+	got := []string{}
+	for e := range q.Spill(context.TODO(), recordC) {
+		got = append(got, e.Record.Text)
+		assert.Nil(t, q.Ack(e.Seq))
+	}
+	assert.Equal(t, []string{"a", "c", "d"}, got)
+	assert.Equal(t, int64(1), q.TooLarge())
+	assert.Nil(t, q.Err())
+}
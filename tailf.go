@@ -1,13 +1,15 @@
 package gotailf
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"io"
-	"strings"
+	"iter"
+	"sync/atomic"
 	"time"
 
+	"github.com/berquerant/gotailf/index"
 	"github.com/berquerant/gotailf/internal"
 )
 
@@ -31,6 +33,61 @@ type Config struct {
 	// If true, continue tailing from the origin of the file when the file truncated.
 	// Default is true.
 	TailFromOriginWhenTruncated bool
+	// ReadBufferSize is the initial size of the buffer to read the target file.
+	// Default is 64KiB.
+	ReadBufferSize int
+	// BatchMaxCount is the max number of records in a batch of Batches().
+	// Default is 1000.
+	BatchMaxCount int
+	// BatchMaxBytes is the max total bytes of the lines in a batch of Batches().
+	// Default is 1MiB.
+	BatchMaxBytes int
+	// BatchMaxLatency is the max span of the read times of the records in a batch of Batches().
+	// A batch is also yielded when the read reaches the end of the target file,
+	// so records are not held while waiting for the new data.
+	// Default is 100 milliseconds.
+	BatchMaxLatency time.Duration
+	// OverflowPolicy decides what to do when the buffer of Tail(), Records() or Lines() is full.
+	// The policies other than OverflowBlock need the positive BufferSize.
+	// Default is OverflowBlock.
+	OverflowPolicy OverflowPolicy
+	// LineNumbers is true if Record.Line is set.
+	// The line numbers are found by the index of the target file, see package index.
+	// The first tailing may read the whole target file to build the index.
+	// Default is false.
+	LineNumbers bool
+	// Line is the line number to start tailing from, 1-based, used instead of Offset if not zero.
+	// Negative means the lines from the end, e.g. -10 starts at the last 10 lines.
+	// The offset of the line is found by the index of the target file as LineNumbers.
+	// Default is 0.
+	Line int64
+	// IndexOptions are the options of the index of the target file.
+	IndexOptions []index.Option
+	// Follow is false to stop tailing at the end of the target file instead of waiting for the new data.
+	// Then the last line is yielded even if it does not end with Delimiter.
+	// Default is true.
+	Follow bool
+	// FollowDescriptor is true to follow the opened target file even if it is moved or removed,
+	// instead of the file of the name.
+	// When the file is truncated, continues reading the same file from the origin,
+	// or the end unless TailFromOriginWhenTruncated.
+	// Default is false.
+	FollowDescriptor bool
+	// MaxUnchangedStats is the number of the checks of the target file without changes of the size,
+	// after which the file of the name is checked to be still the opened file.
+	// ErrFileGone if not, e.g. replaced by the file of the same size.
+	// 0 disables the checks.
+	// Default is 0.
+	MaxUnchangedStats int
+	// Delimiter is the byte that ends the lines.
+	// When LF, the trailing CR is also dropped from the lines.
+	// LineNumbers and Line count the lines by LF regardless.
+	// Default is LF.
+	Delimiter byte
+	// StopWhenGone is true to stop the continue tailer with ErrFileGone when the target file is gone,
+	// instead of waiting for the file of the same name.
+	// Default is false.
+	StopWhenGone bool
 }
 
 func newDefaultConfig() *Config {
@@ -39,6 +96,12 @@ func newDefaultConfig() *Config {
 		Offset:                      -1,
 		BufferSize:                  1000,
 		TailFromOriginWhenTruncated: true,
+		ReadBufferSize:              64 * 1024,
+		BatchMaxCount:               1000,
+		BatchMaxBytes:               1024 * 1024,
+		BatchMaxLatency:             100 * time.Millisecond,
+		Follow:                      true,
+		Delimiter:                   '\n',
 	}
 }
 
@@ -79,15 +142,128 @@ func WithTailFromOriginWhenTruncated(b bool) Option {
 	}
 }
 
+// WithReadBufferSize sets Config.ReadBufferSize.
+func WithReadBufferSize(size int) Option {
+	return func(c *Config) {
+		c.ReadBufferSize = size
+	}
+}
+
+// WithBatchMaxCount sets Config.BatchMaxCount.
+func WithBatchMaxCount(count int) Option {
+	return func(c *Config) {
+		c.BatchMaxCount = count
+	}
+}
+
+// WithBatchMaxBytes sets Config.BatchMaxBytes.
+func WithBatchMaxBytes(size int) Option {
+	return func(c *Config) {
+		c.BatchMaxBytes = size
+	}
+}
+
+// WithBatchMaxLatency sets Config.BatchMaxLatency.
+func WithBatchMaxLatency(latency time.Duration) Option {
+	return func(c *Config) {
+		c.BatchMaxLatency = latency
+	}
+}
+
+// WithOverflowPolicy sets Config.OverflowPolicy.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Config) {
+		c.OverflowPolicy = policy
+	}
+}
+
+// WithLineNumbers sets Config.LineNumbers.
+func WithLineNumbers(b bool) Option {
+	return func(c *Config) {
+		c.LineNumbers = b
+	}
+}
+
+// WithLine sets Config.Line.
+func WithLine(line int64) Option {
+	return func(c *Config) {
+		c.Line = line
+	}
+}
+
+// WithIndexOptions sets Config.IndexOptions.
+func WithIndexOptions(opts ...index.Option) Option {
+	return func(c *Config) {
+		c.IndexOptions = opts
+	}
+}
+
+// WithFollow sets Config.Follow.
+func WithFollow(b bool) Option {
+	return func(c *Config) {
+		c.Follow = b
+	}
+}
+
+// WithFollowDescriptor sets Config.FollowDescriptor.
+func WithFollowDescriptor(b bool) Option {
+	return func(c *Config) {
+		c.FollowDescriptor = b
+	}
+}
+
+// WithMaxUnchangedStats sets Config.MaxUnchangedStats.
+func WithMaxUnchangedStats(n int) Option {
+	return func(c *Config) {
+		c.MaxUnchangedStats = n
+	}
+}
+
+// WithDelimiter sets Config.Delimiter.
+func WithDelimiter(delim byte) Option {
+	return func(c *Config) {
+		c.Delimiter = delim
+	}
+}
+
+// WithStopWhenGone sets Config.StopWhenGone.
+func WithStopWhenGone(b bool) Option {
+	return func(c *Config) {
+		c.StopWhenGone = b
+	}
+}
+
 // Tailer provides an interface for tailing file.
 type Tailer interface {
 	// Tail starts tailing the file.
 	// Yields appended lines.
 	Tail(ctx context.Context) <-chan string
+	// Records starts tailing the file.
+	// Yields appended lines with their metadata.
+	// Either Tail() or Records() should be called.
+	Records(ctx context.Context) <-chan *Record
+	// Batches starts tailing the file.
+	// Yields appended lines with their metadata in batches,
+	// bounded by Config.BatchMaxCount, Config.BatchMaxBytes and Config.BatchMaxLatency.
+	// Either Tail(), Records() or Batches() should be called.
+	Batches(ctx context.Context) <-chan []*Record
+	// RawBatches starts tailing the file.
+	// Yields appended lines as bytes in batches without copying them into strings.
+	// The batches are bounded as Batches().
+	// Either Tail(), Records(), Batches() or RawBatches() should be called.
+	RawBatches(ctx context.Context) <-chan *RawBatch
+	// Lines starts tailing the file.
+	// Yields appended lines with their metadata, and the error of yielding at the end if any.
+	// Breaking the loop stops tailing, the file is closed before the loop returns.
+	// The end of ctx is not an error.
+	// Either Tail(), Records(), Batches(), RawBatches() or Lines() should be called.
+	Lines(ctx context.Context) iter.Seq2[*Record, error]
 	// Filename returns the name of the target file.
 	Filename() string
 	// Pos returns the read offset.
 	Pos() int64
+	// Dropped returns the number of the lines dropped by Config.OverflowPolicy.
+	Dropped() int64
 	// Err returns the error of yielding.
 	// This should be called when Tail() ends.
 	Err() error
@@ -96,6 +272,8 @@ type Tailer interface {
 type tailer struct {
 	// target filename.
 	filename string
+	// target file path.
+	path string
 	// target file.
 	file internal.File
 	// file status watcher.
@@ -103,48 +281,98 @@ type tailer struct {
 	config  *Config
 	// tailing offset.
 	pos int64
-	err error
+	// index of the target file, nil unless Config.LineNumbers or Config.Line.
+	indexer *index.Indexer
+	// the line number of the next line if Config.LineNumbers.
+	line int64
+	// the last time when the index is saved.
+	indexSaved time.Time
+	// the number of the dropped lines.
+	dropped atomic.Int64
+	err     error
 }
 
 // NewTailer returns a new Tailer.
 // The target file must be exist.
-// When the target file is moved, removed or truncated then canceled.
+// When the target file is moved, removed or truncated then canceled, see also Config.FollowDescriptor.
 func NewTailer(filename string, opts ...Option) (Tailer, error) {
 	config := newDefaultConfig()
 	for _, opt := range opts {
 		opt(config)
 	}
-	return newTailer(filename, config, internal.NewWatcher(filename, config.FlushInterval))
-}
-
-func newTailer(filename string, config *Config, watcher internal.Watcher) (Tailer, error) {
 	f, err := internal.OpenFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	return newTailerFromFile(f, config, watcher)
+	t, err := newTailerFromFile(filename, f, config, newWatcher(filename, f, config))
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
-func newTailerFromFile(f internal.File, config *Config, watcher internal.Watcher) (Tailer, error) {
+// newWatcher returns the watcher of the file opened by the filename.
+func newWatcher(filename string, f internal.File, config *Config) internal.Watcher {
+	return internal.NewFileWatcher(filename, f, config.FlushInterval, config.FollowDescriptor, config.MaxUnchangedStats)
+}
+
+func newTailerFromFile(path string, f internal.File, config *Config, watcher internal.Watcher) (*tailer, error) {
 	stat, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
-	pos := config.Offset
-	if pos < 0 || pos > stat.Size() {
-		pos = stat.Size() // tailing from EOF
+	var (
+		indexer *index.Indexer
+		offset  = config.Offset
+	)
+	if config.LineNumbers || config.Line != 0 {
+		if indexer, err = index.Open(path, config.IndexOptions...); err != nil {
+			return nil, err
+		}
+		switch {
+		case config.Line > 0:
+			offset, err = indexer.OffsetOf(config.Line)
+		case config.Line < 0:
+			offset, err = indexer.Tail(-config.Line)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	offset, err := f.Seek(pos, 0)
-	if err != nil {
+	if offset, err = seekOffset(f, offset); err != nil {
 		return nil, err
 	}
-	return &tailer{
+	s := &tailer{
 		filename: stat.Name(),
+		path:     path,
 		watcher:  watcher,
 		file:     f,
 		config:   config,
 		pos:      offset,
-	}, nil
+	}
+	if config.LineNumbers {
+		line, err := indexer.LineAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		s.indexer = indexer
+		s.line = line
+		s.indexSaved = time.Now()
+	}
+	return s, nil
+}
+
+// seekOffset seeks the file to the offset.
+// If the offset is negative or over the size of the file, the end of the file.
+func seekOffset(f internal.File, offset int64) (int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 || offset > stat.Size() {
+		offset = stat.Size() // tailing from EOF
+	}
+	return f.Seek(offset, io.SeekStart)
 }
 
 func (s *tailer) Filename() string {
@@ -159,6 +387,12 @@ func (s *tailer) Pos() int64 {
 	}
 	return s.pos
 }
+func (s *tailer) Dropped() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
 func (s *tailer) Err() error {
 	if s == nil {
 		return nil
@@ -169,9 +403,65 @@ func (s *tailer) addPos(pos int)   { s.pos += int64(pos) }
 func (s *tailer) setErr(err error) { s.err = err }
 
 func (s *tailer) Tail(ctx context.Context) <-chan string {
-	resultC := make(chan string, s.config.BufferSize)
+	var (
+		resultC = make(chan string, s.config.BufferSize)
+		o       = newLineOverflow(s.config.OverflowPolicy, resultC, &s.dropped)
+	)
+	go func() {
+		s.sendLines(ctx, o)
+		close(resultC)
+	}()
+	return resultC
+}
+
+// newLineOverflow returns the overflow of the lines of Tail().
+func newLineOverflow(policy OverflowPolicy, c chan string, dropped *atomic.Int64) *overflow[string] {
+	return newOverflow(policy, c, dropped, func(_ string, n int64) string {
+		return skippedText(n)
+	})
+}
+
+// sendLines tails the file and sends the lines by o, then closes the file.
+func (s *tailer) sendLines(ctx context.Context, o *overflow[string]) {
+	s.loop(ctx, &emitter{
+		emit:  func(r *Record) { o.send(r.Text) },
+		flush: o.flush,
+	})
+	s.file.Close()
+}
+
+func (s *tailer) Records(ctx context.Context) <-chan *Record {
+	var (
+		resultC = make(chan *Record, s.config.BufferSize)
+		o       = newOverflow(s.config.OverflowPolicy, resultC, &s.dropped, skippedRecord)
+	)
+	go func() {
+		s.sendRecords(ctx, o)
+		close(resultC)
+	}()
+	return resultC
+}
+
+// sendRecords tails the file and sends the records by o, then closes the file.
+func (s *tailer) sendRecords(ctx context.Context, o *overflow[*Record]) {
+	s.loop(ctx, &emitter{
+		emit:  o.send,
+		flush: o.flush,
+	})
+	s.file.Close()
+}
+
+func (s *tailer) Batches(ctx context.Context) <-chan []*Record {
+	var (
+		resultC = make(chan []*Record, batchBufferSize(s.config))
+		b       = newBatcher(s.config, resultC)
+	)
 	go func() {
-		s.loop(ctx, resultC)
+		s.loop(ctx, &emitter{
+			alloc: b.alloc,
+			emit:  b.add,
+			flush: b.flush,
+		})
 		s.file.Close()
 		close(resultC)
 	}()
@@ -185,43 +475,165 @@ var (
 	ErrFileTruncated = errors.New("file truncated")
 )
 
-func (s *tailer) loop(ctx context.Context, resultC chan<- string) {
+func (s *tailer) RawBatches(ctx context.Context) <-chan *RawBatch {
 	var (
-		r    = bufio.NewReader(s.file)
-		buf  strings.Builder
+		resultC = make(chan *RawBatch, batchBufferSize(s.config))
+		b       = newRawBatcher(s.path, s.config, resultC)
+	)
+	go func() {
+		s.loop(ctx, &emitter{
+			raw:   b.add,
+			flush: b.flush,
+		})
+		s.file.Close()
+		close(resultC)
+	}()
+	return resultC
+}
+
+// emitter receives the records read by the loop.
+type emitter struct {
+	// alloc returns a new zero record.
+	// Default is new(Record).
+	alloc func() *Record
+	emit  func(r *Record)
+	// raw receives the line without the trailing newline instead of emit.
+	// The line is valid until the next call.
+	raw func(line []byte, offset int64, now time.Time)
+	// flush is called when the read reaches the end of the file.
+	flush func()
+}
+
+func (s *tailer) newRecord(e *emitter, line []byte, now time.Time) *Record {
+	var r *Record
+	if e.alloc != nil {
+		r = e.alloc()
+	} else {
+		r = new(Record)
+	}
+	r.File = s.path
+	r.Offset = s.pos
+	r.End = s.pos + int64(len(line))
+	r.Text = string(s.trimLine(line))
+	r.Time = now
+	return r
+}
+
+// trimLine drops the trailing delimiter of the line.
+func (s *tailer) trimLine(line []byte) []byte {
+	if s.config.Delimiter == '\n' {
+		return internal.DropCRLFBytes(line)
+	}
+	return bytes.TrimSuffix(line, []byte{s.config.Delimiter})
+}
+
+// rewind restarts reading the truncated file from the origin, or the end unless Config.TailFromOriginWhenTruncated.
+func (s *tailer) rewind() error {
+	var offset int64 = -1
+	if s.config.TailFromOriginWhenTruncated {
+		offset = 0
+	}
+	pos, err := seekOffset(s.file, offset)
+	if err != nil {
+		return err
+	}
+	s.pos = pos
+	if s.indexer == nil {
+		return nil
+	}
+	// the index is reset by the truncation
+	if s.indexer, err = index.Open(s.path, s.config.IndexOptions...); err != nil {
+		return err
+	}
+	s.line, err = s.indexer.LineAt(pos)
+	return err
+}
+
+// indexSaveInterval is the min interval of the saves of the index while tailing.
+const indexSaveInterval = 10 * time.Second
+
+// observeLine indexes the line at the read offset and returns the line number.
+// The records not delimited by LF are not indexed, the line number is the LF line of the head of the record.
+// Returns 0 unless Config.LineNumbers.
+func (s *tailer) observeLine(line []byte) int64 {
+	if s.indexer == nil {
+		return 0
+	}
+	n := s.line
+	if s.config.Delimiter != '\n' {
+		s.line += int64(bytes.Count(line, []byte{'\n'}))
+		return n
+	}
+	s.indexer.Observe(s.pos, len(line))
+	s.line++
+	return n
+}
+
+// saveIndex saves the index at most once per indexSaveInterval.
+func (s *tailer) saveIndex(now time.Time) {
+	if s.indexer == nil || now.Sub(s.indexSaved) < indexSaveInterval {
+		return
+	}
+	// the index is a cache, rebuilt if not saved
+	_ = s.indexer.Save()
+	s.indexSaved = now
+}
+
+// loop reads the target file and emits the records.
+func (s *tailer) loop(ctx context.Context, e *emitter) {
+	var (
+		r    = internal.NewDelimReader(s.file, s.config.ReadBufferSize, s.config.Delimiter)
 		read = func() error {
+			if e.flush != nil {
+				defer e.flush()
+			}
+			defer func() {
+				s.saveIndex(time.Now())
+			}()
 			for {
 				select {
 				case <-ctx.Done():
 					return ctx.Err()
 				default:
-					x, err := r.ReadString('\n')
-					if err != nil && !errors.Is(err, io.EOF) {
-						return err
+					line, err := r.ReadLine()
+					// EOF, not end in the delimiter, yield next time.
+					if errors.Is(err, io.EOF) {
+						if s.config.Follow || r.Buffered() == 0 {
+							return nil
+						}
+						// no next time
+						line, err = r.Rest(), nil
 					}
-					// EOF, not end in LF, yield next time.
 					if err != nil {
-						buf.WriteString(x)
-						return nil
+						return err
 					}
-					if buf.Len() > 0 {
-						r := buf.String() + x
-						s.addPos(len(r))
-						resultC <- internal.DropCRLF(r)
-						buf.Reset()
+					// the read time of each line, also bounds the latency of the batches while reading
+					now := time.Now()
+					if e.raw != nil {
+						e.raw(s.trimLine(line), s.pos, now)
+						s.observeLine(line)
+						s.addPos(len(line))
 						continue
 					}
-					s.addPos(len(x))
-					resultC <- internal.DropCRLF(x)
+					rec := s.newRecord(e, line, now)
+					rec.Line = s.observeLine(line)
+					s.addPos(len(line))
+					e.emit(rec)
 				}
 			}
 		}
 	)
 
+	if s.indexer != nil {
+		defer s.indexer.Save()
+	}
 	if err := read(); err != nil {
 		s.setErr(err)
 		return
 	}
+	if !s.config.Follow {
+		return
+	}
 	// Yield when the target file status is changed.
 	eventC, err := s.watcher.Watch(ctx)
 	if err != nil {
@@ -234,8 +646,19 @@ func (s *tailer) loop(ctx context.Context, resultC chan<- string) {
 			s.setErr(ErrFileGone)
 			return
 		case internal.FileChangeEventTruncated:
-			s.setErr(ErrFileTruncated)
-			return
+			if !s.config.FollowDescriptor {
+				s.setErr(ErrFileTruncated)
+				return
+			}
+			if err := s.rewind(); err != nil {
+				s.setErr(err)
+				return
+			}
+			r = internal.NewDelimReader(s.file, s.config.ReadBufferSize, s.config.Delimiter)
+			if err := read(); err != nil {
+				s.setErr(err)
+				return
+			}
 		case internal.FileChangeEventAppended:
 			if err := read(); err != nil {
 				s.setErr(err)
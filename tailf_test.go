@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/index"
+	"github.com/berquerant/gotailf/parse"
 	"github.com/berquerant/gotailf/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -264,3 +266,227 @@ func TestTailer(t *testing.T) {
 		}
 	})
 }
+
+func TestTailerNoFollow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("partial last line", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "first\nsecond\nlast")
+		f.Close(t)
+		defer f.Remove(t)
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithOffset(6),
+			gotailf.WithFollow(false),
+		)
+		assert.Nil(t, err)
+		got := []string{}
+		for line := range s.Tail(context.TODO()) {
+			got = append(got, line)
+		}
+		assert.Nil(t, s.Err())
+		assert.Equal(t, []string{"second", "last"}, got)
+		assert.Equal(t, int64(17), s.Pos())
+	})
+
+	t.Run("delimiter", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "a\nb\x00c\r\x00")
+		f.Close(t)
+		defer f.Remove(t)
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithOffset(0),
+			gotailf.WithFollow(false),
+			gotailf.WithDelimiter(0),
+		)
+		assert.Nil(t, err)
+		got := []string{}
+		for line := range s.Tail(context.TODO()) {
+			got = append(got, line)
+		}
+		assert.Nil(t, s.Err())
+		assert.Equal(t, []string{"a\nb", "c\r"}, got)
+	})
+}
+
+func TestTailerFollowDescriptor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("moved", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "moved\n")
+		dest := fmt.Sprintf("%s-moved", f.Name())
+		defer func() {
+			f.Close(t)
+			os.Remove(dest)
+		}()
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithOffset(0),
+			gotailf.WithFollowDescriptor(true),
+		)
+		assert.Nil(t, err)
+		time.AfterFunc(80*time.Millisecond, func() {
+			assert.Nil(t, os.Rename(f.Name(), dest))
+		})
+		time.AfterFunc(170*time.Millisecond, func() {
+			fmt.Fprint(f.File(), "after\n")
+		})
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		got := []string{}
+		for line := range s.Tail(ctx) {
+			got = append(got, line)
+		}
+		assert.Nil(t, s.Err())
+		assert.Equal(t, []string{"moved", "after"}, got)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		f := test.NewTmpFile(t)
+		fmt.Fprint(f.File(), "before truncation\n")
+		f.Close(t)
+		defer f.Remove(t)
+		s, err := gotailf.NewTailer(f.Name(),
+			gotailf.WithFlushInterval(50*time.Millisecond),
+			gotailf.WithFollowDescriptor(true),
+			gotailf.WithLineNumbers(true),
+			gotailf.WithIndexOptions(index.WithDir(t.TempDir())),
+		)
+		assert.Nil(t, err)
+		time.AfterFunc(80*time.Millisecond, func() {
+			assert.Nil(t, os.WriteFile(f.Name(), []byte("1\n2\n"), 0o600))
+		})
+		ctx, cancel := context.WithTimeout(context.TODO(), 300*time.Millisecond)
+		defer cancel()
+		got := []*gotailf.Record{}
+		for r := range s.Records(ctx) {
+			got = append(got, r)
+		}
+		assert.Nil(t, s.Err())
+		if assert.Equal(t, 2, len(got)) {
+			assert.Equal(t, "1", got[0].Text)
+			assert.Equal(t, int64(0), got[0].Offset)
+			assert.Equal(t, int64(1), got[0].Line)
+			assert.Equal(t, "2", got[1].Text)
+			assert.Equal(t, int64(2), got[1].Line)
+		}
+	})
+}
+
+func TestTailerRecords(t *testing.T) {
+	t.Parallel()
+	f := test.NewTmpFile(t)
+	fmt.Fprint(f.File(), "first\nlevel=info status=200\n")
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithFlushInterval(50*time.Millisecond),
+		gotailf.WithOffset(0),
+	)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	got := []*gotailf.Record{}
+	for r := range s.Records(ctx) {
+		got = append(got, r)
+	}
+	assert.Nil(t, s.Err())
+	if !assert.Equal(t, 2, len(got)) {
+		return
+	}
+	assert.Equal(t, f.Name(), got[0].File)
+	assert.Equal(t, int64(0), got[0].Offset)
+	assert.Equal(t, "first", got[0].Text)
+	assert.Equal(t, int64(6), got[0].End)
+	assert.Equal(t, int64(6), got[1].Offset)
+
+	got[0].Parse(parse.FormatAuto)
+	assert.NotNil(t, got[0].ParseErr)
+	assert.Equal(t, "first", got[0].Text)
+	got[1].Parse(parse.FormatAuto)
+	assert.Nil(t, got[1].ParseErr)
+	assert.Equal(t, parse.Fields{"level": "info", "status": float64(200)}, got[1].Fields)
+}
+
+func TestTailerLineNumbers(t *testing.T) {
+	t.Parallel()
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	f := test.NewTmpFile(t)
+	fmt.Fprint(f.File(), "l1\nl2\nl3\nl4\nl5\n")
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithFlushInterval(50*time.Millisecond),
+		gotailf.WithLine(-2),
+		gotailf.WithLineNumbers(true),
+		gotailf.WithIndexOptions(index.WithInterval(2), index.WithDir(dir.Dir())),
+	)
+	assert.Nil(t, err)
+	time.AfterFunc(80*time.Millisecond, func() {
+		fmt.Fprint(f.File(), "l6\n")
+	})
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	type line struct {
+		text string
+		line int64
+	}
+	got := []line{}
+	for r := range s.Records(ctx) {
+		got = append(got, line{r.Text, r.Line})
+	}
+	assert.Nil(t, s.Err())
+	assert.Equal(t, []line{{"l4", 4}, {"l5", 5}, {"l6", 6}}, got)
+
+	x, err := index.Open(f.Name(), index.WithInterval(2), index.WithDir(dir.Dir()))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), x.Lines(), "saved")
+}
+
+func TestTailerLineNumbersDelimiter(t *testing.T) {
+	t.Parallel()
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	f := test.NewTmpFile(t)
+	fmt.Fprint(f.File(), "l1\nl2\x00l3\x00l4\nl5\n\x00")
+	defer func() {
+		f.Close(t)
+		f.Remove(t)
+	}()
+	s, err := gotailf.NewTailer(f.Name(),
+		gotailf.WithOffset(0),
+		gotailf.WithFollow(false),
+		gotailf.WithDelimiter(0),
+		gotailf.WithLineNumbers(true),
+		gotailf.WithIndexOptions(index.WithInterval(2), index.WithDir(dir.Dir())),
+	)
+	assert.Nil(t, err)
+	type line struct {
+		text string
+		line int64
+	}
+	got := []line{}
+	for r := range s.Records(context.TODO()) {
+		got = append(got, line{r.Text, r.Line})
+	}
+	assert.Nil(t, s.Err())
+	// the LF lines of the heads of the records
+	assert.Equal(t, []line{{"l1\nl2", 1}, {"l3", 2}, {"l4\nl5\n", 2}}, got)
+
+	// the index counts the lines by LF
+	x, err := index.Open(f.Name(), index.WithInterval(2), index.WithDir(dir.Dir()))
+	assert.Nil(t, err)
+	offset, err := x.OffsetOf(3)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), offset)
+}
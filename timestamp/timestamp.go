@@ -0,0 +1,377 @@
+// Package timestamp provides the extraction of event times from lines.
+package timestamp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf/parse"
+)
+
+// Config represents Extractor configurations.
+type Config struct {
+	// Layouts are the additional Go time layouts, tried before the builtin layouts.
+	Layouts []string
+	// Location is the time zone of the times without zones.
+	// Default is time.Local.
+	Location *time.Location
+	// Keys are the field names of the time, tried in order.
+	// Default is time, ts, timestamp, @timestamp, @t, datetime and date.
+	Keys []string
+	// Now returns the current time, used to guess the years of the times without years.
+	// Default is time.Now.
+	Now func() time.Time
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Location: time.Local,
+		Keys:     []string{"time", "ts", "timestamp", "@timestamp", "@t", "datetime", "date"},
+		Now:      time.Now,
+	}
+}
+
+type Option func(*Config)
+
+// WithLayouts sets Config.Layouts.
+func WithLayouts(layouts ...string) Option {
+	return func(c *Config) {
+		c.Layouts = layouts
+	}
+}
+
+// WithLocation sets Config.Location.
+func WithLocation(loc *time.Location) Option {
+	return func(c *Config) {
+		c.Location = loc
+	}
+}
+
+// WithKeys sets Config.Keys.
+func WithKeys(keys ...string) Option {
+	return func(c *Config) {
+		c.Keys = keys
+	}
+}
+
+// WithNow sets Config.Now.
+func WithNow(now func() time.Time) Option {
+	return func(c *Config) {
+		c.Now = now
+	}
+}
+
+// builtinLayouts are the layouts detected automatically.
+// More specific layouts come first.
+var builtinLayouts = []string{
+	// RFC3339 and ISO8601
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	// Apache/nginx common log format
+	"02/Jan/2006:15:04:05 -0700",
+	// Go log default
+	"2006/01/02 15:04:05.999999999",
+	// RFC3164 syslog
+	"Jan _2 15:04:05",
+	// RFC1123
+	time.RFC1123Z,
+	time.RFC1123,
+	// ruby, unix date
+	time.RubyDate,
+	time.UnixDate,
+}
+
+type layout struct {
+	layout string
+	re     *regexp.Regexp
+}
+
+// Extractor extracts event times from lines.
+type Extractor struct {
+	config  *Config
+	layouts []*layout
+}
+
+// ErrInvalidLayout means that the custom layout is not a Go time layout.
+var ErrInvalidLayout = errors.New("invalid layout")
+
+// New returns a new Extractor.
+// Returns ErrInvalidLayout if the custom layouts are invalid.
+func New(opts ...Option) (*Extractor, error) {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	for _, x := range config.Layouts {
+		if !validLayout(x) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLayout, x)
+		}
+	}
+	e := &Extractor{
+		config: config,
+	}
+	for _, x := range append(append([]string{}, config.Layouts...), builtinLayouts...) {
+		e.layouts = append(e.layouts, &layout{
+			layout: x,
+			re:     regexp.MustCompile(LayoutRegexp(x)),
+		})
+	}
+	return e, nil
+}
+
+// layoutReference is the time to validate the layouts.
+var layoutReference = time.Date(2021, 11, 23, 13, 45, 56, 0, time.UTC)
+
+// validLayout returns true if the layout has any elements of time
+// and the time formatted by the layout is parsed by the layout.
+func validLayout(layout string) bool {
+	v := layoutReference.Format(layout)
+	if v == layout {
+		return false
+	}
+	_, err := time.Parse(layout, v)
+	return err == nil
+}
+
+// Match is an extracted time.
+type Match struct {
+	Time time.Time
+	// Start and End are the range of the time in the text.
+	// Both are -1 when the time is extracted from the fields.
+	Start int
+	End   int
+}
+
+// Extract returns the event time of the line.
+// The fields are preferred if any.
+func (e *Extractor) Extract(text string, fields parse.Fields) (time.Time, bool) {
+	if t, ok := e.FromFields(fields); ok {
+		return t, true
+	}
+	if m, ok := e.Find(text); ok {
+		return m.Time, true
+	}
+	return time.Time{}, false
+}
+
+// FromFields returns the time from the fields.
+func (e *Extractor) FromFields(fields parse.Fields) (time.Time, bool) {
+	for _, k := range e.config.Keys {
+		v, ok := fields.Get(k)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			if t, ok := e.parseEpoch(v); ok {
+				return t, true
+			}
+			for _, x := range e.layouts {
+				if loc := x.re.FindStringIndex(v); loc != nil && loc[0] == 0 && loc[1] == len(v) {
+					if t, ok := e.parse(x.layout, v); ok {
+						return t, true
+					}
+				}
+			}
+		case float64:
+			return FromEpoch(v), true
+		}
+	}
+	return time.Time{}, false
+}
+
+var epochRegexp = regexp.MustCompile(`^(\d{10}|\d{13}|\d{16}|\d{19})(\.\d+)?\b`)
+
+// Find searches the text for a time.
+// Epoch times are detected only at the head of the text.
+func (e *Extractor) Find(text string) (Match, bool) {
+	if loc := epochRegexp.FindStringIndex(text); loc != nil {
+		if t, ok := e.parseEpoch(text[loc[0]:loc[1]]); ok {
+			return Match{
+				Time:  t,
+				Start: loc[0],
+				End:   loc[1],
+			}, true
+		}
+	}
+	for _, x := range e.layouts {
+		for _, loc := range x.re.FindAllStringIndex(text, -1) {
+			if t, ok := e.parse(x.layout, text[loc[0]:loc[1]]); ok {
+				return Match{
+					Time:  t,
+					Start: loc[0],
+					End:   loc[1],
+				}, true
+			}
+		}
+	}
+	return Match{}, false
+}
+
+// Rewrite replaces the first time in the text with the time formatted by the layout in the location.
+// Returns the text as it is if no times are found.
+func (e *Extractor) Rewrite(text, layout string, loc *time.Location) string {
+	m, ok := e.Find(text)
+	if !ok {
+		return text
+	}
+	return text[:m.Start] + m.Time.In(loc).Format(layout) + text[m.End:]
+}
+
+func (e *Extractor) parse(layout, value string) (time.Time, bool) {
+	t, err := time.ParseInLocation(layout, strings.Replace(value, ",", ".", 1), e.config.Location)
+	if err != nil {
+		// layout with comma
+		if t, err = time.ParseInLocation(layout, value, e.config.Location); err != nil {
+			return time.Time{}, false
+		}
+	}
+	if t.Year() == 0 {
+		// no years, e.g. RFC3164, assume the latest past
+		now := e.config.Now().In(t.Location())
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+	}
+	return t, true
+}
+
+func (e *Extractor) parseEpoch(v string) (time.Time, bool) {
+	if !epochRegexp.MatchString(v) {
+		return time.Time{}, false
+	}
+	var (
+		intPart  = v
+		fracPart string
+	)
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		intPart, fracPart = v[:i], v[i+1:]
+	}
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// nanoseconds per unit
+	var unit int64
+	switch len(intPart) {
+	case 10:
+		unit = 1e9
+	case 13:
+		unit = 1e6
+	case 16:
+		unit = 1e3
+	default:
+		unit = 1
+	}
+	var frac int64
+	if fracPart != "" && unit > 1 {
+		f, err := strconv.ParseFloat("0."+fracPart, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		frac = int64(f * float64(unit))
+	}
+	return time.Unix(0, n*unit+frac), true
+}
+
+// FromEpoch returns the time of the epoch.
+// The unit is guessed by the magnitude: seconds, milliseconds, microseconds or nanoseconds.
+func FromEpoch(v float64) time.Time {
+	switch {
+	case v >= 1e17:
+		return time.Unix(0, int64(v))
+	case v >= 1e14:
+		return time.Unix(0, int64(v*1e3))
+	case v >= 1e11:
+		return time.Unix(0, int64(v*1e6))
+	default:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9))
+	}
+}
+
+// layoutElements are the elements of Go time layouts and their patterns.
+// Longer elements come first.
+var layoutElements = []struct {
+	elem    string
+	pattern string
+}{
+	{"January", `[A-Z][a-z]{2,8}`},
+	{"Monday", `[A-Z][a-z]{5,8}`},
+	{"Z07:00", `(?:Z|[+-]\d{2}:\d{2})`},
+	{"-07:00", `[+-]\d{2}:\d{2}`},
+	{"Z0700", `(?:Z|[+-]\d{4})`},
+	{"-0700", `[+-]\d{4}`},
+	{"2006", `\d{4}`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]{3,5}`},
+	{"-07", `[+-]\d{2}`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"15", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// LayoutRegexp returns the regular expression that matches the times formatted by the Go time layout.
+func LayoutRegexp(layout string) string {
+	var b strings.Builder
+	if layout != "" && isWordChar(layout[0]) {
+		b.WriteString(`\b`)
+	}
+	for i := 0; i < len(layout); {
+		// fractional seconds
+		if c := layout[i]; (c == '.' || c == ',') && i+1 < len(layout) && (layout[i+1] == '0' || layout[i+1] == '9') {
+			j := i + 1
+			for j < len(layout) && layout[j] == layout[i+1] {
+				j++
+			}
+			if layout[i+1] == '9' {
+				b.WriteString(`(?:[.,]\d+)?`)
+			} else {
+				b.WriteString(`[.,]\d{` + strconv.Itoa(j-i-1) + `}`)
+			}
+			i = j
+			continue
+		}
+		matched := false
+		for _, x := range layoutElements {
+			if strings.HasPrefix(layout[i:], x.elem) {
+				b.WriteString(x.pattern)
+				i += len(x.elem)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordChar(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
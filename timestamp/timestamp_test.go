@@ -0,0 +1,157 @@
+package timestamp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf/parse"
+	"github.com/berquerant/gotailf/timestamp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractor(t *testing.T) {
+	var (
+		jst    = time.FixedZone("JST", 9*60*60)
+		now    = time.Date(2021, 10, 16, 0, 0, 0, 0, time.UTC)
+		e, err = timestamp.New(
+			timestamp.WithLocation(jst),
+			timestamp.WithNow(func() time.Time { return now }),
+			timestamp.WithLayouts("20060102-150405"),
+		)
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, tc := range []*struct {
+		title  string
+		text   string
+		fields parse.Fields
+		want   time.Time
+		ok     bool
+	}{
+		{
+			title: "rfc3339",
+			text:  "2021-10-01T12:00:00.123Z level=info",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 123000000, time.UTC),
+			ok:    true,
+		},
+		{
+			title: "iso8601 with offset",
+			text:  "[2021-10-01 12:00:00+0900] start",
+			want:  time.Date(2021, 10, 1, 3, 0, 0, 0, time.UTC),
+			ok:    true,
+		},
+		{
+			title: "zone-less uses location",
+			text:  "2021-10-01 12:00:00,500 INFO x",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 500000000, jst),
+			ok:    true,
+		},
+		{
+			title: "clf",
+			text:  `127.0.0.1 - - [10/Oct/2021:13:55:36 -0700] "GET / HTTP/1.1" 200 2326`,
+			want:  time.Date(2021, 10, 10, 20, 55, 36, 0, time.UTC),
+			ok:    true,
+		},
+		{
+			title: "go log",
+			text:  "2021/10/01 12:00:00 hello",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 0, jst),
+			ok:    true,
+		},
+		{
+			title: "rfc3164 this year",
+			text:  "<34>Oct  1 12:00:00 host app: x",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 0, jst),
+			ok:    true,
+		},
+		{
+			title: "rfc3164 last year",
+			text:  "Dec 31 23:59:59 host app: x",
+			want:  time.Date(2020, 12, 31, 23, 59, 59, 0, jst),
+			ok:    true,
+		},
+		{
+			title: "epoch seconds",
+			text:  "1633089600.5 x",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 500000000, time.UTC),
+			ok:    true,
+		},
+		{
+			title: "epoch millis",
+			text:  "1633089600123 x",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 123000000, time.UTC),
+			ok:    true,
+		},
+		{
+			title: "custom layout",
+			text:  "at 20211001-120000 done",
+			want:  time.Date(2021, 10, 1, 12, 0, 0, 0, jst),
+			ok:    true,
+		},
+		{
+			title:  "field string",
+			text:   "2000-01-01T00:00:00Z",
+			fields: parse.Fields{"ts": "2021-10-01T12:00:00Z"},
+			want:   time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC),
+			ok:     true,
+		},
+		{
+			title:  "field epoch",
+			fields: parse.Fields{"time": float64(1633089600)},
+			want:   time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC),
+			ok:     true,
+		},
+		{
+			title:  "field t is not a time",
+			fields: parse.Fields{"t": float64(1633089600)},
+		},
+		{
+			title: "not found",
+			text:  "no time here 12:00",
+		},
+	} {
+		tc := tc
+		t.Run(tc.title, func(t *testing.T) {
+			got, ok := e.Extract(tc.text, tc.fields)
+			assert.Equal(t, tc.ok, ok)
+			if ok {
+				assert.True(t, tc.want.Equal(got), "want %v got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	e, err := timestamp.New(timestamp.WithLocation(time.UTC))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t,
+		"[2021-10-10T20:55:36Z] GET /",
+		e.Rewrite("[10/Oct/2021:13:55:36 -0700] GET /", time.RFC3339, time.UTC),
+	)
+	assert.Equal(t, "no time", e.Rewrite("no time", time.RFC3339, time.UTC))
+}
+
+func TestNewInvalidLayout(t *testing.T) {
+	for _, tc := range []struct {
+		layout string
+		err    bool
+	}{
+		{layout: "20060102-150405"},
+		{layout: "Jan _2 15:04"},
+		{layout: "no time", err: true},
+		{layout: "", err: true},
+	} {
+		t.Run(tc.layout, func(t *testing.T) {
+			_, err := timestamp.New(timestamp.WithLayouts(tc.layout))
+			if tc.err {
+				assert.ErrorIs(t, err, timestamp.ErrInvalidLayout)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
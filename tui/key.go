@@ -0,0 +1,100 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyCode is the kind of a key.
+type KeyCode int
+
+const (
+	// KeyRune is a printable character.
+	KeyRune KeyCode = iota
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeyEscape
+	KeyBackspace
+	KeyTab
+	KeyBacktab
+	KeyCtrlC
+	// KeyUnknown is an unsupported key.
+	KeyUnknown
+)
+
+// Key is a key press.
+type Key struct {
+	Code KeyCode
+	// Rune is the character of KeyRune.
+	Rune rune
+}
+
+// escapes maps the escape sequences following ESC to the keys.
+var escapes = map[string]KeyCode{
+	"[A":  KeyUp,
+	"OA":  KeyUp,
+	"[B":  KeyDown,
+	"OB":  KeyDown,
+	"[5~": KeyPageUp,
+	"[6~": KeyPageDown,
+	"[H":  KeyHome,
+	"OH":  KeyHome,
+	"[1~": KeyHome,
+	"[7~": KeyHome,
+	"[F":  KeyEnd,
+	"OF":  KeyEnd,
+	"[4~": KeyEnd,
+	"[8~": KeyEnd,
+	"[Z":  KeyBacktab,
+}
+
+// ParseKeys parses the input of the terminal in the raw mode.
+// ESC at the end of the input is the escape key.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			key, n := parseEscape(b)
+			keys = append(keys, key)
+			b = b[n:]
+			continue
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c < 0x20:
+			keys = append(keys, Key{Code: KeyUnknown})
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			b = b[n:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// parseEscape parses the sequence starting with ESC, returns the key and the length.
+func parseEscape(b []byte) (Key, int) {
+	if len(b) < 2 || (b[1] != '[' && b[1] != 'O') {
+		return Key{Code: KeyEscape}, 1
+	}
+	// CSI or SS3: parameters then a final byte in 0x40-0x7e
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			if code, ok := escapes[string(b[1:i+1])]; ok {
+				return Key{Code: code}, i + 1
+			}
+			return Key{Code: KeyUnknown}, i + 1
+		}
+	}
+	return Key{Code: KeyUnknown}, len(b)
+}
@@ -0,0 +1,64 @@
+package tui_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/gotailf/tui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		input string
+		want  []tui.Key
+	}{
+		{
+			title: "runes",
+			input: "aあ/",
+			want: []tui.Key{
+				{Code: tui.KeyRune, Rune: 'a'},
+				{Code: tui.KeyRune, Rune: 'あ'},
+				{Code: tui.KeyRune, Rune: '/'},
+			},
+		},
+		{
+			title: "controls",
+			input: "\r\t\x7f\x03\x01",
+			want: []tui.Key{
+				{Code: tui.KeyEnter},
+				{Code: tui.KeyTab},
+				{Code: tui.KeyBackspace},
+				{Code: tui.KeyCtrlC},
+				{Code: tui.KeyUnknown},
+			},
+		},
+		{
+			title: "escape sequences",
+			input: "\x1b[A\x1bOB\x1b[5~\x1b[6~\x1b[H\x1b[4~\x1b[Z\x1b[1;5C",
+			want: []tui.Key{
+				{Code: tui.KeyUp},
+				{Code: tui.KeyDown},
+				{Code: tui.KeyPageUp},
+				{Code: tui.KeyPageDown},
+				{Code: tui.KeyHome},
+				{Code: tui.KeyEnd},
+				{Code: tui.KeyBacktab},
+				{Code: tui.KeyUnknown},
+			},
+		},
+		{
+			title: "escape",
+			input: "\x1bq\x1b",
+			want: []tui.Key{
+				{Code: tui.KeyEscape},
+				{Code: tui.KeyRune, Rune: 'q'},
+				{Code: tui.KeyEscape},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.want, tui.ParseKeys([]byte(tc.input)))
+		})
+	}
+}
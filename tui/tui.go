@@ -0,0 +1,266 @@
+// Package tui provides an interactive terminal UI to follow the files.
+//
+// The view keeps following the bottom, and pauses when scrolled up.
+// Multiple files are shown in tabs or split panes.
+//
+// Keys:
+//
+//	q, Ctrl-C         quit
+//	Up, k / Down, j   scroll a line
+//	PgUp, b / PgDn, Space  scroll a page
+//	Home, g           the top
+//	End, G, f         the bottom, resume following
+//	/                 incremental search, Enter to confirm, Esc to cancel
+//	n / N             the previous (older) / next (newer) match
+//	Esc               clear the search
+//	&                 filter the lines by the text
+//	F                 toggle the filter
+//	L                 cycle the min level: all, debug, info, warn and error
+//	Tab, Shift-Tab, 1-9  switch the files
+//	s                 toggle the split panes
+//
+// The search and the filter ignore the case unless the text has upper case letters.
+package tui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/internal"
+	"github.com/berquerant/gotailf/internal/term"
+)
+
+// Config represents the UI configurations.
+type Config struct {
+	// MaxLines is the max number of the lines kept per file.
+	// Default is 10000.
+	MaxLines int
+	// Color enables the colors.
+	// Default is true.
+	Color bool
+	// RefreshInterval is the min interval of the redraws, should be positive.
+	// Default is 50 milliseconds.
+	RefreshInterval time.Duration
+	// Backfill is the number of the last lines of each file shown first.
+	// The lines are found by scanning the tail of the file, without the index of the file.
+	// Default is 0.
+	Backfill int64
+	// TailerOptions are the options of the tailers of Run.
+	// Default is empty.
+	TailerOptions []gotailf.Option
+	// Prepare is applied to the records before they are shown, e.g. to detect the levels.
+	// Default is Record.DetectLevel.
+	Prepare func(*gotailf.Record)
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		MaxLines:        10000,
+		Color:           true,
+		RefreshInterval: 50 * time.Millisecond,
+		Prepare:         (*gotailf.Record).DetectLevel,
+	}
+}
+
+type Option func(*Config)
+
+// WithMaxLines sets Config.MaxLines.
+func WithMaxLines(n int) Option {
+	return func(c *Config) {
+		c.MaxLines = n
+	}
+}
+
+// WithColor sets Config.Color.
+func WithColor(b bool) Option {
+	return func(c *Config) {
+		c.Color = b
+	}
+}
+
+// WithRefreshInterval sets Config.RefreshInterval.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.RefreshInterval = interval
+	}
+}
+
+// WithBackfill sets Config.Backfill.
+func WithBackfill(n int64) Option {
+	return func(c *Config) {
+		c.Backfill = n
+	}
+}
+
+// WithTailerOptions sets Config.TailerOptions.
+func WithTailerOptions(opts ...gotailf.Option) Option {
+	return func(c *Config) {
+		c.TailerOptions = opts
+	}
+}
+
+// WithPrepare sets Config.Prepare.
+func WithPrepare(f func(*gotailf.Record)) Option {
+	return func(c *Config) {
+		c.Prepare = f
+	}
+}
+
+var (
+	// ErrNotTerminal means that the stdin or the stdout is not a terminal.
+	ErrNotTerminal = errors.New("not a terminal")
+	// ErrInvalidRefreshInterval means that Config.RefreshInterval is not positive.
+	ErrInvalidRefreshInterval = errors.New("invalid refresh interval")
+)
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// Run shows the UI on the terminal of the stdin and the stdout until the user quits or ctx ends.
+func Run(ctx context.Context, filenames []string, opts ...Option) error {
+	var (
+		inFd  = int(os.Stdin.Fd())
+		outFd = int(os.Stdout.Fd())
+	)
+	view := NewView(filenames, opts...)
+	if view.config.RefreshInterval <= 0 {
+		return ErrInvalidRefreshInterval
+	}
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return ErrNotTerminal
+	}
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = term.Restore(inFd, state)
+	}()
+	_, _ = io.WriteString(os.Stdout, enterScreen)
+	defer func() {
+		_, _ = io.WriteString(os.Stdout, leaveScreen)
+	}()
+
+	return runView(ctx, view, filenames, readInput(os.Stdin), func() (int, int, error) {
+		return term.Size(outFd)
+	}, os.Stdout)
+}
+
+// readInput yields the input until an error.
+// The reader is left blocked on return because the terminal cannot be canceled.
+func readInput(r io.Reader) <-chan []byte {
+	inputC := make(chan []byte)
+	go func() {
+		defer close(inputC)
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				inputC <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return inputC
+}
+
+type indexedRecord struct {
+	index  int
+	record *gotailf.Record
+}
+
+func runView(ctx context.Context, view *View, filenames []string, inputC <-chan []byte, size func() (int, int, error), out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		config  = view.config
+		recordC = make(chan *indexedRecord, 1000)
+		tailers = make([]gotailf.Tailer, len(filenames))
+	)
+	for i, filename := range filenames {
+		opts := config.TailerOptions
+		if config.Backfill > 0 {
+			// the end of the file if not found
+			if offset, err := internal.FileLastLinesOffset(filename, config.Backfill, '\n'); err == nil {
+				opts = append(slices.Clone(opts), gotailf.WithOffset(offset))
+			}
+		}
+		tailers[i] = gotailf.NewContinueTailer(filename, opts...)
+		go func() {
+			for r := range tailers[i].Records(ctx) {
+				if config.Prepare != nil {
+					config.Prepare(r)
+				}
+				select {
+				case recordC <- &indexedRecord{index: i, record: r}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	var (
+		ticker        = time.NewTicker(config.RefreshInterval)
+		dirty         = true
+		width, height int
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case x := <-recordC:
+			view.Add(x.index, x.record)
+			dirty = true
+		case input, ok := <-inputC:
+			if !ok {
+				return nil
+			}
+			for _, key := range ParseKeys(input) {
+				view.Handle(key)
+			}
+			if view.Quit() {
+				return nil
+			}
+			dirty = true
+		case <-ticker.C:
+			w, h, err := size()
+			if err != nil {
+				return err
+			}
+			if !dirty && w == width && h == height {
+				continue
+			}
+			dirty, width, height = false, w, h
+			if err := draw(out, view.Render(width, height)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// draw overwrites the screen with the lines.
+func draw(w io.Writer, lines []string) error {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, x := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(x)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
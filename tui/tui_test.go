@@ -0,0 +1,15 @@
+package tui_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/berquerant/gotailf/tui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunInvalidRefreshInterval(t *testing.T) {
+	err := tui.Run(context.TODO(), []string{"app"}, tui.WithRefreshInterval(0))
+	assert.True(t, errors.Is(err, tui.ErrInvalidRefreshInterval), "%v", err)
+}
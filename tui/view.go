@@ -0,0 +1,571 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+)
+
+const (
+	sgrReset   = "\x1b[0m"
+	sgrReverse = "\x1b[7m"
+	sgrNoRev   = "\x1b[27m"
+	sgrEvent   = "\x1b[36m"
+)
+
+// levelColors are the colors of the lines by the levels.
+var levelColors = map[level.Level]string{
+	level.Trace: "\x1b[90m",
+	level.Debug: "\x1b[90m",
+	level.Warn:  "\x1b[33m",
+	level.Error: "\x1b[31m",
+	level.Fatal: "\x1b[1;31m",
+}
+
+// line is a line of a pane.
+type line struct {
+	text  string
+	level level.Level
+	// event is true if the line is a notice of the tailing, e.g. the truncation.
+	event bool
+}
+
+// pane is the lines of a file.
+type pane struct {
+	name  string
+	lines []*line
+	// end is the offset of the next line, -1 if unknown.
+	end int64
+	// scroll is the number of the visible lines below the view, 0 means following.
+	scroll int
+	// unread is the number of the visible lines arrived while paused.
+	unread int
+}
+
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeSearch
+	modeFilter
+)
+
+// View is the state of the terminal UI.
+type View struct {
+	config *Config
+	panes  []*pane
+	focus  int
+	split  bool
+	mode   mode
+	// input is the text being edited in modeSearch or modeFilter.
+	input string
+	// search is the search text, highlighted in the lines.
+	search string
+	// match is the index of the current match in the visible lines, -1 if none.
+	match     int
+	filter    string
+	filterOn  bool
+	threshold level.Level
+	// rows is the number of the lines of the focused pane in the last render.
+	rows int
+	quit bool
+}
+
+// NewView returns a new View of the panes of the names.
+func NewView(names []string, opts ...Option) *View {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	panes := make([]*pane, len(names))
+	for i, name := range names {
+		panes[i] = &pane{
+			name: name,
+			end:  -1,
+		}
+	}
+	return &View{
+		config: config,
+		panes:  panes,
+		match:  -1,
+		rows:   1,
+	}
+}
+
+// Quit reports whether the user quit.
+func (v *View) Quit() bool { return v.quit }
+
+// Add appends the record to the i-th pane.
+func (v *View) Add(i int, r *gotailf.Record) {
+	p := v.panes[i]
+	switch {
+	case r.Skipped > 0:
+		v.push(p, &line{text: "--- " + r.Text + " ---", event: true})
+		p.end = r.End
+		return
+	case r.Offset < p.end && r.Offset == 0:
+		// reopened from the origin
+		v.push(p, &line{text: "--- rotated or truncated ---", event: true})
+	}
+	p.end = r.End
+	v.push(p, &line{
+		text:  r.Text,
+		level: r.Level,
+	})
+}
+
+func (v *View) push(p *pane, x *line) {
+	p.lines = append(p.lines, x)
+	if n := len(p.lines) - v.config.MaxLines; n > 0 {
+		p.lines = p.lines[n:]
+		if p == v.panes[v.focus] && v.match >= 0 {
+			v.match = max(v.match-n, -1)
+		}
+	}
+	if p.scroll > 0 && v.visible(x) {
+		// keep the view while paused
+		p.scroll++
+		p.unread++
+	}
+}
+
+// visible reports whether the line passes the filters.
+func (v *View) visible(x *line) bool {
+	if x.event {
+		return true
+	}
+	if v.threshold != level.Unknown && x.level < v.threshold {
+		return false
+	}
+	return !v.filterOn || v.filter == "" || contains(x.text, v.filter)
+}
+
+func (v *View) visibleLines(p *pane) []*line {
+	var lines []*line
+	for _, x := range p.lines {
+		if v.visible(x) {
+			lines = append(lines, x)
+		}
+	}
+	return lines
+}
+
+// contains reports whether the text contains the pattern,
+// ignoring the case if the pattern has no upper case letters.
+func contains(text, pattern string) bool {
+	if strings.IndexFunc(pattern, unicode.IsUpper) >= 0 {
+		return strings.Contains(text, pattern)
+	}
+	return strings.Contains(strings.ToLower(text), pattern)
+}
+
+func (v *View) focused() *pane { return v.panes[v.focus] }
+
+// maxScroll returns the scroll that shows the top of the lines.
+func (v *View) maxScroll(total int) int { return max(total-v.rows, 0) }
+
+// page returns the number of the lines scrolled by a page.
+func (v *View) page() int { return max(v.rows-1, 1) }
+
+// scrollBy scrolls the focused pane up by n lines, down if negative.
+func (v *View) scrollBy(n int) {
+	p := v.focused()
+	total := len(v.visibleLines(p))
+	p.scroll = min(max(p.scroll+n, 0), v.maxScroll(total))
+	if p.scroll == 0 {
+		p.unread = 0
+	}
+}
+
+// follow scrolls the focused pane to the bottom.
+func (v *View) follow() {
+	p := v.focused()
+	p.scroll = 0
+	p.unread = 0
+}
+
+// find moves to the match of the search from the index to the direction, 1 is newer.
+func (v *View) find(from, dir int) {
+	if v.search == "" {
+		v.match = -1
+		return
+	}
+	lines := v.visibleLines(v.focused())
+	for i := from; i >= 0 && i < len(lines); i += dir {
+		if !lines[i].event && contains(lines[i].text, v.search) {
+			v.match = i
+			p := v.focused()
+			// the match at the top of the view
+			p.scroll = min(max(len(lines)-i-v.rows, 0), v.maxScroll(len(lines)))
+			if p.scroll == 0 {
+				p.unread = 0
+			}
+			return
+		}
+	}
+}
+
+// refilter keeps the scroll in the range after the filters changed.
+func (v *View) refilter() {
+	v.match = -1
+	for _, p := range v.panes {
+		total := len(v.visibleLines(p))
+		p.scroll = min(p.scroll, v.maxScroll(total))
+		p.unread = min(p.unread, p.scroll)
+	}
+}
+
+// levels are the thresholds cycled by L.
+var levels = []level.Level{level.Unknown, level.Debug, level.Info, level.Warn, level.Error}
+
+// Handle updates the view by the key.
+func (v *View) Handle(key Key) {
+	switch v.mode {
+	case modeSearch, modeFilter:
+		v.handleInput(key)
+		return
+	}
+	switch key.Code {
+	case KeyCtrlC:
+		v.quit = true
+	case KeyUp:
+		v.scrollBy(1)
+	case KeyDown:
+		v.scrollBy(-1)
+	case KeyPageUp:
+		v.scrollBy(v.page())
+	case KeyPageDown:
+		v.scrollBy(-v.page())
+	case KeyHome:
+		v.scrollBy(len(v.focused().lines))
+	case KeyEnd:
+		v.follow()
+	case KeyEscape:
+		v.search = ""
+		v.match = -1
+	case KeyTab:
+		v.focusTo((v.focus + 1) % len(v.panes))
+	case KeyBacktab:
+		v.focusTo((v.focus + len(v.panes) - 1) % len(v.panes))
+	case KeyRune:
+		v.handleRune(key.Rune)
+	}
+}
+
+func (v *View) handleRune(r rune) {
+	switch r {
+	case 'q':
+		v.quit = true
+	case 'k':
+		v.scrollBy(1)
+	case 'j':
+		v.scrollBy(-1)
+	case 'b':
+		v.scrollBy(v.page())
+	case ' ':
+		v.scrollBy(-v.page())
+	case 'g':
+		v.scrollBy(len(v.focused().lines))
+	case 'G', 'f':
+		v.follow()
+	case '/':
+		v.mode = modeSearch
+		v.input = ""
+	case '&':
+		v.mode = modeFilter
+		v.input = v.filter
+	case 'n':
+		v.find(v.current()-1, -1)
+	case 'N':
+		v.find(v.current()+1, 1)
+	case 'F':
+		v.filterOn = !v.filterOn
+		v.refilter()
+	case 'L':
+		for i, l := range levels {
+			if l == v.threshold {
+				v.threshold = levels[(i+1)%len(levels)]
+				break
+			}
+		}
+		v.refilter()
+	case 's':
+		v.split = !v.split
+	default:
+		if r >= '1' && r <= '9' && int(r-'1') < len(v.panes) {
+			v.focusTo(int(r - '1'))
+		}
+	}
+}
+
+// current returns the index of the current match, or the bottom of the view.
+func (v *View) current() int {
+	if v.match >= 0 {
+		return v.match
+	}
+	p := v.focused()
+	return len(v.visibleLines(p)) - p.scroll
+}
+
+func (v *View) focusTo(i int) {
+	v.focus = i
+	v.match = -1
+}
+
+func (v *View) handleInput(key Key) {
+	switch key.Code {
+	case KeyEnter:
+		if v.mode == modeFilter {
+			v.filter = v.input
+			v.filterOn = v.filter != ""
+			v.refilter()
+		}
+		v.mode = modeNormal
+		return
+	case KeyEscape, KeyCtrlC:
+		if v.mode == modeSearch {
+			v.search = ""
+			v.match = -1
+		}
+		v.mode = modeNormal
+		return
+	case KeyBackspace:
+		if _, n := utf8.DecodeLastRuneInString(v.input); n > 0 {
+			v.input = v.input[:len(v.input)-n]
+		}
+	case KeyRune:
+		v.input += string(key.Rune)
+	default:
+		return
+	}
+	if v.mode == modeSearch {
+		// incremental search from the bottom of the view
+		p := v.focused()
+		v.search = v.input
+		v.match = -1
+		v.find(len(v.visibleLines(p))-1-p.scroll, -1)
+	}
+}
+
+// Render returns the lines of the screen of the size.
+func (v *View) Render(width, height int) []string {
+	var (
+		screen = make([]string, 0, height)
+		body   = height - 1 // status line
+	)
+	if body < 1 || width < 1 {
+		return screen
+	}
+	switch {
+	case v.split && len(v.panes) > 1:
+		n := len(v.panes)
+		for i, p := range v.panes {
+			h := body / n
+			if i < body%n {
+				h++
+			}
+			if h < 2 {
+				continue
+			}
+			screen = append(screen, v.header(p, i, width))
+			screen = append(screen, v.renderPane(p, width, h-1, i == v.focus)...)
+		}
+	case len(v.panes) > 1:
+		screen = append(screen, v.tabs(width))
+		screen = append(screen, v.renderPane(v.focused(), width, body-1, true)...)
+	default:
+		screen = append(screen, v.renderPane(v.focused(), width, body, true)...)
+	}
+	return append(screen, v.status(width))
+}
+
+func (v *View) style(s, sgr string) string {
+	if !v.config.Color || sgr == "" {
+		return s
+	}
+	return sgr + s + sgrReset
+}
+
+func (v *View) header(p *pane, i, width int) string {
+	text := truncate(fmt.Sprintf(" %d:%s", i+1, p.name), width)
+	if i == v.focus {
+		return v.style(pad(text, width), sgrReverse)
+	}
+	return text
+}
+
+func (v *View) tabs(width int) string {
+	var (
+		b    strings.Builder
+		rest = width
+	)
+	for i, p := range v.panes {
+		tab := fmt.Sprintf(" %d:%s ", i+1, p.name)
+		if p.scroll > 0 && p.unread > 0 {
+			tab = fmt.Sprintf(" %d:%s(+%d) ", i+1, p.name, p.unread)
+		}
+		tab = truncate(tab, rest)
+		rest -= utf8.RuneCountInString(tab)
+		if i == v.focus {
+			b.WriteString(v.style(tab, sgrReverse))
+		} else {
+			b.WriteString(tab)
+		}
+		if rest <= 0 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func (v *View) renderPane(p *pane, width, height int, focused bool) []string {
+	lines := v.visibleLines(p)
+	// the old lines may be dropped while paused
+	p.scroll = min(p.scroll, max(len(lines)-height, 0))
+	p.unread = min(p.unread, p.scroll)
+	var (
+		bottom = len(lines) - p.scroll // exclusive
+		top    = max(bottom-height, 0)
+		rows   = make([]string, 0, height)
+	)
+	if focused {
+		v.rows = max(height, 1)
+	}
+	for i := top; i < bottom; i++ {
+		rows = append(rows, v.renderLine(lines[i], width, focused && i == v.match))
+	}
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	return rows
+}
+
+func (v *View) renderLine(x *line, width int, current bool) string {
+	text := truncate(sanitize(x.text), width)
+	if x.event {
+		return v.style(text, sgrEvent)
+	}
+	if !v.config.Color {
+		return text
+	}
+	base := levelColors[x.level]
+	if current {
+		return sgrReverse + base + text + sgrReset
+	}
+	if v.search != "" {
+		text = highlight(text, v.search, base)
+	}
+	if base == "" {
+		return text
+	}
+	return base + text + sgrReset
+}
+
+// highlight reverses the matches of the pattern.
+func highlight(text, pattern, base string) string {
+	var (
+		b       strings.Builder
+		lower   = text
+		fold    = strings.IndexFunc(pattern, unicode.IsUpper) < 0
+		matched bool
+	)
+	if fold {
+		lower = strings.ToLower(text)
+	}
+	if len(lower) != len(text) {
+		// the case folding changed the offsets
+		return text
+	}
+	for {
+		i := strings.Index(lower, pattern)
+		if i < 0 {
+			break
+		}
+		matched = true
+		b.WriteString(text[:i])
+		b.WriteString(sgrReverse)
+		b.WriteString(text[i : i+len(pattern)])
+		b.WriteString(sgrNoRev)
+		b.WriteString(base)
+		text, lower = text[i+len(pattern):], lower[i+len(pattern):]
+	}
+	if !matched {
+		return text
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+func (v *View) status(width int) string {
+	switch v.mode {
+	case modeSearch:
+		return truncate("/"+v.input, width)
+	case modeFilter:
+		return truncate("&"+v.input, width)
+	}
+	p := v.focused()
+	parts := []string{p.name}
+	if p.scroll > 0 {
+		s := "PAUSED"
+		if p.unread > 0 {
+			s += fmt.Sprintf(" +%d new", p.unread)
+		}
+		parts = append(parts, s)
+	} else {
+		parts = append(parts, "FOLLOW")
+	}
+	if v.filter != "" {
+		state := "off"
+		if v.filterOn {
+			state = "on"
+		}
+		parts = append(parts, fmt.Sprintf("filter:%s(%s)", v.filter, state))
+	}
+	if v.threshold != level.Unknown {
+		parts = append(parts, "level>="+v.threshold.String())
+	}
+	if v.search != "" {
+		parts = append(parts, "search:"+v.search)
+	}
+	parts = append(parts, "q:quit /:search &:filter F:toggle L:level s:split tab:next")
+	return v.style(pad(truncate(strings.Join(parts, "  "), width), width), sgrReverse)
+}
+
+// sanitize replaces the control characters with spaces.
+func sanitize(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// truncate cuts the text at the width in runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	var n int
+	for i := range s {
+		if n == width {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+func pad(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
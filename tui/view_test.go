@@ -0,0 +1,218 @@
+package tui_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/tui"
+	"github.com/stretchr/testify/assert"
+)
+
+// adder appends the lines to a pane with the offsets.
+type adder struct {
+	view  *tui.View
+	index int
+	pos   int64
+}
+
+func (a *adder) add(texts ...string) {
+	for _, x := range texts {
+		r := &gotailf.Record{
+			Offset: a.pos,
+			End:    a.pos + int64(len(x)) + 1,
+			Text:   x,
+		}
+		r.DetectLevel()
+		a.view.Add(a.index, r)
+		a.pos = r.End
+	}
+}
+
+func lines(prefix string, n int) []string {
+	xs := make([]string, n)
+	for i := range xs {
+		xs[i] = fmt.Sprintf("%s%d", prefix, i+1)
+	}
+	return xs
+}
+
+func press(v *tui.View, input string) {
+	for _, k := range tui.ParseKeys([]byte(input)) {
+		v.Handle(k)
+	}
+}
+
+// body renders the view without the status line.
+func body(v *tui.View, width, height int) []string {
+	screen := v.Render(width, height)
+	return screen[:len(screen)-1]
+}
+
+func status(v *tui.View, width, height int) string {
+	screen := v.Render(width, height)
+	return strings.TrimRight(screen[len(screen)-1], " ")
+}
+
+func TestViewFollow(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false))
+	a := &adder{view: v}
+	a.add(lines("l", 5)...)
+	assert.Equal(t, []string{"l3", "l4", "l5"}, body(v, 80, 4))
+	assert.True(t, strings.HasPrefix(status(v, 80, 4), "app  FOLLOW"))
+
+	// pause
+	press(v, "k")
+	assert.Equal(t, []string{"l2", "l3", "l4"}, body(v, 80, 4))
+	a.add("l6", "l7")
+	assert.Equal(t, []string{"l2", "l3", "l4"}, body(v, 80, 4))
+	assert.True(t, strings.HasPrefix(status(v, 80, 4), "app  PAUSED +2 new"))
+
+	// top
+	press(v, "g")
+	assert.Equal(t, []string{"l1", "l2", "l3"}, body(v, 80, 4))
+	// page down
+	press(v, " ")
+	assert.Equal(t, []string{"l3", "l4", "l5"}, body(v, 80, 4))
+
+	// resume
+	press(v, "G")
+	assert.Equal(t, []string{"l5", "l6", "l7"}, body(v, 80, 4))
+	a.add("l8")
+	assert.Equal(t, []string{"l6", "l7", "l8"}, body(v, 80, 4))
+	assert.True(t, strings.HasPrefix(status(v, 80, 4), "app  FOLLOW"))
+
+	press(v, "q")
+	assert.True(t, v.Quit())
+}
+
+func TestViewMaxLines(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false), tui.WithMaxLines(3))
+	a := &adder{view: v}
+	a.add(lines("l", 5)...)
+	assert.Equal(t, []string{"l3", "l4", "l5", ""}, body(v, 80, 5))
+}
+
+func TestViewTruncate(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false))
+	a := &adder{view: v}
+	a.add("0123456789", "a\tb")
+	assert.Equal(t, []string{"01234", "a b"}, body(v, 5, 3))
+}
+
+func TestViewSearch(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false))
+	a := &adder{view: v}
+	a.add("foo 1", "bar 2", "Foo 3", "bar 4", "bar 5", "bar 6")
+	body(v, 80, 3)
+
+	// incremental
+	press(v, "/fo")
+	assert.Equal(t, "/fo", status(v, 80, 3))
+	assert.Equal(t, []string{"Foo 3", "bar 4"}, body(v, 80, 3))
+	press(v, "\r")
+	assert.True(t, strings.Contains(status(v, 80, 3), "search:fo"))
+
+	// older
+	press(v, "n")
+	assert.Equal(t, []string{"foo 1", "bar 2"}, body(v, 80, 3))
+	press(v, "n")
+	assert.Equal(t, []string{"foo 1", "bar 2"}, body(v, 80, 3))
+	// newer
+	press(v, "N")
+	assert.Equal(t, []string{"Foo 3", "bar 4"}, body(v, 80, 3))
+
+	// case sensitive
+	press(v, "/foo\x7f\x7f\x7fFoo\r")
+	assert.Equal(t, []string{"Foo 3", "bar 4"}, body(v, 80, 3))
+	press(v, "n")
+	assert.Equal(t, []string{"Foo 3", "bar 4"}, body(v, 80, 3))
+
+	// clear
+	press(v, "\x1b")
+	assert.False(t, strings.Contains(status(v, 80, 3), "search:"))
+}
+
+func TestViewFilter(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false))
+	a := &adder{view: v}
+	a.add("level=info a", "level=error b", "level=debug c", "level=warn d", "level=info e")
+
+	press(v, "& a\r")
+	assert.Equal(t, []string{"level=info a", "", ""}, body(v, 80, 4))
+	assert.True(t, strings.Contains(status(v, 80, 4), "filter: a(on)"))
+	press(v, "F")
+	assert.Equal(t, []string{"level=debug c", "level=warn d", "level=info e"}, body(v, 80, 4))
+	assert.True(t, strings.Contains(status(v, 80, 4), "filter: a(off)"))
+
+	// levels
+	press(v, "LLL")
+	assert.Equal(t, []string{"level=error b", "level=warn d", ""}, body(v, 80, 4))
+	assert.True(t, strings.Contains(status(v, 80, 4), "level>="+level.Warn.String()))
+	press(v, "LL")
+	assert.Equal(t, []string{"level=debug c", "level=warn d", "level=info e"}, body(v, 80, 4))
+}
+
+func TestViewReopen(t *testing.T) {
+	v := tui.NewView([]string{"app"}, tui.WithColor(false))
+	a := &adder{view: v}
+	a.add("a", "b")
+	a.pos = 0
+	a.add("x")
+	v.Add(0, &gotailf.Record{Offset: 2, End: 2, Text: "skipped 3 lines", Skipped: 3})
+	assert.Equal(t, []string{
+		"a",
+		"b",
+		"--- rotated or truncated ---",
+		"x",
+		"--- skipped 3 lines ---",
+	}, body(v, 80, 6))
+}
+
+func TestViewPanes(t *testing.T) {
+	v := tui.NewView([]string{"app", "db"}, tui.WithColor(false))
+	var (
+		app = &adder{view: v}
+		db  = &adder{view: v, index: 1}
+	)
+	app.add("a1", "a2", "a3")
+	db.add("d1", "d2")
+
+	assert.Equal(t, []string{" 1:app  2:db ", "a2", "a3"}, body(v, 80, 4))
+	press(v, "\t")
+	assert.Equal(t, []string{" 1:app  2:db ", "d1", "d2"}, body(v, 80, 4))
+	press(v, "1")
+	assert.Equal(t, []string{" 1:app  2:db ", "a2", "a3"}, body(v, 80, 4))
+	press(v, "\x1b[Z")
+	assert.Equal(t, []string{" 1:app  2:db ", "d1", "d2"}, body(v, 80, 4))
+
+	// split
+	press(v, "s")
+	assert.Equal(t, []string{" 1:app", "a2", "a3", " 2:db", "d1", "d2"}, trimRight(body(v, 80, 7)))
+}
+
+func trimRight(xs []string) []string {
+	for i, x := range xs {
+		xs[i] = strings.TrimRight(x, " ")
+	}
+	return xs
+}
+
+func TestViewColor(t *testing.T) {
+	v := tui.NewView([]string{"app"})
+	a := &adder{view: v}
+	a.add("level=error boom", "plain boom")
+	press(v, "/boom\r")
+	// the last line is the current match
+	assert.Equal(t, []string{
+		"\x1b[31mlevel=error \x1b[7mboom\x1b[27m\x1b[31m\x1b[0m",
+		"\x1b[7mplain boom\x1b[0m",
+	}, body(v, 80, 3))
+}
+
+func TestViewTabsWidth(t *testing.T) {
+	v := tui.NewView([]string{"app", "db"})
+	assert.Equal(t, "\x1b[7m 1:app \x1b[0m 2:", v.Render(10, 3)[0])
+}
@@ -0,0 +1,483 @@
+// Package web provides an http.Handler that streams the tails of the files
+// over Server-Sent Events and WebSocket, with a minimal HTML page to view them.
+//
+// The routes are relative to the mount point of the handler:
+//
+//	GET /        the HTML page
+//	GET /files   the JSON array of the names of the files
+//	GET /events  Server-Sent Events of a file
+//	GET /ws      WebSocket of a file
+//
+// The streams accept the query parameters:
+//
+//	file      the name of the file in the allowlist, required
+//	backfill  the number of the last lines to send first, default is 0
+//	where     the filter expression, see package filter
+//	level     the min level of the lines, the lines without levels are also dropped
+//	parse     the format of the lines to parse: auto, json, logfmt or kv
+//	format    the output: raw (default), logfmt, jsonl or template
+//	fields    comma separated field names of format logfmt
+//	template  text/template of format template
+//
+// The events of Server-Sent Events are:
+//
+//	event: line    data is the formatted line, id is the offset of the next line
+//	event: reset   the file is replaced or truncated, the following lines are of the new file from the origin
+//
+// The reconnection with Last-Event-ID resumes from the offset instead of the backfill.
+//
+// The messages of WebSocket are the JSON objects of Message.
+package web
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/filter"
+	"github.com/berquerant/gotailf/format"
+	"github.com/berquerant/gotailf/internal"
+	"github.com/berquerant/gotailf/level"
+	"github.com/berquerant/gotailf/parse"
+)
+
+//go:embed index.html
+var static embed.FS
+
+// Config represents Handler configurations.
+type Config struct {
+	// Heartbeat is the interval of the heartbeats while no lines.
+	// Not positive disables the heartbeats.
+	// Default is 15 seconds.
+	Heartbeat time.Duration
+	// WriteTimeout is the timeout of the writes to a client.
+	// Default is 30 seconds.
+	WriteTimeout time.Duration
+	// MaxBackfill is the max number of the backfill lines.
+	// Default is 1000.
+	MaxBackfill int64
+	// CheckOrigin reports whether the WebSocket request is allowed.
+	// Default is SameOrigin.
+	CheckOrigin func(r *http.Request) bool
+	// TailerOptions are the options of the tailers of the clients.
+	// The offsets are overwritten by the requests.
+	// Default is empty.
+	TailerOptions []gotailf.Option
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Heartbeat:    15 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		MaxBackfill:  1000,
+		CheckOrigin:  SameOrigin,
+	}
+}
+
+type Option func(*Config)
+
+// WithHeartbeat sets Config.Heartbeat.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *Config) {
+		c.Heartbeat = interval
+	}
+}
+
+// WithWriteTimeout sets Config.WriteTimeout.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.WriteTimeout = timeout
+	}
+}
+
+// WithMaxBackfill sets Config.MaxBackfill.
+func WithMaxBackfill(n int64) Option {
+	return func(c *Config) {
+		c.MaxBackfill = n
+	}
+}
+
+// WithCheckOrigin sets Config.CheckOrigin.
+func WithCheckOrigin(f func(r *http.Request) bool) Option {
+	return func(c *Config) {
+		c.CheckOrigin = f
+	}
+}
+
+// WithTailerOptions sets Config.TailerOptions.
+func WithTailerOptions(opts ...gotailf.Option) Option {
+	return func(c *Config) {
+		c.TailerOptions = opts
+	}
+}
+
+// SameOrigin reports whether the request has no Origin or the Origin is the host of the request.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Handler streams the tails of the files in the allowlist.
+type Handler struct {
+	// files maps the names to the paths.
+	files  map[string]string
+	config *Config
+	mux    *http.ServeMux
+}
+
+// NewHandler returns a new Handler.
+// files is the allowlist, maps the names requested by the clients to the paths.
+func NewHandler(files map[string]string, opts ...Option) *Handler {
+	config := newDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+	h := &Handler{
+		files:  files,
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /{$}", h.index)
+	h.mux.HandleFunc("GET /files", h.list)
+	h.mux.HandleFunc("GET /events", h.events)
+	h.mux.HandleFunc("GET /ws", h.websocket)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	b, _ := static.ReadFile("index.html")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(b)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.files))
+	for name := range h.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(names)
+}
+
+// query is the parameters of a stream.
+type query struct {
+	path      string
+	backfill  int64
+	expr      *filter.Expr
+	threshold level.Level
+	doParse   bool
+	format    parse.Format
+	formatter format.Formatter
+}
+
+func (h *Handler) parseQuery(values url.Values) (*query, error) {
+	name := values.Get("file")
+	path, ok := h.files[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown file: %s", name)
+	}
+	q := &query{path: path}
+	if v := values.Get("backfill"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid backfill: %s", v)
+		}
+		q.backfill = min(n, h.config.MaxBackfill)
+	}
+	if v := values.Get("where"); v != "" {
+		e, err := filter.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where: %w", err)
+		}
+		q.doParse = true
+		q.expr = e
+	}
+	if v := values.Get("level"); v != "" {
+		l, err := level.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		q.doParse = true
+		q.threshold = l
+	}
+	if v := values.Get("parse"); v != "" {
+		f, err := parse.ParseFormat(v)
+		if err != nil {
+			return nil, err
+		}
+		q.doParse = true
+		q.format = f
+	}
+	var fields []string
+	if v := values.Get("fields"); v != "" {
+		fields = strings.Split(v, ",")
+	}
+	switch mode := values.Get("format"); mode {
+	case "", "raw":
+		q.formatter = format.NewRaw()
+	case "logfmt":
+		q.doParse = true
+		q.formatter = format.NewLogfmt(fields)
+	case "jsonl":
+		q.doParse = true
+		q.formatter = format.NewJSONL()
+	case "template":
+		f, err := format.NewTemplate(values.Get("template"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		q.doParse = true
+		q.formatter = f
+	default:
+		return nil, fmt.Errorf("unknown format: %s", mode)
+	}
+	return q, nil
+}
+
+// match prepares the record and reports whether the record passes the filters.
+func (q *query) match(r *gotailf.Record) bool {
+	if r.Skipped > 0 {
+		return true
+	}
+	if q.doParse {
+		r.Parse(q.format)
+		r.DetectLevel()
+	}
+	if r.Level < q.threshold {
+		return false
+	}
+	return q.expr == nil || q.expr.Match(r)
+}
+
+// backfillOffset returns the offset of the last n lines of the file, or -1 as the end of the file.
+// Scans the tail of the file, not to index the whole file per connection.
+func backfillOffset(path string, n int64) int64 {
+	if n <= 0 {
+		return -1
+	}
+	offset, err := internal.FileLastLinesOffset(path, n, '\n')
+	if err != nil {
+		return -1
+	}
+	return offset
+}
+
+// stream is the destination of the lines.
+type stream interface {
+	// line sends the formatted line, end is the offset of the next line.
+	line(end int64, text string) error
+	// reset sends that the file is replaced or truncated.
+	reset() error
+	// ping sends the heartbeat.
+	ping() error
+}
+
+// follow sends the lines of the file from the offset, or the backfill lines if offset is negative.
+func (h *Handler) follow(ctx context.Context, q *query, offset int64, s stream) error {
+	opts := slices.Clone(h.config.TailerOptions)
+	if offset < 0 {
+		offset = backfillOffset(q.path, q.backfill)
+	}
+	opts = append(opts,
+		gotailf.WithOffset(offset),
+		gotailf.WithLine(0),
+		gotailf.WithTailFromOriginWhenGone(true),
+		gotailf.WithTailFromOriginWhenTruncated(true),
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		recordC = gotailf.NewContinueTailer(q.path, opts...).Records(ctx)
+		end     = offset
+		ticker  = internal.NewTicker(h.config.Heartbeat)
+		buf     bytes.Buffer
+	)
+	defer func() {
+		cancel()
+		for range recordC {
+		}
+	}()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := s.ping(); err != nil {
+				return err
+			}
+		case r, ok := <-recordC:
+			if !ok {
+				return nil
+			}
+			if r.Offset < end && r.Offset == 0 && r.Skipped == 0 {
+				// reopened from the origin
+				if err := s.reset(); err != nil {
+					return err
+				}
+			}
+			end = r.End
+			if !q.match(r) {
+				continue
+			}
+			buf.Reset()
+			if err := q.formatter.Format(&buf, r); err != nil {
+				continue
+			}
+			if err := s.line(r.End, strings.TrimSuffix(buf.String(), "\n")); err != nil {
+				return err
+			}
+			ticker.Reset()
+		}
+	}
+}
+
+// sse is a stream of Server-Sent Events.
+type sse struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (s *sse) write(text string) error {
+	if s.timeout > 0 {
+		_ = s.rc.SetWriteDeadline(time.Now().Add(s.timeout))
+	}
+	if _, err := s.w.Write([]byte(text)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func (s *sse) line(end int64, text string) error {
+	var b strings.Builder
+	b.WriteString("event: line\nid: ")
+	b.WriteString(strconv.FormatInt(end, 10))
+	b.WriteByte('\n')
+	for _, x := range strings.Split(text, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(x)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	return s.write(b.String())
+}
+
+func (s *sse) reset() error { return s.write("event: reset\ndata: \n\n") }
+func (s *sse) ping() error  { return s.write(": ping\n\n") }
+
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	q, err := h.parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset := int64(-1)
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			offset = n
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	s := &sse{
+		w:       w,
+		rc:      http.NewResponseController(w),
+		timeout: h.config.WriteTimeout,
+	}
+	if err := s.rc.Flush(); err != nil {
+		return
+	}
+	_ = h.follow(r.Context(), q, offset, s)
+}
+
+// Message is a WebSocket message.
+type Message struct {
+	// Type is line or reset, see the events of Server-Sent Events.
+	Type string `json:"type"`
+	// End is the offset of the next line of the line.
+	End int64 `json:"end,omitempty"`
+	// Text is the formatted line of the line.
+	Text string `json:"text,omitempty"`
+}
+
+// ws is a stream of WebSocket.
+type ws struct {
+	conn *wsConn
+}
+
+func (s *ws) send(m *Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.conn.writeFrame(opText, b)
+}
+
+func (s *ws) line(end int64, text string) error {
+	return s.send(&Message{
+		Type: "line",
+		End:  end,
+		Text: text,
+	})
+}
+
+func (s *ws) reset() error { return s.send(&Message{Type: "reset"}) }
+func (s *ws) ping() error  { return s.conn.writeFrame(opPing, nil) }
+
+func (h *Handler) websocket(w http.ResponseWriter, r *http.Request) {
+	if !h.config.CheckOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	q, err := h.parseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conn, err := upgrade(w, r, h.config.WriteTimeout)
+	if errors.Is(err, errNotWebSocket) {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, err.Error(), http.StatusUpgradeRequired)
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// detect the disconnection
+	go func() {
+		conn.readLoop()
+		cancel()
+	}()
+	if err := h.follow(ctx, q, -1, &ws{conn: conn}); err != nil {
+		return
+	}
+	_ = conn.writeFrame(opClose, closeFrame(1001, "going away"))
+}
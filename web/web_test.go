@@ -0,0 +1,391 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berquerant/gotailf"
+	"github.com/berquerant/gotailf/index"
+	"github.com/berquerant/gotailf/test"
+	"github.com/berquerant/gotailf/web"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer serves the file app.log in the dir.
+func newServer(t *testing.T, dir *test.TmpDir, opts ...web.Option) *httptest.Server {
+	opts = append([]web.Option{
+		web.WithHeartbeat(50 * time.Millisecond),
+		web.WithTailerOptions(
+			gotailf.WithFlushInterval(10*time.Millisecond),
+			gotailf.WithIndexOptions(index.WithDir(dir.Path("index"))),
+		),
+	}, opts...)
+	return httptest.NewServer(web.NewHandler(map[string]string{
+		"app": dir.Path("app.log"),
+	}, opts...))
+}
+
+func appendFile(t *testing.T, name, text string) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(text)
+	assert.Nil(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	ts := newServer(t, dir)
+	defer ts.Close()
+
+	t.Run("page", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		b, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(b), "EventSource")
+	})
+
+	t.Run("files", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/files")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		var got []string
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&got))
+		assert.Equal(t, []string{"app"}, got)
+	})
+
+	for _, tc := range []struct {
+		title string
+		query string
+	}{
+		{title: "unknown file", query: "file=other"},
+		{title: "invalid backfill", query: "file=app&backfill=x"},
+		{title: "invalid where", query: "file=app&where=" + url.QueryEscape("a ==")},
+		{title: "unknown format", query: "file=app&format=xml"},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/events?" + tc.query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+type event struct {
+	event string
+	id    string
+	data  string
+}
+
+// readEvents reads the events of the response.
+func readEvents(body io.Reader) <-chan *event {
+	eventC := make(chan *event, 100)
+	go func() {
+		defer close(eventC)
+		var (
+			scanner = bufio.NewScanner(body)
+			e       = &event{}
+			data    []string
+		)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if e.event != "" {
+					e.data = strings.Join(data, "\n")
+					eventC <- e
+				}
+				e = &event{}
+				data = nil
+			case strings.HasPrefix(line, ":"):
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				data = append(data, strings.TrimPrefix(line, "data: "))
+			}
+		}
+	}()
+	return eventC
+}
+
+func receive(t *testing.T, eventC <-chan *event, want ...*event) {
+	for _, w := range want {
+		select {
+		case got, ok := <-eventC:
+			if !ok {
+				t.Fatal("closed")
+			}
+			assert.Equal(t, w, got)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting %v", w)
+		}
+	}
+}
+
+func subscribe(t *testing.T, ts *httptest.Server, query string, header http.Header) (<-chan *event, func()) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return readEvents(resp.Body), func() {
+		cancel()
+		resp.Body.Close()
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("backfill", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		name := dir.Path("app.log")
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+		ts := newServer(t, dir)
+		defer ts.Close()
+
+		eventC, stop := subscribe(t, ts, "file=app&backfill=1", nil)
+		defer stop()
+		receive(t, eventC, &event{event: "line", id: "4", data: "b"})
+		appendFile(t, name, "c\n")
+		receive(t, eventC, &event{event: "line", id: "6", data: "c"})
+	})
+
+	t.Run("no heartbeats", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		name := dir.Path("app.log")
+		assert.Nil(t, os.WriteFile(name, []byte("a\n"), 0o600))
+		ts := newServer(t, dir, web.WithHeartbeat(0))
+		defer ts.Close()
+
+		eventC, stop := subscribe(t, ts, "file=app&backfill=1", nil)
+		defer stop()
+		receive(t, eventC, &event{event: "line", id: "2", data: "a"})
+	})
+
+	t.Run("last event id", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		name := dir.Path("app.log")
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\nc\n"), 0o600))
+		ts := newServer(t, dir)
+		defer ts.Close()
+
+		eventC, stop := subscribe(t, ts, "file=app&backfill=3", http.Header{
+			"Last-Event-Id": []string{"2"},
+		})
+		defer stop()
+		receive(t, eventC,
+			&event{event: "line", id: "4", data: "b"},
+			&event{event: "line", id: "6", data: "c"},
+		)
+	})
+
+	t.Run("filter and format", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		name := dir.Path("app.log")
+		assert.Nil(t, os.WriteFile(name, []byte("level=error msg=first n=1\nlevel=info msg=second n=2\nlevel=error msg=third n=3\n"), 0o600))
+		ts := newServer(t, dir)
+		defer ts.Close()
+
+		eventC, stop := subscribe(t, ts, "file=app&backfill=3&level=warn&where="+url.QueryEscape(`n > 1`)+
+			"&format=logfmt&fields=msg", nil)
+		defer stop()
+		receive(t, eventC, &event{event: "line", id: "78", data: "msg=third"})
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		dir := test.NewTmpDir(t)
+		defer dir.Remove(t)
+		name := dir.Path("app.log")
+		assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+		ts := newServer(t, dir)
+		defer ts.Close()
+
+		eventC, stop := subscribe(t, ts, "file=app&backfill=2", nil)
+		defer stop()
+		receive(t, eventC,
+			&event{event: "line", id: "2", data: "a"},
+			&event{event: "line", id: "4", data: "b"},
+		)
+		assert.Nil(t, os.WriteFile(name, []byte("x\n"), 0o600))
+		receive(t, eventC,
+			&event{event: "reset"},
+			&event{event: "line", id: "2", data: "x"},
+		)
+	})
+}
+
+// wsClient is a minimal websocket client.
+type wsClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, ts *httptest.Server, query string, header http.Header) (*wsClient, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws?"+query, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==") // RFC 6455
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{conn: conn, r: r}, resp
+}
+
+func (c *wsClient) readFrame(t *testing.T) (byte, []byte) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var b [2]byte
+		_, _ = io.ReadFull(c.r, b[:])
+		size = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, _ = io.ReadFull(c.r, b[:])
+		size = binary.BigEndian.Uint64(b[:])
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+// readMessage reads the next message, skipping the pings.
+func (c *wsClient) readMessage(t *testing.T) *web.Message {
+	for {
+		opcode, payload := c.readFrame(t)
+		if opcode == 0x9 {
+			continue
+		}
+		assert.Equal(t, byte(0x1), opcode)
+		var m web.Message
+		assert.Nil(t, json.Unmarshal(payload, &m))
+		return &m
+	}
+}
+
+func (c *wsClient) writeFrame(opcode byte, payload []byte) error {
+	b := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	b = append(b, mask...)
+	for i, x := range payload {
+		b = append(b, x^mask[i%4])
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func TestWebSocket(t *testing.T) {
+	dir := test.NewTmpDir(t)
+	defer dir.Remove(t)
+	name := dir.Path("app.log")
+	assert.Nil(t, os.WriteFile(name, []byte("a\nb\n"), 0o600))
+	ts := newServer(t, dir)
+	defer ts.Close()
+
+	t.Run("not upgrade", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/ws?file=app")
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+	})
+
+	t.Run("cross origin", func(t *testing.T) {
+		c, resp := dialWebSocket(t, ts, "file=app", http.Header{
+			"Origin": []string{"http://example.com"},
+		})
+		defer c.conn.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		c, resp := dialWebSocket(t, ts, "file=app&backfill=1", http.Header{
+			"Origin": []string{ts.URL},
+		})
+		defer c.conn.Close()
+		if !assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode) {
+			return
+		}
+		assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+		assert.Equal(t, &web.Message{Type: "line", End: 4, Text: "b"}, c.readMessage(t))
+		appendFile(t, name, "c\n")
+		assert.Equal(t, &web.Message{Type: "line", End: 6, Text: "c"}, c.readMessage(t))
+
+		// ping
+		assert.Nil(t, c.writeFrame(0x9, []byte("hi")))
+		for {
+			opcode, payload := c.readFrame(t)
+			if opcode == 0xa {
+				assert.Equal(t, "hi", string(payload))
+				break
+			}
+		}
+		// close
+		assert.Nil(t, c.writeFrame(0x8, []byte{0x03, 0xe8}))
+		for {
+			opcode, payload := c.readFrame(t)
+			if opcode == 0x8 {
+				assert.Equal(t, []byte{0x03, 0xe8}, payload)
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes.
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+)
+
+// websocketGUID is the key suffix of the handshake, RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxFrameSize is the max payload size of the frames from the clients.
+const maxFrameSize = 64 * 1024
+
+var (
+	errNotWebSocket  = errors.New("not a websocket handshake")
+	errFrameTooLarge = errors.New("websocket frame too large")
+	errNotMasked     = errors.New("websocket frame not masked")
+)
+
+// headerContains reports whether the comma separated header has the token.
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, x := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(x), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acceptKey returns Sec-WebSocket-Accept of the key.
+func acceptKey(key string) string {
+	h := sha1.New()
+	_, _ = io.WriteString(h, key)
+	_, _ = io.WriteString(h, websocketGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// wsConn is a server side websocket connection.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// mu guards the writes.
+	mu      sync.Mutex
+	w       *bufio.Writer
+	timeout time.Duration
+}
+
+// upgrade performs the websocket handshake.
+func upgrade(w http.ResponseWriter, r *http.Request, timeout time.Duration) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		return nil, errNotWebSocket
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket not supported")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	c := &wsConn{
+		conn:    conn,
+		r:       brw.Reader,
+		w:       brw.Writer,
+		timeout: timeout,
+	}
+	c.setWriteDeadline()
+	_, _ = c.w.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *wsConn) setWriteDeadline() {
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+func (c *wsConn) Close() error { return c.conn.Close() }
+
+// writeFrame writes an unfragmented frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setWriteDeadline()
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode // FIN
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if _, err := c.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(payload); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// frame is a frame from the client.
+type frame struct {
+	opcode  byte
+	payload []byte
+}
+
+// readFrame reads a frame from the client.
+func (c *wsConn) readFrame() (*frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	var (
+		opcode = header[0] & 0x0f
+		masked = header[1]&0x80 != 0
+		size   = uint64(header[1] & 0x7f)
+	)
+	switch size {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	}
+	if !masked {
+		return nil, errNotMasked
+	}
+	if size > maxFrameSize {
+		return nil, errFrameTooLarge
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return &frame{
+		opcode:  opcode,
+		payload: payload,
+	}, nil
+}
+
+// readLoop reads the frames until the client closes the connection,
+// answering the pings and ignoring the messages.
+func (c *wsConn) readLoop() {
+	for {
+		f, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch f.opcode {
+		case opClose:
+			// echo the status code
+			if len(f.payload) >= 2 {
+				_ = c.writeFrame(opClose, f.payload[:2])
+			} else {
+				_ = c.writeFrame(opClose, nil)
+			}
+			return
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeFrame returns the payload of the close frame with the status code.
+func closeFrame(code uint16, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, code), reason...)
+}